@@ -3,18 +3,31 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
 
 	"github.com/ViBiOh/httputils/v4/pkg/health"
 	"github.com/ViBiOh/httputils/v4/pkg/logger"
+	"github.com/ViBiOh/httputils/v4/pkg/model"
 	"github.com/ViBiOh/httputils/v4/pkg/pprof"
 	"github.com/ViBiOh/httputils/v4/pkg/request"
 	"github.com/ViBiOh/httputils/v4/pkg/telemetry"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 )
 
 type clients struct {
-	telemetry *telemetry.Service
-	pprof     *pprof.Service
-	health    *health.Service
+	telemetry     *telemetry.Service
+	meterProvider *sdkmetric.MeterProvider
+	prometheus    http.Handler
+	pprof         *pprof.Service
+	health        *health.Service
 }
 
 func newClients(ctx context.Context, config configuration) (clients, error) {
@@ -28,8 +41,13 @@ func newClients(ctx context.Context, config configuration) (clients, error) {
 		return output, fmt.Errorf("telemetry: %w", err)
 	}
 
+	output.meterProvider, output.prometheus, err = newMeterProvider(ctx, config.telemetry)
+	if err != nil {
+		return output, fmt.Errorf("meter provider: %w", err)
+	}
+
 	telemetry.AddOpenTelemetryToDefaultLogger(output.telemetry)
-	request.SetDefaultClient(telemetry.AddOpenTelemetryToClient(request.GetDefaultClient(), output.telemetry.MeterProvider(), output.telemetry.TracerProvider()))
+	request.SetDefaultClient(telemetry.AddOpenTelemetryToClient(request.GetDefaultClient(), output.meterProvider, output.telemetry.TracerProvider()))
 
 	service, version, env := output.telemetry.GetServiceVersionAndEnv()
 	output.pprof = pprof.New(config.pprof, service, version, env)
@@ -39,10 +57,59 @@ func newClients(ctx context.Context, config configuration) (clients, error) {
 	return output, nil
 }
 
+// newMeterProvider creates the provider of the app's metrics, read by the Prometheus exporter behind the returned handler and,
+// when an OpenTelemetry endpoint is configured, pushed to it with the same resource as the telemetry client
+func newMeterProvider(ctx context.Context, config *telemetry.Config) (*sdkmetric.MeterProvider, http.Handler, error) {
+	metricResource, err := newResource(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resource: %w", err)
+	}
+
+	registry := prometheus.NewRegistry()
+
+	prometheusExporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry), otelprometheus.WithoutScopeInfo())
+	if err != nil {
+		return nil, nil, fmt.Errorf("prometheus exporter: %w", err)
+	}
+
+	options := []sdkmetric.Option{sdkmetric.WithResource(metricResource), sdkmetric.WithReader(prometheusExporter)}
+
+	if len(config.URL) != 0 {
+		otlpExporter, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithInsecure(), otlpmetricgrpc.WithEndpoint(config.URL))
+		if err != nil {
+			return nil, nil, fmt.Errorf("metric exporter: %w", err)
+		}
+
+		options = append(options, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(otlpExporter)))
+	}
+
+	return sdkmetric.NewMeterProvider(options...), promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), nil
+}
+
+// newResource describes the service like the telemetry client does, for its metrics to be attached to the same service
+func newResource(ctx context.Context) (*resource.Resource, error) {
+	serviceResource, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithAttributes(
+			semconv.ServiceVersion(model.Version()),
+			attribute.String("git.commit.sha", model.GitSha()),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create resource: %w", err)
+	}
+
+	return resource.Merge(resource.Default(), serviceResource)
+}
+
 func (c clients) Start() {
 	go c.pprof.Start(c.health.DoneCtx())
 }
 
 func (c clients) Close(ctx context.Context) {
+	if err := c.meterProvider.Shutdown(ctx); err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "shutdown meter provider", slog.Any("error", err))
+	}
+
 	c.telemetry.Close(ctx)
 }
@@ -14,6 +14,9 @@ import (
 	"github.com/ViBiOh/httputils/v4/pkg/renderer"
 	"github.com/ViBiOh/httputils/v4/pkg/server"
 	"github.com/ViBiOh/httputils/v4/pkg/telemetry"
+	"github.com/ViBiOh/hue/pkg/activity"
+	"github.com/ViBiOh/hue/pkg/auth"
+	"github.com/ViBiOh/hue/pkg/history"
 	"github.com/ViBiOh/hue/pkg/hue"
 	v2 "github.com/ViBiOh/hue/pkg/v2"
 )
@@ -25,13 +28,18 @@ type configuration struct {
 	pprof     *pprof.Config
 	health    *health.Config
 
-	server   *server.Config
-	owasp    *owasp.Config
-	cors     *cors.Config
-	renderer *renderer.Config
+	server           *server.Config
+	prometheusServer *server.Config
+	prometheusMain   *bool
+	owasp            *owasp.Config
+	cors             *cors.Config
+	renderer         *renderer.Config
 
-	hue   *hue.Config
-	hueV2 *v2.Config
+	hue      *hue.Config
+	hueV2    *v2.Config
+	history  *history.Config
+	auth     *auth.Config
+	activity *activity.Config
 }
 
 func newConfig() configuration {
@@ -45,13 +53,18 @@ func newConfig() configuration {
 		pprof:     pprof.Flags(fs, "pprof"),
 		health:    health.Flags(fs, ""),
 
-		server:   server.Flags(fs, ""),
-		owasp:    owasp.Flags(fs, "", flags.NewOverride("Csp", "default-src 'self'; script-src 'httputils-nonce'; style-src 'httputils-nonce'")),
-		cors:     cors.Flags(fs, "cors"),
-		renderer: renderer.Flags(fs, "", flags.NewOverride("Title", "Hue"), flags.NewOverride("PublicURL", "https://hue.vibioh.fr")),
+		server:           server.Flags(fs, ""),
+		prometheusServer: server.Flags(fs, "prometheus", flags.NewOverride("Name", "prometheus"), flags.NewOverride("Port", uint(0))),
+		prometheusMain:   flags.New("Main", "Expose Prometheus metrics on /metrics of the main server").Prefix("prometheus").DocPrefix("prometheus").Bool(fs, false, nil),
+		owasp:            owasp.Flags(fs, "", flags.NewOverride("Csp", "default-src 'self'; script-src 'httputils-nonce'; style-src 'httputils-nonce'")),
+		cors:             cors.Flags(fs, "cors"),
+		renderer:         renderer.Flags(fs, "", flags.NewOverride("Title", "Hue"), flags.NewOverride("PublicURL", "https://hue.vibioh.fr")),
 
-		hue:   hue.Flags(fs, ""),
-		hueV2: v2.Flags(fs, "v2"),
+		hue:      hue.Flags(fs, ""),
+		hueV2:    v2.Flags(fs, "v2"),
+		history:  history.Flags(fs, "history"),
+		auth:     auth.Flags(fs, "auth"),
+		activity: activity.Flags(fs, "activity"),
 	}
 
 	_ = fs.Parse(os.Args[1:])
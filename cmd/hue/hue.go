@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"os"
+	_ "time/tzdata"
 
 	"github.com/ViBiOh/httputils/v4/pkg/alcotest"
 	"github.com/ViBiOh/httputils/v4/pkg/health"
@@ -23,12 +25,25 @@ func main() {
 	services, err := newServices(clients.health.EndCtx(), config, clients)
 	logger.FatalfOnErr(ctx, err, "client")
 
+	if config.hue.Validate {
+		code := services.Validate(ctx)
+		clients.Close(ctx)
+		os.Exit(code)
+	}
+
+	if config.hue.Plan {
+		code := services.Plan(ctx)
+		clients.Close(ctx)
+		os.Exit(code)
+	}
+
 	go services.Start(clients.health.DoneCtx())
 
-	port := newPort(clients, services)
+	port := newPort(clients, services, *config.prometheusMain)
 
 	go services.server.Start(clients.health.EndCtx(), port)
+	go services.prometheusServer.Start(clients.health.EndCtx(), newPrometheusPort(clients))
 
 	clients.health.WaitForTermination(services.server.Done())
-	health.WaitAll(services.server.Done())
+	health.WaitAll(services.server.Done(), services.prometheusServer.Done())
 }
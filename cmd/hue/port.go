@@ -4,20 +4,86 @@ import (
 	"net/http"
 
 	"github.com/ViBiOh/httputils/v4/pkg/httputils"
+	"github.com/ViBiOh/hue/pkg/auth"
+	"github.com/ViBiOh/hue/pkg/hue"
 )
 
-func newPort(clients clients, services services) http.Handler {
+func newPort(clients clients, services services, metrics bool) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("POST /api/groups/{id...}", services.hue.HandleGroup)
+	mux.HandleFunc("POST /api/scenes/{id...}", services.hue.HandleScene)
+	mux.HandleFunc("POST /api/smart_scenes/{id...}", services.hue.HandleSmartScene)
 	mux.HandleFunc("POST /api/schedules/{id...}", services.hue.HandleSchedule)
 	mux.HandleFunc("POST /api/sensors/{id...}", services.hue.HandleSensors)
+	mux.HandleFunc("POST /api/tokens/{id...}", services.hue.HandleToken)
+	mux.HandleFunc("GET /api/sensors/{id}/history", services.hue.HandleSensorHistory)
+
+	mux.HandleFunc("GET /api/v1/groups", services.hue.HandleAPIGroups)
+	mux.HandleFunc("PATCH /api/v1/groups", services.hue.HandleAPIGroupPatch)
+	mux.HandleFunc("GET /api/v1/groups/{id}", services.hue.HandleAPIGroup)
+	mux.HandleFunc("PATCH /api/v1/groups/{id}", services.hue.HandleAPIGroupPatch)
+	mux.HandleFunc("GET /api/v1/scenes", services.hue.HandleAPIScenes)
+	mux.HandleFunc("POST /api/v1/scenes", services.hue.HandleAPISceneCreate)
+	mux.HandleFunc("GET /api/v1/scenes/{id}", services.hue.HandleAPIScene)
+	mux.HandleFunc("DELETE /api/v1/scenes/{id}", services.hue.HandleAPISceneDelete)
+	mux.HandleFunc("POST /api/v1/scenes/{id}/recall", services.hue.HandleAPISceneRecall)
+	mux.HandleFunc("GET /api/v1/smart_scenes", services.hue.HandleAPISmartScenes)
+	mux.HandleFunc("POST /api/v1/smart_scenes", services.hue.HandleAPISmartSceneCreate)
+	mux.HandleFunc("GET /api/v1/smart_scenes/{id}", services.hue.HandleAPISmartScene)
+	mux.HandleFunc("PUT /api/v1/smart_scenes/{id}", services.hue.HandleAPISmartScenePut)
+	mux.HandleFunc("DELETE /api/v1/smart_scenes/{id}", services.hue.HandleAPISmartSceneDelete)
+	mux.HandleFunc("GET /api/v1/lights", services.hue.HandleAPILights)
+	mux.HandleFunc("GET /api/v1/lights/{id}", services.hue.HandleAPILight)
+	mux.HandleFunc("GET /api/v1/sensors", services.hue.HandleAPISensors)
+	mux.HandleFunc("GET /api/v1/sensors/{id}", services.hue.HandleAPISensor)
+	mux.HandleFunc("PATCH /api/v1/sensors/{id}", services.hue.HandleAPISensorPatch)
+	mux.HandleFunc("GET /api/v1/schedules", services.hue.HandleAPISchedules)
+	mux.HandleFunc("GET /api/v1/schedules/{id}", services.hue.HandleAPISchedule)
+	mux.HandleFunc("PATCH /api/v1/schedules/{id}", services.hue.HandleAPISchedulePatch)
+	mux.HandleFunc("PUT /api/v1/schedules/{id}", services.hue.HandleAPISchedulePut)
+	mux.HandleFunc("DELETE /api/v1/schedules/{id}", services.hue.HandleAPIScheduleDelete)
+	mux.HandleFunc("GET /api/v1/states", services.hue.HandleAPIStates)
+	mux.HandleFunc("GET /api/v1/activity", services.hue.HandleAPIActivity)
+	mux.HandleFunc("GET "+hue.EventsPath, services.hue.HandleEvents)
+	mux.HandleFunc("GET /api/openapi.json", services.hue.HandleOpenAPI)
+
+	if services.auth.LoginEnabled() {
+		mux.HandleFunc("GET "+auth.Path+"login", services.auth.HandleLogin)
+		mux.HandleFunc("GET "+auth.Path+"callback", services.auth.HandleCallback)
+		mux.HandleFunc("POST "+auth.Path+"logout", services.auth.HandleLogout)
+	}
+
+	mux.Handle("GET "+hue.DevicesPath, services.renderer.Handler(services.hue.TemplateDevices))
+	mux.Handle("GET "+hue.TokensPath, services.renderer.Handler(services.hue.TemplateTokens))
+	mux.Handle("GET "+hue.ActivityPath, services.renderer.Handler(services.hue.TemplateActivity))
 
 	services.renderer.RegisterMux(mux, services.hue.TemplateFunc)
 
-	return httputils.Handler(mux, clients.health,
+	handler := hue.NoWriteTimeout(httputils.Handler(mux, clients.health,
 		clients.telemetry.Middleware("http"),
 		services.owasp.Middleware,
 		services.cors.Middleware,
-	)
+		services.auth.Middleware,
+		services.activity.Middleware,
+	))
+
+	if !metrics {
+		return handler
+	}
+
+	// Scrapers don't authenticate, the metrics are served before the middlewares
+	root := http.NewServeMux()
+	root.Handle("GET /metrics", clients.prometheus)
+	root.Handle("/", handler)
+
+	return root
+}
+
+func newPrometheusPort(clients clients) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("GET /metrics", clients.prometheus)
+
+	return mux
 }
@@ -4,12 +4,16 @@ import (
 	"context"
 	"embed"
 	"fmt"
+	"log/slog"
 
 	"github.com/ViBiOh/httputils/v4/pkg/cors"
 	"github.com/ViBiOh/httputils/v4/pkg/logger"
 	"github.com/ViBiOh/httputils/v4/pkg/owasp"
 	"github.com/ViBiOh/httputils/v4/pkg/renderer"
 	"github.com/ViBiOh/httputils/v4/pkg/server"
+	"github.com/ViBiOh/hue/pkg/activity"
+	"github.com/ViBiOh/hue/pkg/auth"
+	"github.com/ViBiOh/hue/pkg/history"
 	"github.com/ViBiOh/hue/pkg/hue"
 	v2 "github.com/ViBiOh/hue/pkg/v2"
 )
@@ -18,12 +22,16 @@ import (
 var content embed.FS
 
 type services struct {
-	server   *server.Server
-	renderer *renderer.Service
-	hue      *hue.Service
-	huev2    *v2.Service
-	cors     cors.Service
-	owasp    owasp.Service
+	server           *server.Server
+	prometheusServer *server.Server
+	renderer         *renderer.Service
+	hue              *hue.Service
+	huev2            *v2.Service
+	history          *history.Service
+	auth             *auth.Service
+	activity         *activity.Service
+	cors             cors.Service
+	owasp            owasp.Service
 }
 
 func newServices(ctx context.Context, config configuration, clients clients) (services, error) {
@@ -31,20 +39,36 @@ func newServices(ctx context.Context, config configuration, clients clients) (se
 	var err error
 
 	output.server = server.New(config.server)
+	output.prometheusServer = server.New(config.prometheusServer)
 	output.owasp = owasp.New(config.owasp)
 	output.cors = cors.New(config.cors)
 
-	output.renderer, err = renderer.New(ctx, config.renderer, content, hue.FuncMap, clients.telemetry.MeterProvider(), clients.telemetry.TracerProvider())
+	output.renderer, err = renderer.New(ctx, config.renderer, content, hue.FuncMap, clients.meterProvider, clients.telemetry.TracerProvider())
 	if err != nil {
 		return output, fmt.Errorf("renderer: %w", err)
 	}
 
-	output.huev2, err = v2.New(config.hueV2, clients.telemetry.MeterProvider())
+	output.history, err = history.New(config.history, clients.telemetry.TracerProvider())
+	if err != nil {
+		return output, fmt.Errorf("history: %w", err)
+	}
+
+	output.activity, err = activity.New(config.activity)
+	if err != nil {
+		return output, fmt.Errorf("activity: %w", err)
+	}
+
+	output.huev2, err = v2.New(config.hueV2, clients.meterProvider, clients.telemetry.TracerProvider(), output.history, output.activity)
 	if err != nil {
 		return output, fmt.Errorf("hue v2: %w", err)
 	}
 
-	output.hue, err = hue.New(config.hue, clients.telemetry.TracerProvider(), output.renderer, output.huev2)
+	output.auth, err = auth.New(ctx, config.auth)
+	if err != nil {
+		return output, fmt.Errorf("auth: %w", err)
+	}
+
+	output.hue, err = hue.New(config.hue, *config.prometheusMain, clients.telemetry.TracerProvider(), output.renderer, output.huev2, output.history, output.auth, output.activity)
 	if err != nil {
 		return output, fmt.Errorf("hue: %w", err)
 	}
@@ -56,6 +80,71 @@ func (s services) Start(ctx context.Context) {
 	err := s.huev2.Init(ctx)
 	logger.FatalfOnErr(ctx, err, "init v2")
 
+	s.initHue(ctx)
+
 	go s.hue.Start(ctx)
 	go s.huev2.Start(ctx)
+	go s.history.Start(ctx)
+}
+
+// initHue reads the timezone of the bridge, the local time of the process being used if it's unknown
+func (s services) initHue(ctx context.Context) {
+	if err := s.hue.Init(ctx); err != nil {
+		slog.LogAttrs(ctx, slog.LevelWarn, "init hue, presets are resolved in the local time of the process", slog.Any("error", err))
+	}
+}
+
+// Validate checks the configuration against the inventory of the bridge, printing every issue, and returns the exit code
+func (s services) Validate(ctx context.Context) int {
+	if err := s.huev2.Init(ctx); err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "init v2", slog.Any("error", err))
+		return 1
+	}
+
+	s.initHue(ctx)
+
+	issues, err := s.hue.Validate()
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "validate", slog.Any("error", err))
+		return 1
+	}
+
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+
+	if len(issues) != 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// pendingChangesCode is the exit code of the plan when some changes are pending, failures exiting with 1
+const pendingChangesCode = 2
+
+// Plan prints the changes of the configuration to the bridge, without applying them, and returns the exit code
+func (s services) Plan(ctx context.Context) int {
+	if err := s.huev2.Init(ctx); err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "init v2", slog.Any("error", err))
+		return 1
+	}
+
+	s.initHue(ctx)
+
+	changes, err := s.hue.Plan(ctx)
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "plan", slog.Any("error", err))
+		return 1
+	}
+
+	for _, item := range changes {
+		fmt.Println(item)
+	}
+
+	if len(changes) != 0 {
+		return pendingChangesCode
+	}
+
+	return 0
 }
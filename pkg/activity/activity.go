@@ -0,0 +1,450 @@
+package activity
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViBiOh/flags"
+	"github.com/ViBiOh/httputils/v4/pkg/query"
+	"github.com/ViBiOh/hue/pkg/auth"
+)
+
+const (
+	filename = "activity.log"
+
+	// expectation is how long a change issued by the app is expected to come back from the event stream
+	expectation = time.Second * 15
+)
+
+type Source string
+
+const (
+	UI         Source = "ui"
+	API        Source = "api"
+	Cron       Source = "cron"
+	Automation Source = "automation"
+	Bridge     Source = "bridge"
+	External   Source = "external"
+)
+
+// Sources lists every source of activity
+var Sources = []Source{UI, API, Cron, Automation, Bridge, External}
+
+type Kind string
+
+const (
+	Group    Kind = "group"
+	Sensor   Kind = "sensor"
+	Schedule Kind = "schedule"
+)
+
+// Kinds lists every kind of target of activity
+var Kinds = []Kind{Group, Sensor, Schedule}
+
+// Origin describes who issued the actions done with a context
+type Origin struct {
+	Source Source
+	Actor  string
+	IP     string
+}
+
+type originKey struct{}
+
+func WithOrigin(ctx context.Context, origin Origin) context.Context {
+	return context.WithValue(ctx, originKey{}, origin)
+}
+
+// OriginFromContext returns the origin of the context, an automation of the app if none is set
+func OriginFromContext(ctx context.Context) Origin {
+	if origin, ok := ctx.Value(originKey{}).(Origin); ok {
+		return origin
+	}
+
+	return Origin{Source: Automation}
+}
+
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Source Source    `json:"source"`
+	Actor  string    `json:"actor,omitempty"`
+	IP     string    `json:"ip,omitempty"`
+	Kind   Kind      `json:"kind"`
+	Target string    `json:"target"`
+	Name   string    `json:"name,omitempty"`
+	State  string    `json:"state"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Filter selects entries of the log, empty fields matching everything
+type Filter struct {
+	From   time.Time
+	To     time.Time
+	Source Source
+	Kind   Kind
+	Target string
+	Limit  int
+}
+
+func (f Filter) match(entry Entry) bool {
+	if len(f.Source) != 0 && entry.Source != f.Source {
+		return false
+	}
+
+	if len(f.Kind) != 0 && entry.Kind != f.Kind {
+		return false
+	}
+
+	if len(f.Target) != 0 && entry.Target != f.Target && !strings.Contains(strings.ToLower(entry.Name), strings.ToLower(f.Target)) {
+		return false
+	}
+
+	if !f.From.IsZero() && entry.Time.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && entry.Time.After(f.To) {
+		return false
+	}
+
+	return true
+}
+
+// Attribution returns the name of the automation of the bridge managed by the app that changed the target, if any
+type Attribution func(ctx context.Context, kind Kind, target string, now time.Time) (string, bool)
+
+type Service struct {
+	expected    map[string]time.Time
+	attribution Attribution
+	clock       func() time.Time
+	filename    string
+	maxSize     int64
+	maxFiles    int
+	mutex       sync.Mutex
+}
+
+type Config struct {
+	Directory string
+	MaxSize   uint
+	MaxFiles  uint
+}
+
+func Flags(fs *flag.FlagSet, prefix string) *Config {
+	var config Config
+
+	flags.New("Directory", "Directory for storing the activity log, disabled if empty").Prefix(prefix).DocPrefix("activity").StringVar(fs, &config.Directory, "", nil)
+	flags.New("MaxSize", "Size in MB of the activity log before rotating it").Prefix(prefix).DocPrefix("activity").UintVar(fs, &config.MaxSize, 5, nil)
+	flags.New("MaxFiles", "Number of rotated activity logs kept").Prefix(prefix).DocPrefix("activity").UintVar(fs, &config.MaxFiles, 4, nil)
+
+	return &config
+}
+
+// New creates the activity service, it returns nil if no directory is configured
+func New(config *Config) (*Service, error) {
+	if len(config.Directory) == 0 {
+		return nil, nil
+	}
+
+	if config.MaxSize == 0 {
+		return nil, errors.New("max size of activity log must be positive")
+	}
+
+	if err := os.MkdirAll(config.Directory, 0o700); err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+
+	return &Service{
+		filename: filepath.Join(config.Directory, filename),
+		maxSize:  int64(config.MaxSize) << 20,
+		maxFiles: int(config.MaxFiles),
+		expected: make(map[string]time.Time),
+		clock:    time.Now,
+	}, nil
+}
+
+func (s *Service) Enabled() bool {
+	return s != nil
+}
+
+// Middleware sets the origin of the actions done by a request, it has to be placed after the authentication for knowing the user
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := Origin{
+			Source: UI,
+			IP:     query.GetIP(r),
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/api/v1/") {
+			origin.Source = API
+		}
+
+		if user, ok := auth.UserFromContext(r.Context()); ok {
+			origin.Actor = user.Login
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithOrigin(r.Context(), origin)))
+	})
+}
+
+// Record logs an action issued by the app, the resulting change coming from the event stream won't be logged as external
+func (s *Service) Record(ctx context.Context, kind Kind, target, name, state string, err error) {
+	if s == nil {
+		return
+	}
+
+	origin := OriginFromContext(ctx)
+
+	entry := Entry{
+		Source: origin.Source,
+		Actor:  origin.Actor,
+		IP:     origin.IP,
+		Kind:   kind,
+		Target: target,
+		Name:   name,
+		State:  state,
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry.Time = s.clock()
+	s.expected[expectedKey(kind, target)] = entry.Time.Add(expectation)
+
+	s.write(ctx, entry)
+}
+
+// Expect prevents the change of a target from being logged as external, when it results from an action recorded on another one
+func (s *Service) Expect(kind Kind, target string) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expected[expectedKey(kind, target)] = s.clock().Add(expectation)
+}
+
+// Attribute sets how the changes received from the event stream are attributed to the automations of the bridge managed by the app
+func (s *Service) Attribute(attribution Attribution) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.attribution = attribution
+}
+
+// RecordExternal logs a change received from the event stream, unless it results from an action recently issued by the app
+func (s *Service) RecordExternal(ctx context.Context, kind Kind, target, name, state string) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+
+	now := s.clock()
+
+	for key, expire := range s.expected {
+		if !now.Before(expire) {
+			delete(s.expected, key)
+		}
+	}
+
+	_, expected := s.expected[expectedKey(kind, target)]
+	attribution := s.attribution
+
+	s.mutex.Unlock()
+
+	if expected {
+		return
+	}
+
+	entry := Entry{
+		Time:   now,
+		Source: External,
+		Kind:   kind,
+		Target: target,
+		Name:   name,
+		State:  state,
+	}
+
+	// The attribution may call the bridge, it's done without holding the mutex
+	if attribution != nil {
+		if automation, ok := attribution(ctx, kind, target, now); ok {
+			entry.Source = Bridge
+			entry.Actor = automation
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.write(ctx, entry)
+}
+
+func expectedKey(kind Kind, target string) string {
+	return string(kind) + ":" + target
+}
+
+// write appends the entry to the log, an error only being logged for never failing the action itself. The mutex has to be held
+func (s *Service) write(ctx context.Context, entry Entry) {
+	if err := s.append(entry); err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "record activity", slog.String("target", entry.Target), slog.Any("error", err))
+	}
+}
+
+func (s *Service) append(entry Entry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+
+	if info, err := os.Stat(s.filename); err == nil && info.Size()+int64(len(content))+1 > s.maxSize {
+		if err = s.rotate(); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(s.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+
+	_, err = file.Write(append(content, '\n'))
+
+	return errors.Join(err, file.Close())
+}
+
+// rotate shifts the rotated logs, dropping the oldest one beyond the number kept
+func (s *Service) rotate() error {
+	if s.maxFiles == 0 {
+		return os.Remove(s.filename)
+	}
+
+	if err := os.Remove(rotatedName(s.filename, s.maxFiles)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	for i := s.maxFiles - 1; i > 0; i-- {
+		if err := os.Rename(rotatedName(s.filename, i), rotatedName(s.filename, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return os.Rename(s.filename, rotatedName(s.filename, 1))
+}
+
+func rotatedName(filename string, index int) string {
+	return fmt.Sprintf("%s.%d", filename, index)
+}
+
+// List returns the entries matching the filter, newest first
+func (s *Service) List(filter Filter) ([]Entry, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	files, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		for _, file := range files {
+			_ = file.Close()
+		}
+	}()
+
+	var output []Entry
+
+	for _, file := range files {
+		entries, err := readEntries(file)
+		if err != nil {
+			return nil, fmt.Errorf("read `%s`: %w", filepath.Base(file.Name()), err)
+		}
+
+		for _, entry := range slices.Backward(entries) {
+			if !filter.match(entry) {
+				continue
+			}
+
+			output = append(output, entry)
+
+			if filter.Limit > 0 && len(output) >= filter.Limit {
+				return output, nil
+			}
+		}
+	}
+
+	return output, nil
+}
+
+// open opens the log and its rotations, newest first, under the mutex only: an opened file is still read entirely if it's rotated in the meantime
+func (s *Service) open() ([]*os.File, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var output []*os.File
+
+	for i := 0; i <= s.maxFiles; i++ {
+		name := s.filename
+		if i > 0 {
+			name = rotatedName(s.filename, i)
+		}
+
+		file, err := os.Open(name)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			for _, opened := range output {
+				_ = opened.Close()
+			}
+
+			return nil, fmt.Errorf("open `%s`: %w", filepath.Base(name), err)
+		}
+
+		output = append(output, file)
+	}
+
+	return output, nil
+}
+
+func readEntries(file *os.File) ([]Entry, error) {
+	var output []Entry
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+
+		output = append(output, entry)
+	}
+
+	return output, scanner.Err()
+}
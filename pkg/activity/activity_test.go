@@ -0,0 +1,280 @@
+package activity
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T, now *time.Time) *Service {
+	t.Helper()
+
+	service, err := New(&Config{Directory: t.TempDir(), MaxSize: 1, MaxFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service.clock = func() time.Time { return *now }
+
+	return service
+}
+
+func states(entries []Entry) []string {
+	output := make([]string, len(entries))
+	for i, entry := range entries {
+		output[i] = string(entry.Source) + " " + entry.Target + " " + entry.State
+	}
+
+	return output
+}
+
+func TestRecordExternal(t *testing.T) {
+	origin := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	ui := WithOrigin(context.Background(), Origin{Source: UI, Actor: "bob", IP: "10.0.0.1"})
+
+	cases := map[string]struct {
+		run  func(context.Context, *Service, *time.Time)
+		want []string
+	}{
+		"external": {
+			func(ctx context.Context, service *Service, _ *time.Time) {
+				service.RecordExternal(ctx, Group, "living", "Living", "off")
+			},
+			[]string{"external living off"},
+		},
+		"issued": {
+			func(ctx context.Context, service *Service, now *time.Time) {
+				service.Record(ui, Group, "living", "Living", "off", nil)
+				*now = now.Add(time.Second)
+				service.RecordExternal(ctx, Group, "living", "Living", "off")
+			},
+			[]string{"ui living off"},
+		},
+		"expected": {
+			func(ctx context.Context, service *Service, _ *time.Time) {
+				service.Expect(Group, "house")
+				service.RecordExternal(ctx, Group, "house", "House", "off")
+			},
+			[]string{},
+		},
+		"other target": {
+			func(ctx context.Context, service *Service, now *time.Time) {
+				service.Record(ui, Group, "living", "Living", "off", nil)
+				*now = now.Add(time.Second)
+				service.RecordExternal(ctx, Sensor, "living", "Living", "disabled")
+			},
+			[]string{"external living disabled", "ui living off"},
+		},
+		"bridge automation": {
+			func(ctx context.Context, service *Service, _ *time.Time) {
+				service.Attribute(func(_ context.Context, kind Kind, target string, _ time.Time) (string, bool) {
+					return "schedule Wake up", kind == Group && target == "living"
+				})
+				service.RecordExternal(ctx, Group, "living", "Living", "on at 100%")
+				service.RecordExternal(ctx, Group, "kitchen", "Kitchen", "off")
+			},
+			[]string{"external kitchen off", "bridge living on at 100%"},
+		},
+		"later": {
+			func(ctx context.Context, service *Service, now *time.Time) {
+				service.Record(ctx, Group, "living", "Living", "off", nil)
+				*now = now.Add(expectation)
+				service.RecordExternal(ctx, Group, "living", "Living", "on at 100%")
+			},
+			[]string{"external living on at 100%", "automation living off"},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			now := origin
+			service := newTestService(t, &now)
+
+			tc.run(context.Background(), service, &now)
+
+			entries, err := service.List(Filter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := states(entries); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("RecordExternal() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	service := newTestService(t, &now)
+
+	service.Record(WithOrigin(context.Background(), Origin{Source: API, Actor: "bob", IP: "10.0.0.1"}), Group, "living", "Living", "on at 50%", errors.New("bridge unavailable"))
+
+	entries, err := service.List(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Entry{{
+		Time:   now,
+		Source: API,
+		Actor:  "bob",
+		IP:     "10.0.0.1",
+		Kind:   Group,
+		Target: "living",
+		Name:   "Living",
+		State:  "on at 50%",
+		Error:  "bridge unavailable",
+	}}
+
+	for i := range entries {
+		entries[i].Time = entries[i].Time.UTC()
+	}
+
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("Record() = %+v, want %+v", entries, want)
+	}
+}
+
+func TestList(t *testing.T) {
+	origin := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+
+	now := origin
+	service := newTestService(t, &now)
+	ctx := context.Background()
+	cron := WithOrigin(ctx, Origin{Source: Cron})
+
+	service.Record(cron, Sensor, "hall", "Hall", "disabled", nil)
+	now = now.Add(time.Minute)
+	service.RecordExternal(ctx, Group, "living", "Living room", "off")
+	now = now.Add(time.Minute)
+	service.Record(cron, Sensor, "hall", "Hall", "enabled", nil)
+
+	cases := map[string]struct {
+		filter Filter
+		want   []string
+	}{
+		"all": {
+			Filter{},
+			[]string{"cron hall enabled", "external living off", "cron hall disabled"},
+		},
+		"source": {
+			Filter{Source: External},
+			[]string{"external living off"},
+		},
+		"kind": {
+			Filter{Kind: Sensor},
+			[]string{"cron hall enabled", "cron hall disabled"},
+		},
+		"name": {
+			Filter{Target: "room"},
+			[]string{"external living off"},
+		},
+		"id": {
+			Filter{Target: "hall"},
+			[]string{"cron hall enabled", "cron hall disabled"},
+		},
+		"range": {
+			Filter{From: origin.Add(time.Second), To: origin.Add(time.Minute * 2)},
+			[]string{"cron hall enabled", "external living off"},
+		},
+		"limit": {
+			Filter{Limit: 1},
+			[]string{"cron hall enabled"},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			entries, err := service.List(tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := states(entries); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("List() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRotate(t *testing.T) {
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	service := newTestService(t, &now)
+	service.maxSize = 400
+
+	ctx := context.Background()
+
+	for range 12 {
+		service.RecordExternal(ctx, Group, "living", "Living", "off")
+	}
+
+	for i := 1; i <= service.maxFiles; i++ {
+		if _, err := os.Stat(rotatedName(service.filename, i)); err != nil {
+			t.Errorf("rotated file %d: %s", i, err)
+		}
+	}
+
+	if _, err := os.Stat(rotatedName(service.filename, service.maxFiles+1)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file beyond the ones kept: %v", err)
+	}
+
+	for _, name := range []string{service.filename, rotatedName(service.filename, 1)} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Size() > service.maxSize {
+			t.Errorf("size of %s = %d, want at most %d", name, info.Size(), service.maxSize)
+		}
+	}
+
+	entries, err := service.List(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) == 0 || len(entries) >= 12 {
+		t.Errorf("List() = %d entries, want the ones of the kept files only", len(entries))
+	}
+}
+
+func TestOpenRotated(t *testing.T) {
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	service := newTestService(t, &now)
+	service.maxSize = 100
+
+	ctx := context.Background()
+
+	service.RecordExternal(ctx, Group, "living", "Living", "off")
+
+	files, err := service.open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		for _, file := range files {
+			_ = file.Close()
+		}
+	}()
+
+	service.RecordExternal(ctx, Group, "kitchen", "Kitchen", "off")
+
+	if len(files) != 1 {
+		t.Fatalf("open() = %d files, want 1", len(files))
+	}
+
+	entries, err := readEntries(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := states(entries); !reflect.DeepEqual(got, []string{"external living off"}) {
+		t.Errorf("readEntries() = %v, want the entries before the rotation", got)
+	}
+}
@@ -0,0 +1,325 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/flags"
+	"github.com/ViBiOh/httputils/v4/pkg/httperror"
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+)
+
+const (
+	ModeBasic = "basic"
+	ModeProxy = "proxy"
+	ModeOIDC  = "oidc"
+
+	// Path is the prefix of the routes handling the login flow, reachable without being authenticated
+	Path = "/auth/"
+)
+
+type Role int
+
+const (
+	Viewer Role = iota + 1
+	Operator
+	Admin
+)
+
+var roleNames = map[Role]string{
+	Viewer:   "viewer",
+	Operator: "operator",
+	Admin:    "admin",
+}
+
+func ParseRole(value string) (Role, error) {
+	for role, name := range roleNames {
+		if strings.EqualFold(name, strings.TrimSpace(value)) {
+			return role, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown role `%s`", value)
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+type User struct {
+	Scope *Scope `json:"scope,omitempty"`
+	Login string `json:"login"`
+	Role  Role   `json:"role"`
+}
+
+// Can checks if the user has at least the given role
+func (u User) Can(role Role) bool {
+	return u.Role >= role
+}
+
+type ctxKey struct{}
+
+func WithUser(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, ctxKey{}, user)
+}
+
+func UserFromContext(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(ctxKey{}).(User)
+	return user, ok
+}
+
+// errNoCredentials is returned by a provider when the request doesn't carry any credentials, so the client is challenged
+var errNoCredentials = errors.New("no credentials")
+
+type provider interface {
+	authenticate(r *http.Request) (User, error)
+	challenge(w http.ResponseWriter, r *http.Request)
+}
+
+type Service struct {
+	provider provider
+	oidc     *oidcProvider
+	tokens   *tokenStore
+	signer   signer
+}
+
+type Config struct {
+	Mode             string
+	Credentials      string
+	ProxyHeader      string
+	ProxyTrusted     []string
+	Roles            []string
+	DefaultRole      string
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	Secret           string
+	Tokens           string
+	SessionDuration  time.Duration
+}
+
+func Flags(fs *flag.FlagSet, prefix string) *Config {
+	var config Config
+
+	flags.New("Mode", "Authentication mode: empty for none, basic, proxy or oidc").Prefix(prefix).DocPrefix("auth").StringVar(fs, &config.Mode, "", nil)
+	flags.New("Credentials", "Basic credentials filename, one login:role:bcrypt hash per line").Prefix(prefix).DocPrefix("auth").StringVar(fs, &config.Credentials, "", nil)
+	flags.New("ProxyHeader", "Header containing the login set by the trusted proxy").Prefix(prefix).DocPrefix("auth").StringVar(fs, &config.ProxyHeader, "X-Forwarded-User", nil)
+	flags.New("ProxyTrusted", "CIDRs of the trusted proxies").Prefix(prefix).DocPrefix("auth").StringSliceVar(fs, &config.ProxyTrusted, []string{"127.0.0.1/32", "::1/128"}, nil)
+	flags.New("Roles", "Roles of proxy or OIDC users, in the login:role form").Prefix(prefix).DocPrefix("auth").StringSliceVar(fs, &config.Roles, nil, nil)
+	flags.New("DefaultRole", "Role of proxy or OIDC users not listed in roles, empty for denying them").Prefix(prefix).DocPrefix("auth").StringVar(fs, &config.DefaultRole, "viewer", nil)
+	flags.New("OidcIssuer", "OIDC issuer URL").Prefix(prefix).DocPrefix("auth").StringVar(fs, &config.OIDCIssuer, "", nil)
+	flags.New("OidcClientID", "OIDC client ID").Prefix(prefix).DocPrefix("auth").StringVar(fs, &config.OIDCClientID, "", nil)
+	flags.New("OidcClientSecret", "OIDC client secret").Prefix(prefix).DocPrefix("auth").StringVar(fs, &config.OIDCClientSecret, "", nil)
+	flags.New("OidcRedirectURL", "OIDC redirect URL, ending with /auth/callback").Prefix(prefix).DocPrefix("auth").StringVar(fs, &config.OIDCRedirectURL, "", nil)
+	flags.New("Secret", "Secret for signing sessions and access tokens, random if empty").Prefix(prefix).DocPrefix("auth").StringVar(fs, &config.Secret, "", nil)
+	flags.New("Tokens", "Filename for storing access tokens, in memory if empty").Prefix(prefix).DocPrefix("auth").StringVar(fs, &config.Tokens, "", nil)
+	flags.New("SessionDuration", "Duration of a session").Prefix(prefix).DocPrefix("auth").DurationVar(fs, &config.SessionDuration, time.Hour*12, nil)
+
+	return &config
+}
+
+// New creates the authentication service, nil when no mode is configured, every request being then allowed
+func New(ctx context.Context, config *Config) (*Service, error) {
+	if len(config.Mode) == 0 {
+		return nil, nil
+	}
+
+	secret := []byte(config.Secret)
+	if len(secret) == 0 {
+		slog.Warn("No secret configured, sessions and access tokens won't survive a restart")
+		secret = []byte(rand.Text())
+	}
+
+	tokens, err := newTokenStore(config.Tokens)
+	if err != nil {
+		return nil, fmt.Errorf("tokens: %w", err)
+	}
+
+	service := Service{
+		signer: signer{purpose: tokenCookie, secret: secret},
+		tokens: tokens,
+	}
+
+	switch config.Mode {
+	case ModeBasic:
+		basic, err := newBasicProvider(config.Credentials)
+		if err != nil {
+			return nil, fmt.Errorf("basic: %w", err)
+		}
+
+		service.provider = basic
+
+	case ModeProxy:
+		roles, err := newRoleMapping(config.Roles, config.DefaultRole)
+		if err != nil {
+			return nil, fmt.Errorf("roles: %w", err)
+		}
+
+		proxy, err := newProxyProvider(config.ProxyHeader, config.ProxyTrusted, roles)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %w", err)
+		}
+
+		service.provider = proxy
+
+	case ModeOIDC:
+		roles, err := newRoleMapping(config.Roles, config.DefaultRole)
+		if err != nil {
+			return nil, fmt.Errorf("roles: %w", err)
+		}
+
+		oidc, err := newOIDCProvider(ctx, config, service.signer, roles)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: %w", err)
+		}
+
+		service.provider = oidc
+		service.oidc = oidc
+
+	default:
+		return nil, fmt.Errorf("unknown mode `%s`", config.Mode)
+	}
+
+	return &service, nil
+}
+
+func (s *Service) Enabled() bool {
+	return s != nil
+}
+
+// LoginEnabled checks if the login routes have to be registered
+func (s *Service) LoginEnabled() bool {
+	return s != nil && s.oidc != nil
+}
+
+// Middleware authenticates the request and puts the user in its context, challenging the client if it has no credentials
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.oidc != nil && strings.HasPrefix(r.URL.Path, Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+
+		if value, fromQuery := tokenValue(r); len(value) != 0 {
+			user, token, err := s.authenticateToken(value)
+			if err != nil {
+				httperror.Log(ctx, err, http.StatusUnauthorized, "unauthorized")
+				httperror.Unauthorized(ctx, w, errors.New("invalid access token"))
+				return
+			}
+
+			if fromQuery && r.Method == http.MethodGet {
+				openTokenSession(w, r, value, token)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithUser(ctx, user)))
+			return
+		}
+
+		// A session or credentials of the provider take precedence over the cookie of an access token, which may be stale
+		user, err := s.provider.authenticate(r)
+		if errors.Is(err, errNoCredentials) {
+			user, err = s.authenticateCookie(w, r)
+		}
+
+		if err != nil {
+			switch {
+			case errors.Is(err, errNoCredentials):
+				s.provider.challenge(w, r)
+			case errors.Is(err, model.ErrForbidden):
+				httperror.Log(ctx, err, http.StatusForbidden, "forbidden")
+				httperror.Forbidden(ctx, w)
+			default:
+				httperror.Log(ctx, err, http.StatusUnauthorized, "unauthorized")
+				httperror.Unauthorized(ctx, w, errors.New("invalid credentials"))
+			}
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithUser(ctx, user)))
+	})
+}
+
+// Check verifies that the user of the context has at least the given role. Everything is allowed when the service is disabled
+func (s *Service) Check(ctx context.Context, role Role) error {
+	if s == nil {
+		return nil
+	}
+
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return model.WrapUnauthorized(errors.New("not authenticated"))
+	}
+
+	if !user.Can(role) {
+		return model.WrapForbidden(fmt.Errorf("`%s` is %s, %s is required", user.Login, user.Role, role))
+	}
+
+	return nil
+}
+
+// roleMapping gives the role of users authenticated by a third party
+type roleMapping struct {
+	roles       map[string]Role
+	defaultRole Role
+}
+
+func newRoleMapping(values []string, defaultRole string) (roleMapping, error) {
+	output := roleMapping{
+		roles: make(map[string]Role, len(values)),
+	}
+
+	for _, value := range values {
+		login, roleName, ok := strings.Cut(value, ":")
+		if !ok {
+			return output, fmt.Errorf("invalid role `%s`, expected `login:role`", value)
+		}
+
+		role, err := ParseRole(roleName)
+		if err != nil {
+			return output, err
+		}
+
+		output.roles[strings.ToLower(strings.TrimSpace(login))] = role
+	}
+
+	if len(defaultRole) != 0 {
+		role, err := ParseRole(defaultRole)
+		if err != nil {
+			return output, fmt.Errorf("default: %w", err)
+		}
+
+		output.defaultRole = role
+	}
+
+	return output, nil
+}
+
+func (m roleMapping) user(login string) (User, error) {
+	role, ok := m.roles[strings.ToLower(login)]
+	if !ok {
+		role = m.defaultRole
+	}
+
+	if role == 0 {
+		return User{}, model.WrapForbidden(fmt.Errorf("`%s` has no role", login))
+	}
+
+	return User{Login: login, Role: role}, nil
+}
@@ -0,0 +1,397 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheck(t *testing.T) {
+	cases := map[string]struct {
+		instance *Service
+		ctx      context.Context
+		role     Role
+		wantErr  error
+	}{
+		"disabled": {
+			instance: nil,
+			ctx:      context.Background(),
+			role:     Admin,
+		},
+		"anonymous": {
+			instance: &Service{},
+			ctx:      context.Background(),
+			role:     Viewer,
+			wantErr:  model.ErrUnauthorized,
+		},
+		"higher role": {
+			instance: &Service{},
+			ctx:      WithUser(context.Background(), User{Login: "admin", Role: Admin}),
+			role:     Operator,
+		},
+		"lower role": {
+			instance: &Service{},
+			ctx:      WithUser(context.Background(), User{Login: "viewer", Role: Viewer}),
+			role:     Operator,
+			wantErr:  model.ErrForbidden,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if gotErr := tc.instance.Check(tc.ctx, tc.role); !errors.Is(gotErr, tc.wantErr) {
+				t.Errorf("Check() = %v, want %v", gotErr, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials, err := parseCredentials(bufio.NewScanner(strings.NewReader("# comment\nalice:operator:" + string(hash) + "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles, err := newRoleMapping([]string{"bob:admin"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy, err := newProxyProvider("X-Forwarded-User", []string{"10.0.0.0/8"}, roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := newTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basic := &Service{provider: &basicProvider{credentials: credentials, verified: make(map[string][32]byte)}, signer: signer{purpose: tokenCookie, secret: []byte("secret")}, tokens: store}
+	proxied := &Service{provider: proxy}
+
+	token, err := basic.CreateToken("Guest", Viewer, Scope{Groups: []string{"room"}}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := basic.CreateToken("Former", Viewer, Scope{Groups: []string{"room"}}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = basic.RevokeToken(revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		instance    *Service
+		setup       func(*http.Request)
+		want        int
+		wantLogin   string
+		wantHeader  string
+		wantExpired bool
+	}{
+		"basic challenge": {
+			instance:   basic,
+			setup:      func(*http.Request) {},
+			want:       http.StatusUnauthorized,
+			wantHeader: `Basic realm="hue", charset="UTF-8"`,
+		},
+		"basic valid": {
+			instance:  basic,
+			setup:     func(r *http.Request) { r.SetBasicAuth("alice", "secret") },
+			want:      http.StatusOK,
+			wantLogin: "alice",
+		},
+		"basic invalid password": {
+			instance: basic,
+			setup:    func(r *http.Request) { r.SetBasicAuth("alice", "guess") },
+			want:     http.StatusUnauthorized,
+		},
+		"basic unknown user": {
+			instance: basic,
+			setup:    func(r *http.Request) { r.SetBasicAuth("mallory", "secret") },
+			want:     http.StatusUnauthorized,
+		},
+		"token cookie": {
+			instance:  basic,
+			setup:     func(r *http.Request) { r.AddCookie(&http.Cookie{Name: tokenCookie, Value: token.Value}) },
+			want:      http.StatusOK,
+			wantLogin: "token:Guest",
+		},
+		"credentials over token cookie": {
+			instance: basic,
+			setup: func(r *http.Request) {
+				r.SetBasicAuth("alice", "secret")
+				r.AddCookie(&http.Cookie{Name: tokenCookie, Value: revoked.Value})
+			},
+			want:      http.StatusOK,
+			wantLogin: "alice",
+		},
+		"revoked token cookie": {
+			instance:    basic,
+			setup:       func(r *http.Request) { r.AddCookie(&http.Cookie{Name: tokenCookie, Value: revoked.Value}) },
+			want:        http.StatusUnauthorized,
+			wantHeader:  `Basic realm="hue", charset="UTF-8"`,
+			wantExpired: true,
+		},
+		"proxy trusted": {
+			instance: proxied,
+			setup: func(r *http.Request) {
+				r.RemoteAddr = "10.0.0.1:1234"
+				r.Header.Set("X-Forwarded-User", "bob")
+			},
+			want:      http.StatusOK,
+			wantLogin: "bob",
+		},
+		"proxy untrusted": {
+			instance: proxied,
+			setup: func(r *http.Request) {
+				r.RemoteAddr = "192.168.1.1:1234"
+				r.Header.Set("X-Forwarded-User", "bob")
+			},
+			want: http.StatusUnauthorized,
+		},
+		"proxy without role": {
+			instance: proxied,
+			setup: func(r *http.Request) {
+				r.RemoteAddr = "10.0.0.1:1234"
+				r.Header.Set("X-Forwarded-User", "eve")
+			},
+			want: http.StatusForbidden,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var gotLogin string
+
+			handler := tc.instance.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				user, _ := UserFromContext(r.Context())
+				gotLogin = user.Login
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tc.setup(req)
+
+			writer := httptest.NewRecorder()
+			handler.ServeHTTP(writer, req)
+
+			if got := writer.Code; got != tc.want {
+				t.Errorf("Middleware() = %d, want %d", got, tc.want)
+			}
+
+			if gotLogin != tc.wantLogin {
+				t.Errorf("Middleware() login = `%s`, want `%s`", gotLogin, tc.wantLogin)
+			}
+
+			if got := writer.Header().Get("WWW-Authenticate"); got != tc.wantHeader {
+				t.Errorf("Middleware() WWW-Authenticate = `%s`, want `%s`", got, tc.wantHeader)
+			}
+
+			expired := slices.ContainsFunc(writer.Result().Cookies(), func(cookie *http.Cookie) bool {
+				return cookie.Name == tokenCookie && cookie.MaxAge < 0
+			})
+			if expired != tc.wantExpired {
+				t.Errorf("Middleware() expired cookie = %t, want %t", expired, tc.wantExpired)
+			}
+		})
+	}
+}
+
+func TestSigner(t *testing.T) {
+	instance := signer{secret: []byte("secret")}
+
+	value, err := instance.sign(session{User: User{Login: "alice", Role: Operator}, Expire: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		value   string
+		want    session
+		wantErr error
+	}{
+		"valid": {
+			value: value,
+			want:  session{User: User{Login: "alice", Role: Operator}, Expire: 42},
+		},
+		"tampered": {
+			value:   "eyJsb2dpbiI6ImV2ZSJ9" + value[strings.Index(value, "."):],
+			wantErr: errInvalidSignature,
+		},
+		"unsigned": {
+			value:   "eyJsb2dpbiI6ImV2ZSJ9",
+			wantErr: errInvalidSignature,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var got session
+
+			gotErr := instance.verify(tc.value, &got)
+			if !errors.Is(gotErr, tc.wantErr) {
+				t.Fatalf("verify() error = %v, want %v", gotErr, tc.wantErr)
+			}
+
+			if got != tc.want {
+				t.Errorf("verify() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	cases := map[string]struct {
+		value string
+		want  string
+	}{
+		"local":         {value: "/devices?range=day", want: "/devices?range=day"},
+		"empty":         {value: "", want: "/"},
+		"absolute":      {value: "https://example.com", want: "/"},
+		"protocol less": {value: "//example.com", want: "/"},
+		"backslash":     {value: "/\\example.com", want: "/"},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := safeRedirect(tc.value); got != tc.want {
+				t.Errorf("safeRedirect() = `%s`, want `%s`", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestToken(t *testing.T) {
+	store, err := newTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance := &Service{signer: signer{purpose: tokenCookie, secret: []byte("secret")}, tokens: store}
+
+	token, err := instance.CreateToken("Guest", Operator, Scope{Groups: []string{"room"}}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := instance.CreateToken("Former", Viewer, Scope{Sensors: []string{"hall"}}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = instance.RevokeToken(revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := instance.signer.withPurpose(sessionCookie).sign(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := instance.signer.sign(Token{ID: token.ID, Role: Operator, Expire: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		value   string
+		want    User
+		wantErr bool
+	}{
+		"valid": {
+			value: token.Value,
+			want:  User{Login: "token:Guest", Role: Operator, Scope: &Scope{Groups: []string{"room"}}},
+		},
+		"other purpose": {
+			value:   session,
+			wantErr: true,
+		},
+		"expired": {
+			value:   expired,
+			wantErr: true,
+		},
+		"revoked": {
+			value:   revoked.Value,
+			wantErr: true,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			got, _, gotErr := instance.authenticateToken(tc.value)
+			if (gotErr != nil) != tc.wantErr {
+				t.Fatalf("authenticateToken() error = %v, want error %t", gotErr, tc.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("authenticateToken() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+
+	reloaded, err := newTokenStore(store.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reloaded.has(token.ID) || reloaded.has(revoked.ID) {
+		t.Errorf("reloaded tokens = %+v, want only `%s`", reloaded.tokens, token.ID)
+	}
+}
+
+func TestBasicAuthenticateError(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = bcrypt.Cost(unknownUserHash); err != nil {
+		t.Fatalf("unknownUserHash isn't a bcrypt hash: %s", err)
+	}
+
+	provider := &basicProvider{credentials: map[string]credential{"alice": {hash: hash, role: Operator}}, verified: make(map[string][32]byte)}
+
+	cases := map[string]struct {
+		login    string
+		password string
+	}{
+		"invalid password": {
+			login:    "alice",
+			password: "guess",
+		},
+		"unknown user": {
+			login:    "mallory",
+			password: "secret",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.SetBasicAuth(tc.login, tc.password)
+
+			if _, err := provider.authenticate(req); err != errInvalidCredentials {
+				t.Errorf("authenticate() = %v, want %v", err, errInvalidCredentials)
+			}
+		})
+	}
+}
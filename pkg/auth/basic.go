@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	errInvalidCredentials = errors.New("invalid credentials")
+
+	// unknownUserHash is compared to the password of an unknown login, for answering as slowly as for a known one
+	unknownUserHash = []byte("$2a$10$EWGJ/tyJVE5nQY.hKAGH2O69EP/NnsHVr8eQ9Fa2A7A02sOxwQf5O")
+)
+
+type credential struct {
+	hash []byte
+	role Role
+}
+
+type basicProvider struct {
+	credentials map[string]credential
+	// verified keeps a digest of the last password matching the bcrypt hash, bcrypt being too slow for every request
+	verified map[string][sha256.Size]byte
+	mutex    sync.RWMutex
+}
+
+func newBasicProvider(filename string) (*basicProvider, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open credentials: %w", err)
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	credentials, err := parseCredentials(bufio.NewScanner(file))
+	if err != nil {
+		return nil, fmt.Errorf("parse `%s`: %w", filename, err)
+	}
+
+	return &basicProvider{
+		credentials: credentials,
+		verified:    make(map[string][sha256.Size]byte),
+	}, nil
+}
+
+func parseCredentials(scanner *bufio.Scanner) (map[string]credential, error) {
+	output := make(map[string]credential)
+
+	for line := 1; scanner.Scan(); line++ {
+		value := strings.TrimSpace(scanner.Text())
+		if len(value) == 0 || strings.HasPrefix(value, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(value, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("line %d: expected `login:role:hash`", line)
+		}
+
+		role, err := ParseRole(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if _, err := bcrypt.Cost([]byte(parts[2])); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		output[parts[0]] = credential{
+			hash: []byte(parts[2]),
+			role: role,
+		}
+	}
+
+	return output, scanner.Err()
+}
+
+func (p *basicProvider) authenticate(r *http.Request) (User, error) {
+	login, password, ok := r.BasicAuth()
+	if !ok {
+		return User{}, errNoCredentials
+	}
+
+	credential, ok := p.credentials[login]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(unknownUserHash, []byte(password))
+
+		return User{}, errInvalidCredentials
+	}
+
+	digest := sha256.Sum256([]byte(password))
+
+	p.mutex.RLock()
+	verified, ok := p.verified[login]
+	p.mutex.RUnlock()
+
+	if !ok || subtle.ConstantTimeCompare(verified[:], digest[:]) != 1 {
+		if err := bcrypt.CompareHashAndPassword(credential.hash, []byte(password)); err != nil {
+			return User{}, errInvalidCredentials
+		}
+
+		p.mutex.Lock()
+		p.verified[login] = digest
+		p.mutex.Unlock()
+	}
+
+	return User{Login: login, Role: credential.role}, nil
+}
+
+func (p *basicProvider) challenge(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="hue", charset="UTF-8"`)
+	http.Error(w, "authentication required", http.StatusUnauthorized)
+}
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/httperror"
+	"github.com/ViBiOh/hue/pkg/csrf"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	sessionCookie = "hue_session"
+	loginCookie   = "hue_login"
+	loginDuration = time.Minute * 10
+)
+
+type loginState struct {
+	State    string `json:"state"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+	Expire   int64  `json:"exp"`
+}
+
+type oidcProvider struct {
+	verifier        *oidc.IDTokenVerifier
+	oauth2          oauth2.Config
+	signer          signer
+	loginSigner     signer
+	roles           roleMapping
+	sessionDuration time.Duration
+	secure          bool
+}
+
+func newOIDCProvider(ctx context.Context, config *Config, signer signer, roles roleMapping) (*oidcProvider, error) {
+	if len(config.OIDCIssuer) == 0 || len(config.OIDCClientID) == 0 || len(config.OIDCRedirectURL) == 0 {
+		return nil, errors.New("issuer, client ID and redirect URL are required")
+	}
+
+	provider, err := oidc.NewProvider(ctx, config.OIDCIssuer)
+	if err != nil {
+		return nil, fmt.Errorf("discover `%s`: %w", config.OIDCIssuer, err)
+	}
+
+	return &oidcProvider{
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.OIDCClientID}),
+		oauth2: oauth2.Config{
+			ClientID:     config.OIDCClientID,
+			ClientSecret: config.OIDCClientSecret,
+			RedirectURL:  config.OIDCRedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+		signer:          signer.withPurpose(sessionCookie),
+		loginSigner:     signer.withPurpose(loginCookie),
+		roles:           roles,
+		sessionDuration: config.SessionDuration,
+		secure:          strings.HasPrefix(config.OIDCRedirectURL, "https://"),
+	}, nil
+}
+
+func (p *oidcProvider) authenticate(r *http.Request) (User, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return User{}, errNoCredentials
+	}
+
+	var content session
+	if err = p.signer.verify(cookie.Value, &content); err != nil {
+		return User{}, fmt.Errorf("verify session: %w", err)
+	}
+
+	if content.expired(time.Now()) || content.Role == 0 {
+		return User{}, errNoCredentials
+	}
+
+	return content.User, nil
+}
+
+// challenge redirects browsers to the login page, API clients receive an unauthorized status instead
+func (p *oidcProvider) challenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	http.Redirect(w, r, Path+"login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+}
+
+// HandleLogin starts the authorization code flow with the identity provider
+func (s *Service) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	p := s.oidc
+
+	state := loginState{
+		State:    rand.Text(),
+		Verifier: oauth2.GenerateVerifier(),
+		Redirect: safeRedirect(r.URL.Query().Get("redirect")),
+		Expire:   time.Now().Add(loginDuration).Unix(),
+	}
+
+	value, err := p.loginSigner.sign(state)
+	if err != nil {
+		httperror.InternalServerError(r.Context(), w, fmt.Errorf("sign login: %w", err))
+		return
+	}
+
+	setCookie(w, loginCookie, Path, value, loginDuration, p.secure)
+
+	http.Redirect(w, r, p.oauth2.AuthCodeURL(state.State, oauth2.S256ChallengeOption(state.Verifier)), http.StatusFound)
+}
+
+// HandleCallback exchanges the authorization code and opens a session for the user
+func (s *Service) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	p := s.oidc
+	ctx := r.Context()
+
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil {
+		httperror.BadRequest(ctx, w, errors.New("no login in progress"))
+		return
+	}
+
+	clearCookie(w, loginCookie, Path, p.secure)
+
+	var state loginState
+	if err = p.loginSigner.verify(cookie.Value, &state); err != nil || time.Now().Unix() >= state.Expire || state.State != r.URL.Query().Get("state") {
+		httperror.BadRequest(ctx, w, errors.New("invalid login state"))
+		return
+	}
+
+	if message := r.URL.Query().Get("error"); len(message) != 0 {
+		httperror.Unauthorized(ctx, w, fmt.Errorf("identity provider: %s", message))
+		return
+	}
+
+	user, err := p.exchange(ctx, r.URL.Query().Get("code"), state.Verifier)
+	if err != nil {
+		httperror.Log(ctx, err, http.StatusUnauthorized, "oidc callback")
+		httperror.Unauthorized(ctx, w, errors.New("authentication failed"))
+		return
+	}
+
+	value, err := p.signer.sign(session{
+		User:   user,
+		Expire: time.Now().Add(p.sessionDuration).Unix(),
+	})
+	if err != nil {
+		httperror.InternalServerError(ctx, w, fmt.Errorf("sign session: %w", err))
+		return
+	}
+
+	setCookie(w, sessionCookie, "/", value, p.sessionDuration, p.secure)
+
+	http.Redirect(w, r, state.Redirect, http.StatusFound)
+}
+
+// HandleLogout closes the session
+func (s *Service) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if err := csrf.Verify(r); err != nil {
+		httperror.Forbidden(r.Context(), w)
+		return
+	}
+
+	clearCookie(w, sessionCookie, "/", s.oidc.secure)
+	clearCookie(w, tokenCookie, "/", s.oidc.secure)
+
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (p *oidcProvider) exchange(ctx context.Context, code, verifier string) (User, error) {
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return User{}, fmt.Errorf("exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return User{}, errors.New("no id_token in response")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return User{}, fmt.Errorf("verify id_token: %w", err)
+	}
+
+	var claims struct {
+		Email             string `json:"email"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+
+	if err = idToken.Claims(&claims); err != nil {
+		return User{}, fmt.Errorf("parse claims: %w", err)
+	}
+
+	login := claims.Email
+	if len(login) == 0 {
+		login = claims.PreferredUsername
+	}
+
+	if len(login) == 0 {
+		login = idToken.Subject
+	}
+
+	return p.roles.user(login)
+}
+
+// safeRedirect only allows redirecting to a local path
+func safeRedirect(value string) string {
+	if !strings.HasPrefix(value, "/") || strings.HasPrefix(value, "//") || strings.HasPrefix(value, "/\\") {
+		return "/"
+	}
+
+	return value
+}
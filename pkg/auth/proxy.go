@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type proxyProvider struct {
+	header  string
+	trusted []netip.Prefix
+	roles   roleMapping
+}
+
+func newProxyProvider(header string, trusted []string, roles roleMapping) (*proxyProvider, error) {
+	if len(header) == 0 {
+		return nil, fmt.Errorf("no header configured")
+	}
+
+	output := proxyProvider{
+		header: header,
+		roles:  roles,
+	}
+
+	for _, value := range trusted {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("parse trusted `%s`: %w", value, err)
+		}
+
+		output.trusted = append(output.trusted, prefix)
+	}
+
+	return &output, nil
+}
+
+func (p *proxyProvider) authenticate(r *http.Request) (User, error) {
+	if !p.isTrusted(r.RemoteAddr) {
+		return User{}, fmt.Errorf("untrusted proxy `%s`", r.RemoteAddr)
+	}
+
+	login := strings.TrimSpace(r.Header.Get(p.header))
+	if len(login) == 0 {
+		return User{}, errNoCredentials
+	}
+
+	return p.roles.user(login)
+}
+
+func (p *proxyProvider) isTrusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	for _, prefix := range p.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *proxyProvider) challenge(w http.ResponseWriter, _ *http.Request) {
+	http.Error(w, "authentication required", http.StatusUnauthorized)
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var errInvalidSignature = errors.New("invalid signature")
+
+// signer produces values that can't be forged without the secret. The purpose prevents a value signed for something to be used
+// for something else
+type signer struct {
+	purpose string
+	secret  []byte
+}
+
+func (s signer) withPurpose(purpose string) signer {
+	return signer{purpose: purpose, secret: s.secret}
+}
+
+func (s signer) sign(payload any) (string, error) {
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("marshal: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(content)
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+func (s signer) verify(value string, output any) error {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return errInvalidSignature
+	}
+
+	rawSignature, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(rawSignature, s.mac(encoded)) {
+		return errInvalidSignature
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	if err = json.Unmarshal(content, output); err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+
+	return nil
+}
+
+func (s signer) mac(value string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(s.purpose))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+
+	return mac.Sum(nil)
+}
+
+type session struct {
+	User
+	Expire int64 `json:"exp"`
+}
+
+func (s session) expired(now time.Time) bool {
+	return now.Unix() >= s.Expire
+}
+
+func setCookie(w http.ResponseWriter, name, path, value string, duration time.Duration, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   int(duration.Seconds()),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearCookie(w http.ResponseWriter, name, path string, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Path:     path,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
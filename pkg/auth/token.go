@@ -0,0 +1,342 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+)
+
+const (
+	// TokenParam is the query parameter for opening a browser session with an access token
+	TokenParam = "token"
+
+	tokenCookie = "hue_token"
+	tokenPrefix = "token:"
+)
+
+// Scope restricts the groups and sensors a user can see and control
+type Scope struct {
+	Groups  []string `json:"groups"`
+	Sensors []string `json:"sensors"`
+}
+
+// HasGroup checks if the group is in scope, every group being when there is no scope
+func (s *Scope) HasGroup(id string) bool {
+	return s == nil || slices.Contains(s.Groups, id)
+}
+
+// HasSensor checks if the sensor is in scope, every sensor being when there is no scope
+func (s *Scope) HasSensor(id string) bool {
+	return s == nil || slices.Contains(s.Sensors, id)
+}
+
+// ScopeFromContext returns the scope of the user of the context, nil if unrestricted
+func ScopeFromContext(ctx context.Context) *Scope {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	return user.Scope
+}
+
+// Token grants a role on a limited set of groups and sensors until it expires or is revoked
+type Token struct {
+	Created time.Time `json:"created"`
+	Expire  time.Time `json:"expire"`
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Value   string    `json:"-"`
+	Scope
+	Role Role `json:"role"`
+}
+
+func (t Token) Expired(now time.Time) bool {
+	return !now.Before(t.Expire)
+}
+
+type tokenStore struct {
+	tokens   map[string]Token
+	filename string
+	mutex    sync.RWMutex
+}
+
+func newTokenStore(filename string) (*tokenStore, error) {
+	store := tokenStore{
+		filename: filename,
+		tokens:   make(map[string]Token),
+	}
+
+	if len(filename) == 0 {
+		slog.Warn("No file configured for storing access tokens, they won't survive a restart")
+		return &store, nil
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &store, nil
+		}
+
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	var tokens []Token
+	if err = json.Unmarshal(content, &tokens); err != nil {
+		return nil, fmt.Errorf("unmarshal `%s`: %w", filename, err)
+	}
+
+	for _, token := range tokens {
+		store.tokens[token.ID] = token
+	}
+
+	return &store, nil
+}
+
+func (s *tokenStore) has(id string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, ok := s.tokens[id]
+
+	return ok
+}
+
+func (s *tokenStore) list(now time.Time) []Token {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	output := make([]Token, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		if !token.Expired(now) {
+			output = append(output, token)
+		}
+	}
+
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].Created.Before(output[j].Created)
+	})
+
+	return output
+}
+
+func (s *tokenStore) add(token Token) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for id, item := range s.tokens {
+		if item.Expired(now) {
+			delete(s.tokens, id)
+		}
+	}
+
+	s.tokens[token.ID] = token
+
+	return s.save()
+}
+
+func (s *tokenStore) remove(id string) (Token, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token, ok := s.tokens[id]
+	if !ok {
+		return token, model.WrapNotFound(fmt.Errorf("unknown token `%s`", id))
+	}
+
+	delete(s.tokens, id)
+
+	return token, s.save()
+}
+
+// save writes the tokens to the file, through a temporary one for never leaving it half written. The mutex has to be held
+func (s *tokenStore) save() error {
+	if len(s.filename) == 0 {
+		return nil
+	}
+
+	tokens := make([]Token, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		tokens = append(tokens, token)
+	}
+
+	content, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(s.filename), filepath.Base(s.filename)+".*")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+
+	if _, err = file.Write(content); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+
+		return fmt.Errorf("write: %w", err)
+	}
+
+	if err = file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+
+		return fmt.Errorf("close: %w", err)
+	}
+
+	return os.Rename(file.Name(), s.filename)
+}
+
+// Tokens lists the access tokens that aren't expired, with their value
+func (s *Service) Tokens() []Token {
+	tokens := s.tokens.list(time.Now())
+
+	for i, token := range tokens {
+		value, err := s.signer.sign(token)
+		if err != nil {
+			slog.Error("sign token", slog.String("id", token.ID), slog.Any("error", err))
+			continue
+		}
+
+		tokens[i].Value = value
+	}
+
+	return tokens
+}
+
+// CreateToken issues an access token restricted to the given groups and sensors
+func (s *Service) CreateToken(name string, role Role, scope Scope, duration time.Duration) (Token, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return Token{}, model.WrapInvalid(errors.New("name is required"))
+	}
+
+	if role != Viewer && role != Operator {
+		return Token{}, model.WrapInvalid(fmt.Errorf("role of a token must be %s or %s", Viewer, Operator))
+	}
+
+	if duration <= 0 {
+		return Token{}, model.WrapInvalid(fmt.Errorf("invalid duration `%s`", duration))
+	}
+
+	if len(scope.Groups) == 0 && len(scope.Sensors) == 0 {
+		return Token{}, model.WrapInvalid(errors.New("at least a group or a sensor is required"))
+	}
+
+	now := time.Now().Truncate(time.Second)
+
+	token := Token{
+		ID:      rand.Text(),
+		Name:    name,
+		Role:    role,
+		Scope:   scope,
+		Created: now,
+		Expire:  now.Add(duration),
+	}
+
+	if err := s.tokens.add(token); err != nil {
+		return token, fmt.Errorf("store token: %w", err)
+	}
+
+	value, err := s.signer.sign(token)
+	if err != nil {
+		return token, fmt.Errorf("sign token: %w", err)
+	}
+
+	token.Value = value
+
+	return token, nil
+}
+
+// RevokeToken invalidates the access token immediately
+func (s *Service) RevokeToken(id string) (Token, error) {
+	return s.tokens.remove(id)
+}
+
+// tokenValue returns the access token sent with the request, from the header or the query
+func tokenValue(r *http.Request) (value string, fromQuery bool) {
+	if value, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(value), false
+	}
+
+	if value := r.URL.Query().Get(TokenParam); len(value) != 0 {
+		return value, true
+	}
+
+	return "", false
+}
+
+// authenticateCookie authenticates the request with the access token kept in its cookie, expiring the cookie once the token is no longer valid
+func (s *Service) authenticateCookie(w http.ResponseWriter, r *http.Request) (User, error) {
+	cookie, err := r.Cookie(tokenCookie)
+	if err != nil {
+		return User{}, errNoCredentials
+	}
+
+	user, _, err := s.authenticateToken(cookie.Value)
+	if err != nil {
+		slog.LogAttrs(r.Context(), slog.LevelInfo, "token cookie expired", slog.Any("error", err))
+		clearCookie(w, tokenCookie, "/", secureRequest(r))
+
+		return User{}, errNoCredentials
+	}
+
+	return user, nil
+}
+
+func (s *Service) authenticateToken(value string) (User, Token, error) {
+	var token Token
+	if err := s.signer.verify(value, &token); err != nil {
+		return User{}, token, fmt.Errorf("verify token: %w", err)
+	}
+
+	if token.Expired(time.Now()) {
+		return User{}, token, errors.New("token expired")
+	}
+
+	if !s.tokens.has(token.ID) {
+		return User{}, token, errors.New("token revoked")
+	}
+
+	scope := token.Scope
+
+	return User{Login: tokenPrefix + token.Name, Role: token.Role, Scope: &scope}, token, nil
+}
+
+func secureRequest(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// openTokenSession keeps the token in a cookie and removes it from the URL, so it doesn't leak in history or logs
+func openTokenSession(w http.ResponseWriter, r *http.Request, value string, token Token) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     tokenCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  token.Expire,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	query := r.URL.Query()
+	query.Del(TokenParam)
+
+	redirect := *r.URL
+	redirect.RawQuery = query.Encode()
+
+	http.Redirect(w, r, redirect.RequestURI(), http.StatusFound)
+}
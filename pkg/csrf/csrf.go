@@ -0,0 +1,89 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+)
+
+const (
+	CookieName = "hue_csrf"
+	FieldName  = "csrf"
+	HeaderName = "X-CSRF-Token"
+)
+
+// Token returns the token of the browser session, creating it if needed. It has to be injected in every form
+func Token(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(CookieName); err == nil && len(cookie.Value) != 0 {
+		return cookie.Value
+	}
+
+	token := rand.Text()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   isSecure(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return token
+}
+
+// Verify checks that a form submission carries the token of the browser session, in the form or in a header
+func Verify(r *http.Request) error {
+	if err := CheckOrigin(r); err != nil {
+		return err
+	}
+
+	cookie, err := r.Cookie(CookieName)
+	if err != nil || len(cookie.Value) == 0 {
+		return model.WrapForbidden(errors.New("no csrf token in session, reload the page"))
+	}
+
+	token := r.Header.Get(HeaderName)
+	if len(token) == 0 {
+		token = r.FormValue(FieldName)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) != 1 {
+		return model.WrapForbidden(errors.New("invalid csrf token, reload the page"))
+	}
+
+	return nil
+}
+
+// CheckOrigin rejects requests issued by another site, according to the browser's headers. Requests without them, from API
+// clients that aren't browsers, are allowed
+func CheckOrigin(r *http.Request) error {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return model.WrapForbidden(errors.New("cross-site request"))
+	}
+
+	source := r.Header.Get("Origin")
+	if len(source) == 0 {
+		source = r.Header.Get("Referer")
+	}
+
+	if len(source) == 0 {
+		return nil
+	}
+
+	sourceURL, err := url.Parse(source)
+	if err != nil || sourceURL.Host != r.Host {
+		return model.WrapForbidden(fmt.Errorf("cross-origin request from `%s`", source))
+	}
+
+	return nil
+}
+
+func isSecure(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,141 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+)
+
+func TestToken(t *testing.T) {
+	writer := httptest.NewRecorder()
+	token := Token(writer, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	cookies := writer.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != token || cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Fatalf("Token() cookies = %+v, want a strict cookie with `%s`", cookies, token)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+
+	writer = httptest.NewRecorder()
+	if got := Token(writer, req); got != token {
+		t.Errorf("Token() = `%s`, want `%s`", got, token)
+	}
+
+	if got := len(writer.Result().Cookies()); got != 0 {
+		t.Errorf("Token() set %d cookies on existing session, want 0", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	cases := map[string]struct {
+		cookie  string
+		form    string
+		header  http.Header
+		wantErr error
+	}{
+		"valid": {
+			cookie: "token",
+			form:   "token",
+		},
+		"header": {
+			cookie: "token",
+			header: http.Header{http.CanonicalHeaderKey(HeaderName): []string{"token"}},
+		},
+		"same origin": {
+			cookie: "token",
+			form:   "token",
+			header: http.Header{"Origin": []string{"http://example.com"}},
+		},
+		"no session": {
+			form:    "token",
+			wantErr: model.ErrForbidden,
+		},
+		"missing": {
+			cookie:  "token",
+			wantErr: model.ErrForbidden,
+		},
+		"mismatch": {
+			cookie:  "token",
+			form:    "other",
+			wantErr: model.ErrForbidden,
+		},
+		"cross origin": {
+			cookie:  "token",
+			form:    "token",
+			header:  http.Header{"Origin": []string{"https://evil.com"}},
+			wantErr: model.ErrForbidden,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/groups/", strings.NewReader(url.Values{FieldName: []string{tc.form}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			for key := range tc.header {
+				req.Header.Set(key, tc.header.Get(key))
+			}
+
+			if len(tc.cookie) != 0 {
+				req.AddCookie(&http.Cookie{Name: CookieName, Value: tc.cookie})
+			}
+
+			if gotErr := Verify(req); !errors.Is(gotErr, tc.wantErr) {
+				t.Errorf("Verify() = %v, want %v", gotErr, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	cases := map[string]struct {
+		header  http.Header
+		wantErr error
+	}{
+		"no header": {},
+		"same origin": {
+			header: http.Header{"Origin": []string{"http://example.com"}},
+		},
+		"same referer": {
+			header: http.Header{"Referer": []string{"http://example.com/devices"}},
+		},
+		"other origin": {
+			header:  http.Header{"Origin": []string{"http://example.com.evil.com"}},
+			wantErr: model.ErrForbidden,
+		},
+		"other referer": {
+			header:  http.Header{"Referer": []string{"https://evil.com/example.com"}},
+			wantErr: model.ErrForbidden,
+		},
+		"opaque origin": {
+			header:  http.Header{"Origin": []string{"null"}},
+			wantErr: model.ErrForbidden,
+		},
+		"cross site": {
+			header:  http.Header{"Sec-Fetch-Site": []string{"cross-site"}},
+			wantErr: model.ErrForbidden,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/api/v1/groups", nil)
+			req.Header = tc.header
+
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+
+			if gotErr := CheckOrigin(req); !errors.Is(gotErr, tc.wantErr) {
+				t.Errorf("CheckOrigin() = %v, want %v", gotErr, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+package history
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViBiOh/flags"
+	"github.com/ViBiOh/httputils/v4/pkg/cron"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	dayLayout        = "2006-01-02"
+	rawSuffix        = ".csv"
+	downsampleSuffix = ".downsampled.csv"
+
+	// queueSize is the number of points waiting to be written before new ones are dropped
+	queueSize = 1024
+)
+
+var (
+	ErrInvalidSensor = errors.New("invalid sensor id")
+	ErrQueueFull     = errors.New("history queue is full")
+	sensorIDMatcher  = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+)
+
+type Metric string
+
+const (
+	Temperature Metric = "temperature"
+	Lux         Metric = "lux"
+	Motion      Metric = "motion"
+	Battery     Metric = "battery"
+)
+
+// Metrics lists every metric recorded in history
+var Metrics = []Metric{Temperature, Lux, Motion, Battery}
+
+// aggregate merges values of a bucket: motions are counted, other metrics are averaged
+func (m Metric) aggregate(values []float64) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+
+	if m == Motion {
+		return sum
+	}
+
+	return sum / float64(len(values))
+}
+
+type Point struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// record is a point waiting to be written to disk
+type record struct {
+	timestamp time.Time
+	sensor    string
+	metric    Metric
+	value     float64
+}
+
+type Service struct {
+	tracerProvider trace.TracerProvider
+	records        chan record
+	directory      string
+	retention      time.Duration
+	resolution     time.Duration
+	mutex          sync.Mutex
+	// compaction keeps queries from reading a day being compacted, appends never waiting on them
+	compaction sync.RWMutex
+}
+
+type Config struct {
+	Directory  string
+	Retention  time.Duration
+	Resolution time.Duration
+}
+
+func Flags(fs *flag.FlagSet, prefix string) *Config {
+	var config Config
+
+	flags.New("Directory", "Directory for storing sensors' history, disabled if empty").Prefix(prefix).DocPrefix("history").StringVar(fs, &config.Directory, "", nil)
+	flags.New("Retention", "Retention of sensors' history").Prefix(prefix).DocPrefix("history").DurationVar(fs, &config.Retention, time.Hour*24*30, nil)
+	flags.New("Resolution", "Resolution of sensors' history of past days").Prefix(prefix).DocPrefix("history").DurationVar(fs, &config.Resolution, time.Minute*5, nil)
+
+	return &config
+}
+
+// New creates the history service, it returns nil if no directory is configured
+func New(config *Config, tracerProvider trace.TracerProvider) (*Service, error) {
+	if len(config.Directory) == 0 {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(config.Directory, 0o700); err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+
+	return &Service{
+		directory:      config.Directory,
+		retention:      config.Retention,
+		resolution:     config.Resolution,
+		tracerProvider: tracerProvider,
+		records:        make(chan record, queueSize),
+	}, nil
+}
+
+// Retention returns how long the history is kept
+func (s *Service) Retention() time.Duration {
+	return s.retention
+}
+
+func (s *Service) Enabled() bool {
+	return s != nil
+}
+
+func (s *Service) Start(ctx context.Context) {
+	if s == nil {
+		return
+	}
+
+	go s.drain(ctx)
+
+	cron.New().WithTracerProvider(s.tracerProvider).Each(time.Hour).Now().OnError(func(ctx context.Context, err error) {
+		slog.LogAttrs(ctx, slog.LevelError, "history maintenance", slog.Any("error", err))
+	}).Start(ctx, s.maintain)
+}
+
+// Record queues the point for being written to disk, for never blocking the caller on I/O. The point is dropped if the queue is full
+func (s *Service) Record(sensor string, metric Metric, timestamp time.Time, value float64) error {
+	if s == nil {
+		return nil
+	}
+
+	if !sensorIDMatcher.MatchString(sensor) {
+		return ErrInvalidSensor
+	}
+
+	select {
+	case s.records <- record{sensor: sensor, metric: metric, timestamp: timestamp, value: value}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// drain writes the queued points until the context is done, the pending ones being written before returning
+func (s *Service) drain(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			s.flush(context.WithoutCancel(ctx))
+			return
+		case item := <-s.records:
+			s.write(ctx, item)
+		}
+	}
+}
+
+// flush writes the queued points, without waiting for new ones
+func (s *Service) flush(ctx context.Context) {
+	for {
+		select {
+		case item := <-s.records:
+			s.write(ctx, item)
+		default:
+			return
+		}
+	}
+}
+
+func (s *Service) write(ctx context.Context, item record) {
+	if err := s.append(item); err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "write history", slog.String("sensor", item.sensor), slog.String("metric", string(item.metric)), slog.Any("error", err))
+	}
+}
+
+func (s *Service) append(item record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	directory := filepath.Join(s.directory, item.sensor, string(item.metric))
+	if err := os.MkdirAll(directory, 0o700); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(directory, item.timestamp.UTC().Format(dayLayout)+rawSuffix), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+
+	_, err = fmt.Fprintf(file, "%d,%s\n", item.timestamp.Unix(), strconv.FormatFloat(item.value, 'f', -1, 64))
+
+	return errors.Join(err, file.Close())
+}
+
+// Query returns points of the metric between from and to, aggregated by resolution if not zero
+func (s *Service) Query(sensor string, metric Metric, from, to time.Time, resolution time.Duration) ([]Point, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	if !sensorIDMatcher.MatchString(sensor) {
+		return nil, ErrInvalidSensor
+	}
+
+	s.compaction.RLock()
+	defer s.compaction.RUnlock()
+
+	directory := filepath.Join(s.directory, sensor, string(metric))
+
+	var points []Point
+
+	for day := from.UTC().Truncate(time.Hour * 24); !day.After(to); day = day.Add(time.Hour * 24) {
+		for _, suffix := range []string{downsampleSuffix, rawSuffix} {
+			dayPoints, err := readPoints(filepath.Join(directory, day.Format(dayLayout)+suffix))
+			if err != nil {
+				return nil, fmt.Errorf("read `%s`: %w", day.Format(dayLayout), err)
+			}
+
+			for _, point := range dayPoints {
+				if !point.Timestamp.Before(from) && !point.Timestamp.After(to) {
+					points = append(points, point)
+				}
+			}
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Timestamp.Before(points[j].Timestamp)
+	})
+
+	if resolution <= 0 {
+		return points, nil
+	}
+
+	return downsample(metric, points, from, resolution), nil
+}
+
+func downsample(metric Metric, points []Point, origin time.Time, resolution time.Duration) []Point {
+	var output []Point
+	var values []float64
+	var bucket time.Time
+
+	for _, point := range points {
+		pointBucket := origin.Add(point.Timestamp.Sub(origin) / resolution * resolution)
+
+		if !pointBucket.Equal(bucket) && len(values) != 0 {
+			output = append(output, Point{Timestamp: bucket, Value: metric.aggregate(values)})
+			values = values[:0]
+		}
+
+		bucket = pointBucket
+		values = append(values, point.Value)
+	}
+
+	if len(values) != 0 {
+		output = append(output, Point{Timestamp: bucket, Value: metric.aggregate(values)})
+	}
+
+	return output
+}
+
+func (s *Service) maintain(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.compaction.Lock()
+	defer s.compaction.Unlock()
+
+	now := time.Now().UTC()
+	today := now.Truncate(time.Hour * 24)
+	expiration := now.Add(-s.retention).Truncate(time.Hour * 24)
+
+	return filepath.WalkDir(s.directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		name := entry.Name()
+		if !strings.HasSuffix(name, rawSuffix) {
+			return nil
+		}
+
+		day, err := time.Parse(dayLayout, strings.TrimSuffix(strings.TrimSuffix(name, downsampleSuffix), rawSuffix))
+		if err != nil {
+			return nil
+		}
+
+		if day.Before(expiration) {
+			slog.LogAttrs(ctx, slog.LevelDebug, "Removing expired history", slog.String("path", path))
+			return os.Remove(path)
+		}
+
+		if strings.HasSuffix(name, downsampleSuffix) || !day.Before(today) {
+			return nil
+		}
+
+		return s.compact(path, day)
+	})
+}
+
+func (s *Service) compact(path string, day time.Time) error {
+	metric := Metric(filepath.Base(filepath.Dir(path)))
+
+	points, err := readPoints(path)
+	if err != nil {
+		return fmt.Errorf("read `%s`: %w", path, err)
+	}
+
+	downsampledPath := strings.TrimSuffix(path, rawSuffix) + downsampleSuffix
+
+	previous, err := readPoints(downsampledPath)
+	if err != nil {
+		return fmt.Errorf("read `%s`: %w", downsampledPath, err)
+	}
+
+	points = append(previous, points...)
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Timestamp.Before(points[j].Timestamp)
+	})
+
+	if err := writePoints(downsampledPath, downsample(metric, points, day, s.resolution)); err != nil {
+		return fmt.Errorf("write `%s`: %w", downsampledPath, err)
+	}
+
+	return os.Remove(path)
+}
+
+func readPoints(path string) ([]Point, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var output []Point
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		rawTimestamp, rawValue, ok := strings.Cut(scanner.Text(), ",")
+		if !ok {
+			continue
+		}
+
+		timestamp, err := strconv.ParseInt(rawTimestamp, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(rawValue, 64)
+		if err != nil {
+			continue
+		}
+
+		output = append(output, Point{Timestamp: time.Unix(timestamp, 0), Value: value})
+	}
+
+	return output, scanner.Err()
+}
+
+func writePoints(path string, points []Point) error {
+	temporaryPath := path + ".tmp"
+
+	file, err := os.OpenFile(temporaryPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+
+	for _, point := range points {
+		if _, err = fmt.Fprintf(writer, "%d,%s\n", point.Timestamp.Unix(), strconv.FormatFloat(point.Value, 'f', -1, 64)); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	if err = errors.Join(err, file.Close()); err != nil {
+		return err
+	}
+
+	return os.Rename(temporaryPath, path)
+}
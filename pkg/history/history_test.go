@@ -0,0 +1,186 @@
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	origin := time.Date(2024, 1, 1, 23, 50, 0, 0, time.UTC)
+
+	service, err := New(&Config{Directory: t.TempDir(), Retention: time.Hour * 24 * 30, Resolution: time.Minute * 5}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, value := range []float64{20, 21, 22, 23} {
+		if err := service.Record("sensor-1", Temperature, origin.Add(time.Duration(i)*time.Minute*5), value); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := service.Record("sensor-1", Motion, origin.Add(time.Duration(i)*time.Minute*5), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	service.flush(context.Background())
+
+	type args struct {
+		metric     Metric
+		resolution time.Duration
+	}
+
+	cases := map[string]struct {
+		args args
+		want []Point
+	}{
+		"raw": {
+			args{
+				metric: Temperature,
+			},
+			[]Point{
+				{Timestamp: origin, Value: 20},
+				{Timestamp: origin.Add(time.Minute * 5), Value: 21},
+				{Timestamp: origin.Add(time.Minute * 10), Value: 22},
+				{Timestamp: origin.Add(time.Minute * 15), Value: 23},
+			},
+		},
+		"average": {
+			args{
+				metric:     Temperature,
+				resolution: time.Minute * 10,
+			},
+			[]Point{
+				{Timestamp: origin, Value: 20.5},
+				{Timestamp: origin.Add(time.Minute * 10), Value: 22.5},
+			},
+		},
+		"count": {
+			args{
+				metric:     Motion,
+				resolution: time.Hour,
+			},
+			[]Point{
+				{Timestamp: origin, Value: 4},
+			},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			got, err := service.Query("sensor-1", tc.args.metric, origin, origin.Add(time.Hour), tc.args.resolution)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := range got {
+				got[i].Timestamp = got[i].Timestamp.UTC()
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Query() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMaintain(t *testing.T) {
+	directory := t.TempDir()
+
+	service, err := New(&Config{Directory: directory, Retention: time.Hour * 24 * 2, Resolution: time.Hour}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	yesterday := now.Truncate(time.Hour * 24).Add(-time.Hour * 12)
+	expired := now.Add(-time.Hour * 24 * 5)
+
+	for _, timestamp := range []time.Time{yesterday, yesterday.Add(time.Minute), expired} {
+		if err := service.Record("sensor-1", Lux, timestamp, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	service.flush(context.Background())
+
+	if err := service.maintain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	metricDirectory := filepath.Join(directory, "sensor-1", string(Lux))
+
+	if _, err := os.Stat(filepath.Join(metricDirectory, expired.Format(dayLayout)+rawSuffix)); !os.IsNotExist(err) {
+		t.Errorf("expired file still exists: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(metricDirectory, yesterday.Format(dayLayout)+rawSuffix)); !os.IsNotExist(err) {
+		t.Errorf("raw file still exists: %v", err)
+	}
+
+	points, err := readPoints(filepath.Join(metricDirectory, yesterday.Format(dayLayout)+downsampleSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(points) != 1 || points[0].Value != 10 {
+		t.Errorf("downsampled points = %+v, want one point of 10", points)
+	}
+}
+
+func TestRecordInvalidSensor(t *testing.T) {
+	service, err := New(&Config{Directory: t.TempDir()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Record("../etc", Lux, time.Now(), 1); err != ErrInvalidSensor {
+		t.Errorf("Record() = %v, want %v", err, ErrInvalidSensor)
+	}
+}
+
+func TestRecordQueueFull(t *testing.T) {
+	service, err := New(&Config{Directory: t.TempDir()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range queueSize {
+		if err := service.Record("sensor-1", Lux, time.Now(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := service.Record("sensor-1", Lux, time.Now(), 1); err != ErrQueueFull {
+		t.Errorf("Record() = %v, want %v", err, ErrQueueFull)
+	}
+}
+
+func TestAppendDuringQuery(t *testing.T) {
+	service, err := New(&Config{Directory: t.TempDir()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A query in progress
+	service.compaction.RLock()
+	defer service.compaction.RUnlock()
+
+	done := make(chan error)
+	go func() {
+		done <- service.append(record{sensor: "sensor-1", metric: Lux, timestamp: time.Now(), value: 1})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("append() waited for the query")
+	}
+}
@@ -0,0 +1,232 @@
+package hue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/httpjson"
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"github.com/ViBiOh/httputils/v4/pkg/renderer"
+	"github.com/ViBiOh/hue/pkg/activity"
+	"github.com/ViBiOh/hue/pkg/auth"
+)
+
+const (
+	ActivityPath = "/activity"
+
+	defaultActivityLimit = 200
+	maxActivityLimit     = 5000
+
+	// activityInputLayout is the format of the datetime-local inputs of the activity page
+	activityInputLayout = "2006-01-02T15:04"
+
+	lastTriggeredLayout = "2006-01-02T15:04:05"
+	minutesPerDay       = 24 * 60
+
+	// ruleTriggerWindow is how long after a rule of the app is triggered a change of its group is attributed to it
+	ruleTriggerWindow = time.Second * 30
+)
+
+// TemplateActivity renders the activity log, for admins only
+func (s *Service) TemplateActivity(w http.ResponseWriter, r *http.Request) (renderer.Page, error) {
+	ctx := r.Context()
+
+	if !s.activity.Enabled() {
+		return renderer.Page{}, model.WrapNotFound(errors.New("activity log is disabled"))
+	}
+
+	if err := s.auth.Check(ctx, auth.Admin); err != nil {
+		return renderer.Page{}, err
+	}
+
+	params := r.URL.Query()
+
+	filter, err := parseActivityFilter(params)
+	if err != nil {
+		return renderer.Page{}, err
+	}
+
+	entries, err := s.activity.List(filter)
+	if err != nil {
+		return renderer.Page{}, fmt.Errorf("list activity: %w", err)
+	}
+
+	return renderer.NewPage("activity", http.StatusOK, s.withUser(w, r, map[string]any{
+		"Entries": entries,
+		"Sources": activity.Sources,
+		"Kinds":   activity.Kinds,
+		"Filter":  params,
+		"Limit":   filter.Limit,
+	})), nil
+}
+
+func (s *Service) HandleAPIActivity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if !s.activity.Enabled() {
+		writeAPIError(ctx, w, model.WrapNotFound(errors.New("activity log is disabled")))
+		return
+	}
+
+	if err := s.auth.Check(ctx, auth.Admin); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	filter, err := parseActivityFilter(r.URL.Query())
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	entries, err := s.activity.List(filter)
+	if err != nil {
+		writeAPIError(ctx, w, fmt.Errorf("list activity: %w", err))
+		return
+	}
+
+	if entries == nil {
+		entries = []activity.Entry{}
+	}
+
+	httpjson.Write(ctx, w, http.StatusOK, entries)
+}
+
+func parseActivityFilter(params url.Values) (activity.Filter, error) {
+	output := activity.Filter{
+		Target: params.Get("target"),
+		Limit:  defaultActivityLimit,
+	}
+
+	if source := activity.Source(params.Get("source")); len(source) != 0 {
+		if !slices.Contains(activity.Sources, source) {
+			return output, model.WrapInvalid(fmt.Errorf("unknown source `%s`", source))
+		}
+
+		output.Source = source
+	}
+
+	if kind := activity.Kind(params.Get("kind")); len(kind) != 0 {
+		if !slices.Contains(activity.Kinds, kind) {
+			return output, model.WrapInvalid(fmt.Errorf("unknown kind `%s`", kind))
+		}
+
+		output.Kind = kind
+	}
+
+	var err error
+
+	if output.From, err = parseActivityTime(params.Get("from")); err != nil {
+		return output, model.WrapInvalid(fmt.Errorf("parse `from`: %w", err))
+	}
+
+	if output.To, err = parseActivityTime(params.Get("to")); err != nil {
+		return output, model.WrapInvalid(fmt.Errorf("parse `to`: %w", err))
+	}
+
+	if !output.From.IsZero() && !output.To.IsZero() && !output.From.Before(output.To) {
+		return output, model.WrapInvalid(errors.New("`from` must be before `to`"))
+	}
+
+	if rawLimit := params.Get("limit"); len(rawLimit) != 0 {
+		if output.Limit, err = strconv.Atoi(rawLimit); err != nil || output.Limit <= 0 || output.Limit > maxActivityLimit {
+			return output, model.WrapInvalid(fmt.Errorf("`limit` must be between 1 and %d", maxActivityLimit))
+		}
+	}
+
+	return output, nil
+}
+
+// parseActivityTime accepts RFC3339 from the API and the local time of the page's inputs
+func parseActivityTime(value string) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+
+	if output, err := time.Parse(time.RFC3339, value); err == nil {
+		return output, nil
+	}
+
+	return time.ParseInLocation(activityInputLayout, value, time.Local)
+}
+
+// bridgeAutomation attributes a change of a group received from the event stream to the schedule of the app due at that minute, or to its rule just triggered
+func (s *Service) bridgeAutomation(ctx context.Context, kind activity.Kind, target string, now time.Time) (string, bool) {
+	if kind != activity.Group {
+		return "", false
+	}
+
+	group, ok := s.v2Service.Group(target)
+	if !ok {
+		return "", false
+	}
+
+	if schedule, ok := s.dueSchedule(group.IDV1, now); ok {
+		return "schedule " + schedule.Name, true
+	}
+
+	rules, err := s.listRules(ctx)
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelWarn, "list rules for attributing a change", slog.Any("error", err))
+		return "", false
+	}
+
+	for _, rule := range rules {
+		if !s.ownedRule(rule) || !ruleTriggered(rule.LastTriggered, now) {
+			continue
+		}
+
+		if slices.ContainsFunc(rule.Actions, func(action Action) bool { return action.GetGroup() == group.IDV1 }) {
+			return "rule " + rule.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// dueSchedule returns the schedule of the app acting on the group at the minute of now
+func (s *Service) dueSchedule(groupIDV1 string, now time.Time) (Schedule, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now = s.inLocation(now)
+
+	for _, schedule := range s.schedules {
+		if s.ownedSchedule(schedule) && schedule.Command.GetGroup() == groupIDV1 && scheduleDue(schedule.Localtime, now) {
+			return schedule, true
+		}
+	}
+
+	return Schedule{}, false
+}
+
+// scheduleDue tells if the weekly or daily local time is the minute of now, or the one before for the changes received late
+func scheduleDue(localtime string, now time.Time) bool {
+	minute, ok := localtimeMinute(localtime)
+	if !ok {
+		return false
+	}
+
+	elapsed := (minuteOfDay(now) - minute + minutesPerDay) % minutesPerDay
+
+	return elapsed <= 1
+}
+
+// ruleTriggered tells if the rule has been triggered within the window before now
+func ruleTriggered(lastTriggered string, now time.Time) bool {
+	triggered, err := time.ParseInLocation(lastTriggeredLayout, lastTriggered, time.UTC)
+	if err != nil {
+		return false
+	}
+
+	elapsed := now.Sub(triggered)
+
+	return elapsed >= 0 && elapsed <= ruleTriggerWindow
+}
@@ -0,0 +1,143 @@
+package hue
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"github.com/ViBiOh/hue/pkg/activity"
+)
+
+func TestParseActivityFilter(t *testing.T) {
+	from := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		params  url.Values
+		want    activity.Filter
+		wantErr error
+	}{
+		"default": {
+			url.Values{},
+			activity.Filter{Limit: defaultActivityLimit},
+			nil,
+		},
+		"full": {
+			url.Values{"source": {"external"}, "kind": {"group"}, "target": {"living"}, "from": {"2024-01-01T20:00:00Z"}, "to": {"2024-01-02T20:00:00Z"}, "limit": {"10"}},
+			activity.Filter{Source: activity.External, Kind: activity.Group, Target: "living", From: from, To: from.Add(time.Hour * 24), Limit: 10},
+			nil,
+		},
+		"local input": {
+			url.Values{"from": {"2024-01-01T20:00"}},
+			activity.Filter{From: time.Date(2024, 1, 1, 20, 0, 0, 0, time.Local), Limit: defaultActivityLimit},
+			nil,
+		},
+		"unknown source": {
+			url.Values{"source": {"hub"}},
+			activity.Filter{},
+			model.ErrInvalid,
+		},
+		"unknown kind": {
+			url.Values{"kind": {"light"}},
+			activity.Filter{},
+			model.ErrInvalid,
+		},
+		"invalid range": {
+			url.Values{"from": {"2024-01-02T20:00:00Z"}, "to": {"2024-01-01T20:00:00Z"}},
+			activity.Filter{},
+			model.ErrInvalid,
+		},
+		"invalid limit": {
+			url.Values{"limit": {"0"}},
+			activity.Filter{},
+			model.ErrInvalid,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			got, gotErr := parseActivityFilter(tc.params)
+
+			if tc.wantErr != nil {
+				if !errors.Is(gotErr, tc.wantErr) {
+					t.Errorf("parseActivityFilter() error = %v, want %v", gotErr, tc.wantErr)
+				}
+
+				return
+			}
+
+			if gotErr != nil {
+				t.Fatalf("parseActivityFilter() error = %v", gotErr)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseActivityFilter() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScheduleDue(t *testing.T) {
+	now := time.Date(2024, 1, 1, 7, 31, 20, 0, time.UTC)
+
+	cases := map[string]struct {
+		localtime string
+		want      bool
+	}{
+		"same minute": {
+			localtime: "W124/T07:31:00",
+			want:      true,
+		},
+		"minute before": {
+			localtime: "W124/T07:30:00",
+			want:      true,
+		},
+		"earlier": {
+			localtime: "W124/T07:00:00",
+		},
+		"later": {
+			localtime: "W124/T07:32:00",
+		},
+		"timer": {
+			localtime: "PT00:10:00",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := scheduleDue(tc.localtime, now); got != tc.want {
+				t.Errorf("scheduleDue() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRuleTriggered(t *testing.T) {
+	now := time.Date(2024, 1, 1, 7, 30, 20, 0, time.UTC)
+
+	cases := map[string]struct {
+		lastTriggered string
+		want          bool
+	}{
+		"just triggered": {
+			lastTriggered: "2024-01-01T07:30:10",
+			want:          true,
+		},
+		"triggered earlier": {
+			lastTriggered: "2024-01-01T07:20:00",
+		},
+		"never triggered": {
+			lastTriggered: "none",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := ruleTriggered(tc.lastTriggered, now); got != tc.want {
+				t.Errorf("ruleTriggered() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
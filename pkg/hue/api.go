@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/ViBiOh/httputils/v4/pkg/request"
 )
@@ -15,8 +16,24 @@ func hasError(content []byte) bool {
 	return !bytes.Contains(content, []byte("success"))
 }
 
-func get(ctx context.Context, url string, response any) error {
-	resp, err := request.Get(url).Send(ctx, nil)
+// url returns the address of the resource on the v1 API of the bridge, of the given object if id isn't empty
+func (s *Service) url(resource, id string) string {
+	if len(id) == 0 {
+		return fmt.Sprintf("%s/%s", s.bridgeURL, resource)
+	}
+
+	return fmt.Sprintf("%s/%s/%s", s.bridgeURL, resource, id)
+}
+
+// record times the call to the v1 API of the bridge, with the instruments of the calls to the v2 one. It is meant to be deferred with the named error of the call
+func (s *Service) record(ctx context.Context, resource, method string, start time.Time, err *error) {
+	s.v2Service.RecordRequest(ctx, "v1", resource, method, time.Since(start), *err)
+}
+
+func (s *Service) apiGet(ctx context.Context, resource string, response any) (err error) {
+	defer s.record(ctx, resource, http.MethodGet, time.Now(), &err)
+
+	resp, err := request.Get(s.url(resource, "")).Send(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -27,8 +44,10 @@ func get(ctx context.Context, url string, response any) error {
 	return nil
 }
 
-func create(ctx context.Context, url string, payload any) (string, error) {
-	resp, err := request.Post(url).JSON(ctx, payload)
+func (s *Service) apiCreate(ctx context.Context, resource string, payload any) (id string, err error) {
+	defer s.record(ctx, resource, http.MethodPost, time.Now(), &err)
+
+	resp, err := request.Post(s.url(resource, "")).JSON(ctx, payload)
 	if err != nil {
 		return "", err
 	}
@@ -50,8 +69,10 @@ func create(ctx context.Context, url string, payload any) (string, error) {
 	return response[0]["success"]["id"], nil
 }
 
-func update(ctx context.Context, url string, payload any) error {
-	resp, err := request.Put(url).JSON(ctx, payload)
+func (s *Service) apiUpdate(ctx context.Context, resource, id string, payload any) (err error) {
+	defer s.record(ctx, resource, http.MethodPut, time.Now(), &err)
+
+	resp, err := request.Put(s.url(resource, id)).JSON(ctx, payload)
 	if err != nil {
 		return err
 	}
@@ -68,8 +89,10 @@ func update(ctx context.Context, url string, payload any) error {
 	return nil
 }
 
-func remove(ctx context.Context, url string) error {
-	resp, err := request.Delete(url).Send(ctx, nil)
+func (s *Service) apiRemove(ctx context.Context, resource, id string) (err error) {
+	defer s.record(ctx, resource, http.MethodDelete, time.Now(), &err)
+
+	resp, err := request.Delete(s.url(resource, id)).Send(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,176 @@
+package hue
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViBiOh/hue/pkg/history"
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+const (
+	chartWidth  = 300
+	chartHeight = 100
+	chartPoints = 96
+
+	// chartCacheDuration is how long the points of a chart are kept before reading the history again
+	chartCacheDuration = time.Minute * 5
+
+	defaultHistoryRange = "24h"
+)
+
+var (
+	historyRanges = map[string]time.Duration{
+		"24h": time.Hour * 24,
+		"7d":  time.Hour * 24 * 7,
+		"30d": time.Hour * 24 * 30,
+	}
+
+	historyRangeNames = []string{"24h", "7d", "30d"}
+)
+
+// Chart describes a metric history drawn as a SVG polyline
+type Chart struct {
+	Points string
+	Min    float64
+	Max    float64
+	Last   float64
+	Empty  bool
+}
+
+// chartCache keeps the points of the charts for a while, for not reading the history files on every render of the dashboard
+type chartCache struct {
+	entries map[chartKey]chartEntry
+	mutex   sync.Mutex
+}
+
+type chartKey struct {
+	sensor     string
+	metric     history.Metric
+	resolution time.Duration
+}
+
+type chartEntry struct {
+	expiration time.Time
+	points     []history.Point
+}
+
+func (c *chartCache) get(key chartKey, now time.Time) ([]history.Point, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || now.After(entry.expiration) {
+		return nil, false
+	}
+
+	return entry.points, true
+}
+
+func (c *chartCache) set(key chartKey, points []history.Point, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[chartKey]chartEntry)
+	}
+
+	for key, entry := range c.entries {
+		if now.After(entry.expiration) {
+			delete(c.entries, key)
+		}
+	}
+
+	c.entries[key] = chartEntry{points: points, expiration: now.Add(chartCacheDuration)}
+}
+
+type sensorHistory struct {
+	Sensor      v2.MotionSensor
+	Temperature Chart
+	Lux         Chart
+	Motion      Chart
+}
+
+func (s *Service) sensorsHistory(ctx context.Context, sensors []v2.MotionSensor, duration time.Duration) []sensorHistory {
+	to := time.Now()
+	from := to.Add(-duration)
+	resolution := duration / chartPoints
+
+	output := make([]sensorHistory, 0, len(sensors))
+
+	for _, sensor := range sensors {
+		output = append(output, sensorHistory{
+			Sensor:      sensor,
+			Temperature: s.chart(ctx, sensor.ID, history.Temperature, from, to, resolution),
+			Lux:         s.chart(ctx, sensor.ID, history.Lux, from, to, resolution),
+			Motion:      s.chart(ctx, sensor.ID, history.Motion, from, to, resolution),
+		})
+	}
+
+	return output
+}
+
+// chart draws the history of the metric, its points being read from the cache when the same chart has been drawn recently
+func (s *Service) chart(ctx context.Context, sensor string, metric history.Metric, from, to time.Time, resolution time.Duration) Chart {
+	key := chartKey{sensor: sensor, metric: metric, resolution: resolution}
+
+	points, ok := s.charts.get(key, to)
+	if !ok {
+		var err error
+
+		points, err = s.history.Query(sensor, metric, from, to, resolution)
+		if err != nil {
+			slog.LogAttrs(ctx, slog.LevelError, "query history", slog.String("sensor", sensor), slog.String("metric", string(metric)), slog.Any("error", err))
+		} else {
+			s.charts.set(key, points, to)
+		}
+	}
+
+	start := 0
+	for start < len(points) && points[start].Timestamp.Before(from) {
+		start++
+	}
+
+	return newChart(points[start:], from, to)
+}
+
+func newChart(points []history.Point, from, to time.Time) Chart {
+	if len(points) == 0 {
+		return Chart{Empty: true}
+	}
+
+	chart := Chart{
+		Min:  points[0].Value,
+		Max:  points[0].Value,
+		Last: points[len(points)-1].Value,
+	}
+
+	for _, point := range points {
+		chart.Min = min(chart.Min, point.Value)
+		chart.Max = max(chart.Max, point.Value)
+	}
+
+	span := chart.Max - chart.Min
+	duration := to.Sub(from).Seconds()
+
+	coordinates := make([]string, 0, len(points))
+
+	for _, point := range points {
+		x := point.Timestamp.Sub(from).Seconds() / duration * chartWidth
+		y := float64(chartHeight) / 2
+
+		if span != 0 {
+			y = chartHeight - (point.Value-chart.Min)/span*chartHeight
+		}
+
+		coordinates = append(coordinates, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+
+	chart.Points = strings.Join(coordinates, " ")
+
+	return chart
+}
@@ -0,0 +1,44 @@
+package hue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/hue/pkg/history"
+)
+
+func TestChartCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	key := chartKey{sensor: "sensor-1", metric: history.Lux, resolution: time.Minute * 15}
+
+	cases := map[string]struct {
+		key    chartKey
+		at     time.Time
+		wantOk bool
+	}{
+		"cached": {
+			key:    key,
+			at:     now.Add(time.Minute),
+			wantOk: true,
+		},
+		"expired": {
+			key: key,
+			at:  now.Add(chartCacheDuration + time.Second),
+		},
+		"other range": {
+			key: chartKey{sensor: "sensor-1", metric: history.Lux, resolution: time.Hour},
+			at:  now,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var cache chartCache
+			cache.set(key, []history.Point{{Timestamp: now, Value: 10}}, now)
+
+			if _, ok := cache.get(tc.key, tc.at); ok != tc.wantOk {
+				t.Errorf("get() = %t, want %t", ok, tc.wantOk)
+			}
+		})
+	}
+}
@@ -1,16 +1,28 @@
 package hue
 
 type configHue struct {
+	States        map[string]groupPatch     `json:"states"`
+	Presets       map[string][]configPreset `json:"presets"`
 	Schedules     []ScheduleConfig
+	SmartScenes   []smartScenePayload `json:"smart_scenes"`
 	Sensors       []configSensor
 	Taps          []configTap
 	MotionSensors motionSensors `json:"motion_sensors"`
+	ReplaceStates bool          `json:"replace_states"`
+}
+
+type configPreset struct {
+	States map[string]groupPatch `json:"states"`
+	Name   string                `json:"name"`
+	From   string                `json:"from"`
+	To     string                `json:"to"`
 }
 
 type configSensor struct {
 	ID       string
 	OffDelay string
 	Groups   []string
+	DarkLux  float64 `json:"dark_lux"`
 	WhenDark bool
 	AllOff   bool
 }
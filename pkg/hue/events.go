@@ -0,0 +1,141 @@
+package hue
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/httperror"
+	"github.com/ViBiOh/hue/pkg/auth"
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+const (
+	EventsPath      = "/api/events"
+	eventsKeepAlive = time.Second * 30
+)
+
+type groupEvent struct {
+	v2.GroupState
+	ID string `json:"id"`
+}
+
+type sensorEvent struct {
+	v2.SensorState
+	ID              string `json:"id"`
+	TemperatureIcon string `json:"temperature_icon"`
+	LuxIcon         string `json:"lux_icon"`
+	BatteryIcon     string `json:"battery_icon"`
+}
+
+// HandleEvents streams changes of groups and sensors as server-sent events, until the client or the bridge stream goes away
+func (s *Service) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	controller := http.NewResponseController(w)
+	scope := auth.ScopeFromContext(ctx)
+
+	subscription := s.v2Service.Subscribe()
+	defer s.v2Service.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-subscription.Done():
+			return
+		case <-keepAlive.C:
+			_, err = io.WriteString(w, ": keep-alive\n\n")
+		case <-subscription.Notify():
+			for _, change := range subscription.Changes() {
+				if !inScope(scope, change) {
+					continue
+				}
+
+				if err = writeEvent(w, change); err != nil {
+					break
+				}
+			}
+		}
+
+		if err == nil {
+			err = controller.Flush()
+		}
+
+		if err != nil {
+			if !httperror.CanBeIgnored(err) {
+				slog.LogAttrs(ctx, slog.LevelError, "write event", slog.Any("error", err))
+			}
+
+			return
+		}
+	}
+}
+
+// NoWriteTimeout disables the server's write timeout on the events stream, keep-alives being sent instead. It has to wrap the
+// middlewares because their response writers don't expose the underlying connection.
+func NoWriteTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == EventsPath {
+			if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+				slog.LogAttrs(r.Context(), slog.LevelWarn, "disable write deadline", slog.Any("error", err))
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func inScope(scope *auth.Scope, change v2.Change) bool {
+	switch change.Kind {
+	case v2.GroupChange:
+		return scope.HasGroup(change.ID)
+	case v2.SensorChange:
+		return scope.HasSensor(change.ID)
+	default:
+		return scope == nil
+	}
+}
+
+func writeEvent(w io.Writer, change v2.Change) error {
+	var data any
+
+	switch change.Kind {
+	case v2.GroupChange:
+		data = groupEvent{
+			ID:         change.ID,
+			GroupState: *change.Group,
+		}
+	case v2.SensorChange:
+		data = sensorEvent{
+			ID:              change.ID,
+			SensorState:     *change.Sensor,
+			TemperatureIcon: temperatureIcon(change.Sensor.Temperature),
+			LuxIcon:         luxIcon(change.Sensor.Lux),
+			BatteryIcon:     batteryIcon(change.Sensor.BatteryLevel),
+		}
+	default:
+		return nil
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", change.Kind, payload)
+
+	return err
+}
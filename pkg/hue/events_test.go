@@ -0,0 +1,42 @@
+package hue
+
+import (
+	"strings"
+	"testing"
+
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+func TestWriteEvent(t *testing.T) {
+	cases := map[string]struct {
+		change v2.Change
+		want   string
+	}{
+		"group": {
+			change: v2.Change{Kind: v2.GroupChange, ID: "room", Group: &v2.GroupState{On: true, Reachable: false, Unreachable: []string{"Lamp"}}},
+			want:   "event: group\ndata: {\"unreachable\":[\"Lamp\"],\"on\":true,\"reachable\":false,\"id\":\"room\"}\n\n",
+		},
+		"sensor": {
+			change: v2.Change{Kind: v2.SensorChange, ID: "hall", Sensor: &v2.SensorState{Temperature: 19.5, Lux: 5, BatteryLevel: 95, Enabled: true, Motion: true, Reachable: true}},
+			want:   "event: sensor\ndata: {\"temperature\":19.5,\"lux\":5,\"battery_level\":95,\"enabled\":true,\"motion\":true,\"reachable\":true,\"id\":\"hall\",\"temperature_icon\":\"thermometer-half?fill=limegreen\",\"lux_icon\":\"moon?fill=silver\",\"battery_icon\":\"battery-full?fill=limegreen\"}\n\n",
+		},
+		"unknown": {
+			change: v2.Change{Kind: "scene", ID: "relax"},
+			want:   "",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var writer strings.Builder
+
+			if err := writeEvent(&writer, tc.change); err != nil {
+				t.Fatalf("writeEvent() error = %s", err)
+			}
+
+			if got := writer.String(); got != tc.want {
+				t.Errorf("writeEvent() = `%s`, want `%s`", got, tc.want)
+			}
+		})
+	}
+}
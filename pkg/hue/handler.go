@@ -9,50 +9,224 @@ import (
 
 	"github.com/ViBiOh/httputils/v4/pkg/model"
 	"github.com/ViBiOh/httputils/v4/pkg/renderer"
+	"github.com/ViBiOh/hue/pkg/auth"
+	"github.com/ViBiOh/hue/pkg/csrf"
+	v2 "github.com/ViBiOh/hue/pkg/v2"
 )
 
 const (
 	updateSuccessMessage = "%s is now %s"
+	allSensors           = "all"
 )
 
 func (s *Service) HandleGroup(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkForm(r, auth.Operator); err != nil {
+		s.renderer.Error(w, r, nil, err)
+		return
+	}
+
 	if r.FormValue("method") != http.MethodPatch {
 		s.renderer.Error(w, r, nil, model.WrapNotFound(fmt.Errorf("invalid method for updating group")))
 		return
 	}
 
-	groupID := r.PathValue("id")
-	stateName := r.FormValue("state")
+	patch, err := parseGroupForm(r)
+	if err != nil {
+		s.renderer.Error(w, r, nil, err)
+		return
+	}
 
-	state, ok := States[stateName]
-	if !ok {
-		s.renderer.Error(w, r, nil, model.WrapNotFound(fmt.Errorf("unknown state '%s'", stateName)))
+	name, description, err := s.updateGroups(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		s.renderer.Error(w, r, nil, err)
 		return
 	}
 
+	s.renderer.Redirect(w, r, "/", renderer.NewSuccessMessage(updateSuccessMessage, name, description))
+}
+
+// checkForm verifies the role of the user and the csrf token of a form submitted from the dashboard
+func (s *Service) checkForm(r *http.Request, role auth.Role) error {
+	if err := s.auth.Check(r.Context(), role); err != nil {
+		return err
+	}
+
+	return csrf.Verify(r)
+}
+
+// updateGroups applies the patch to the given group, or to every group if the ID is empty
+func (s *Service) updateGroups(ctx context.Context, groupID string, patch groupPatch) (string, string, error) {
+	minute := minuteOfDay(s.now())
+
 	if len(groupID) == 0 {
-		for _, group := range s.v2Service.Groups() {
-			_, err := s.v2Service.UpdateGroup(r.Context(), group.ID, state.On, float64(state.Brightness), state.Duration)
+		state, err := patch.resolve(s.states)
+		if err != nil {
+			return "", "", err
+		}
+
+		for _, group := range scopedGroups(ctx, s.v2Service.Groups()) {
+			if len(patch.State) != 0 && !s.allowsState(group.Name, patch.State) {
+				continue
+			}
+
+			groupState, err := patch.resolve(s.roomStates(group.Name, minute))
 			if err != nil {
-				s.renderer.Error(w, r, nil, err)
-				return
+				return "", "", err
+			}
+
+			if _, err := s.v2Service.UpdateGroup(ctx, group.ID, groupState.group()); err != nil {
+				return "", "", err
 			}
 		}
 
-		s.renderer.Redirect(w, r, "/", renderer.NewSuccessMessage("All groups are now %s", stateName))
+		return "All groups", patch.describe(state), nil
+	}
+
+	if err := checkGroupScope(ctx, groupID); err != nil {
+		return "", "", err
+	}
+
+	group, ok := s.v2Service.Group(groupID)
+	if !ok {
+		return "", "", model.WrapNotFound(fmt.Errorf("unknown group `%s`", groupID))
+	}
+
+	if len(patch.State) != 0 && !s.allowsState(group.Name, patch.State) {
+		return "", "", model.WrapInvalid(fmt.Errorf("state `%s` isn't allowed in `%s`", patch.State, group.Name))
+	}
+
+	state, err := patch.resolve(s.roomStates(group.Name, minute))
+	if err != nil {
+		return "", "", err
+	}
+
+	if group, err = s.v2Service.UpdateGroup(ctx, groupID, state.group()); err != nil {
+		return "", "", err
+	}
+
+	description := patch.describe(state)
+
+	if item, ok := s.presetAt(group.Name, minute); ok && !patch.custom() {
+		if _, ok := item.states[patch.State]; ok {
+			description = fmt.Sprintf("%s (%s)", description, item.label())
+		}
+	}
+
+	return group.Name, description, nil
+}
+
+func (s *Service) HandleScene(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkForm(r, auth.Operator); err != nil {
+		s.renderer.Error(w, r, nil, err)
+		return
+	}
+
+	switch r.FormValue("method") {
+	case http.MethodPatch:
+		s.handleSceneRecall(w, r)
+	case http.MethodPost:
+		s.handleSceneCreate(w, r)
+	default:
+		s.renderer.Error(w, r, nil, model.WrapMethodNotAllowed(errors.New("invalid method for updating scene")))
+	}
+}
+
+func (s *Service) handleSceneRecall(w http.ResponseWriter, r *http.Request) {
+	payload := sceneRecall{
+		Action: v2.SceneRecall(r.FormValue("action")),
+	}
+
+	if rawBrightness := r.FormValue("brightness"); len(rawBrightness) != 0 {
+		brightness, err := strconv.ParseFloat(rawBrightness, 64)
+		if err != nil {
+			s.renderer.Error(w, r, nil, model.WrapInvalid(fmt.Errorf("parse brightness: %w", err)))
+			return
+		}
+
+		payload.Brightness = brightness
+	}
+
+	scene, groupName, err := s.recallScene(r.Context(), r.PathValue("id"), payload)
+	if err != nil {
+		s.renderer.Error(w, r, nil, err)
+		return
+	}
+
+	s.renderer.Redirect(w, r, "/", renderer.NewSuccessMessage(updateSuccessMessage, groupName, "on scene "+scene.Metadata.Name))
+}
+
+func (s *Service) handleSceneCreate(w http.ResponseWriter, r *http.Request) {
+	payload, err := parseSceneForm(r)
+	if err != nil {
+		s.renderer.Error(w, r, nil, err)
 		return
 	}
 
-	group, err := s.v2Service.UpdateGroup(r.Context(), groupID, state.On, float64(state.Brightness), state.Duration)
+	scene, groupName, err := s.createScene(r.Context(), payload)
 	if err != nil {
 		s.renderer.Error(w, r, nil, err)
 		return
 	}
 
-	s.renderer.Redirect(w, r, "/", renderer.NewSuccessMessage(updateSuccessMessage, group.Name, stateName))
+	s.renderer.Redirect(w, r, "/", renderer.NewSuccessMessage("Scene %s saved in %s", scene.Metadata.Name, groupName))
+}
+
+// recallScene applies the scene to its group, active by default. It returns the scene and the name of its group
+func (s *Service) recallScene(ctx context.Context, id string, payload sceneRecall) (v2.Scene, string, error) {
+	scene, err := s.existingScene(ctx, id)
+	if err != nil {
+		return scene, "", err
+	}
+
+	if len(payload.Action) == 0 {
+		payload.Action = v2.SceneActive
+	}
+
+	scene, err = s.v2Service.RecallScene(ctx, id, payload.Action, payload.Brightness)
+	if err != nil {
+		return scene, "", err
+	}
+
+	groupName := scene.Metadata.Name
+	if group, ok := s.v2Service.Group(scene.Group.Rid); ok {
+		groupName = group.Name
+	}
+
+	return scene, groupName, nil
+}
+
+func (s *Service) HandleSmartScene(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkForm(r, auth.Admin); err != nil {
+		s.renderer.Error(w, r, nil, err)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	switch r.FormValue("method") {
+	case http.MethodPost:
+		id = ""
+	case http.MethodPut:
+	default:
+		s.renderer.Error(w, r, nil, model.WrapMethodNotAllowed(errors.New("invalid method for updating smart scene")))
+		return
+	}
+
+	smartScene, err := s.saveSmartScene(r.Context(), id, parseSmartSceneForm(r))
+	if err != nil {
+		s.renderer.Error(w, r, nil, err)
+		return
+	}
+
+	s.renderer.Redirect(w, r, "/", renderer.NewSuccessMessage("Smart scene %s saved", smartScene.Metadata.Name))
 }
 
 func (s *Service) HandleSchedule(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkForm(r, auth.Admin); err != nil {
+		s.renderer.Error(w, r, nil, err)
+		return
+	}
+
 	switch r.FormValue("method") {
 	case http.MethodPatch:
 		s.handleSchedulePatch(w, r)
@@ -95,7 +269,7 @@ func (s *Service) handleSchedulePut(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	localtime := fmt.Sprintf("W%03d/T%s:00", recurrence, time)
+	localtime := formatLocaltime(recurrence, time)
 
 	schedule := Schedule{
 		ID: r.PathValue("id"),
@@ -120,17 +294,29 @@ func (s *Service) handleScheduleSuccess(ctx context.Context, w http.ResponseWrit
 		return
 	}
 
-	s.mutex.RLock()
 	name := "Schedule"
-	if updated, ok := s.schedules[scheduleID]; ok {
+	if updated, ok := s.schedule(scheduleID); ok {
 		name = updated.Name
 	}
-	s.mutex.RUnlock()
 
 	s.renderer.Redirect(w, r, "/", renderer.NewSuccessMessage(updateSuccessMessage, name, status))
 }
 
+func (s *Service) schedule(id string) (Schedule, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	schedule, ok := s.schedules[id]
+
+	return schedule, ok
+}
+
 func (s *Service) HandleSensors(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkForm(r, sensorRole(r.Context(), r.PathValue("id"))); err != nil {
+		s.renderer.Error(w, r, nil, err)
+		return
+	}
+
 	if r.FormValue("method") != http.MethodPatch {
 		s.renderer.Error(w, r, nil, model.WrapMethodNotAllowed(errors.New("invalid method for updating sensor")))
 		return
@@ -145,14 +331,13 @@ func (s *Service) HandleSensors(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if id == "all" {
-		for _, sensor := range s.v2Service.Sensors() {
-			if _, err := s.v2Service.UpdateSensor(r.Context(), sensor.ID, statusBool); err != nil {
-				s.renderer.Error(w, r, nil, fmt.Errorf("update sensor `%s`: %w", id, err))
-				return
-			}
-		}
+	name, err := s.updateSensor(r.Context(), id, statusBool)
+	if err != nil {
+		s.renderer.Error(w, r, nil, err)
+		return
+	}
 
+	if id == allSensors {
 		stateName := "off"
 		if !statusBool {
 			stateName = "on"
@@ -162,18 +347,34 @@ func (s *Service) HandleSensors(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	motionSensor, err := s.v2Service.UpdateSensor(r.Context(), id, statusBool)
-	if err != nil {
-		s.renderer.Error(w, r, nil, fmt.Errorf("update sensor `%s`: %w", id, err))
-		return
-	}
-
-	name := motionSensor.Name + " Sensor"
-
 	stateName := "on"
 	if !statusBool {
 		stateName = "off"
 	}
 
-	s.renderer.Redirect(w, r, "/", renderer.NewSuccessMessage(updateSuccessMessage, name, stateName))
+	s.renderer.Redirect(w, r, "/", renderer.NewSuccessMessage(updateSuccessMessage, name+" Sensor", stateName))
+}
+
+// updateSensor enables or disables the given sensor, or every sensor if the ID is `all`. It returns the name of what has been updated
+func (s *Service) updateSensor(ctx context.Context, id string, enabled bool) (string, error) {
+	if id == allSensors {
+		for _, sensor := range scopedSensors(ctx, s.v2Service.Sensors()) {
+			if _, err := s.v2Service.UpdateSensor(ctx, sensor.ID, enabled); err != nil {
+				return "", fmt.Errorf("update sensor `%s`: %w", sensor.ID, err)
+			}
+		}
+
+		return "All sensors", nil
+	}
+
+	if err := checkSensorScope(ctx, id); err != nil {
+		return "", err
+	}
+
+	motionSensor, err := s.v2Service.UpdateSensor(ctx, id, enabled)
+	if err != nil {
+		return "", fmt.Errorf("update sensor `%s`: %w", id, err)
+	}
+
+	return motionSensor.Name, nil
 }
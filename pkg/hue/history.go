@@ -0,0 +1,200 @@
+package hue
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/httperror"
+	"github.com/ViBiOh/httputils/v4/pkg/httpjson"
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"github.com/ViBiOh/hue/pkg/history"
+)
+
+const (
+	defaultHistoryDuration = time.Hour * 24
+	minHistoryResolution   = time.Minute
+	maxHistoryPoints       = 2000
+	csvContentType         = "text/csv"
+)
+
+type historyResponse struct {
+	From       time.Time                          `json:"from"`
+	To         time.Time                          `json:"to"`
+	Metrics    map[history.Metric][]history.Point `json:"metrics"`
+	ID         string                             `json:"id"`
+	Name       string                             `json:"name"`
+	Resolution string                             `json:"resolution,omitempty"`
+}
+
+type historyQuery struct {
+	from       time.Time
+	to         time.Time
+	metrics    []history.Metric
+	resolution time.Duration
+	csv        bool
+}
+
+func (s *Service) HandleSensorHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if !s.history.Enabled() {
+		httperror.NotFound(ctx, w, errors.New("history is disabled"))
+		return
+	}
+
+	id := r.PathValue("id")
+
+	sensor, ok := s.v2Service.Sensor(id)
+	if !ok || checkSensorScope(ctx, id) != nil {
+		httperror.NotFound(ctx, w, fmt.Errorf("unknown sensor `%s`", id))
+		return
+	}
+
+	query, err := parseHistoryQuery(r, s.history.Retention())
+	if err != nil {
+		httperror.HandleError(ctx, w, err)
+		return
+	}
+
+	output := historyResponse{
+		ID:      sensor.ID,
+		Name:    sensor.Name,
+		From:    query.from,
+		To:      query.to,
+		Metrics: make(map[history.Metric][]history.Point, len(query.metrics)),
+	}
+
+	output.Resolution = query.resolution.String()
+
+	for _, metric := range query.metrics {
+		points, err := s.history.Query(sensor.ID, metric, query.from, query.to, query.resolution)
+		if err != nil {
+			httperror.InternalServerError(ctx, w, fmt.Errorf("query %s: %w", metric, err))
+			return
+		}
+
+		if points == nil {
+			points = []history.Point{}
+		}
+
+		output.Metrics[metric] = points
+	}
+
+	if query.csv {
+		writeHistoryCSV(w, r, query.metrics, output)
+		return
+	}
+
+	httpjson.Write(ctx, w, http.StatusOK, output)
+}
+
+// parseHistoryQuery reads the range and the resolution of the query, the range being limited to the retention and the resolution to a maximum number of points,
+// the default resolution being the smallest one within this maximum
+func parseHistoryQuery(r *http.Request, retention time.Duration) (historyQuery, error) {
+	params := r.URL.Query()
+
+	output := historyQuery{
+		to:      time.Now(),
+		metrics: history.Metrics,
+		csv:     params.Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), csvContentType),
+	}
+
+	var err error
+
+	if rawTo := params.Get("to"); len(rawTo) != 0 {
+		if output.to, err = time.Parse(time.RFC3339, rawTo); err != nil {
+			return output, model.WrapInvalid(fmt.Errorf("parse `to`: %w", err))
+		}
+	}
+
+	output.from = output.to.Add(-defaultHistoryDuration)
+	if rawFrom := params.Get("from"); len(rawFrom) != 0 {
+		if output.from, err = time.Parse(time.RFC3339, rawFrom); err != nil {
+			return output, model.WrapInvalid(fmt.Errorf("parse `from`: %w", err))
+		}
+	}
+
+	if !output.from.Before(output.to) {
+		return output, model.WrapInvalid(errors.New("`from` must be before `to`"))
+	}
+
+	span := output.to.Sub(output.from)
+
+	if span > retention {
+		return output, model.WrapInvalid(fmt.Errorf("range can't exceed the retention of %s", retention))
+	}
+
+	output.resolution = max(minHistoryResolution, (span+maxHistoryPoints-1)/maxHistoryPoints).Round(time.Second)
+
+	if rawResolution := params.Get("resolution"); len(rawResolution) != 0 {
+		if output.resolution, err = time.ParseDuration(rawResolution); err != nil {
+			return output, model.WrapInvalid(fmt.Errorf("parse `resolution`: %w", err))
+		}
+
+		if output.resolution < minHistoryResolution {
+			return output, model.WrapInvalid(fmt.Errorf("`resolution` must be at least %s", minHistoryResolution))
+		}
+
+		if span/output.resolution > maxHistoryPoints {
+			return output, model.WrapInvalid(fmt.Errorf("`resolution` must be at least %s for this range, at most %d points being returned", (span+maxHistoryPoints-1)/maxHistoryPoints, maxHistoryPoints))
+		}
+	}
+
+	if rawMetrics := params.Get("metrics"); len(rawMetrics) != 0 {
+		output.metrics = nil
+
+		for _, name := range strings.Split(rawMetrics, ",") {
+			metric, err := parseMetric(name)
+			if err != nil {
+				return output, err
+			}
+
+			output.metrics = append(output.metrics, metric)
+		}
+	}
+
+	return output, nil
+}
+
+func parseMetric(name string) (history.Metric, error) {
+	for _, metric := range history.Metrics {
+		if strings.EqualFold(string(metric), strings.TrimSpace(name)) {
+			return metric, nil
+		}
+	}
+
+	return "", model.WrapInvalid(fmt.Errorf("unknown metric `%s`", name))
+}
+
+func writeHistoryCSV(w http.ResponseWriter, r *http.Request, metrics []history.Metric, output historyResponse) {
+	w.Header().Set("Content-Type", csvContentType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", output.ID+".csv"))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write([]string{"timestamp", "metric", "value"}); err != nil {
+		logError(r.Context(), fmt.Errorf("write csv header: %w", err))
+		return
+	}
+
+	for _, metric := range metrics {
+		for _, point := range output.Metrics[metric] {
+			if err := writer.Write([]string{point.Timestamp.UTC().Format(time.RFC3339), string(metric), strconv.FormatFloat(point.Value, 'f', -1, 64)}); err != nil {
+				logError(r.Context(), fmt.Errorf("write csv: %w", err))
+				return
+			}
+		}
+	}
+
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		logError(r.Context(), fmt.Errorf("flush csv: %w", err))
+	}
+}
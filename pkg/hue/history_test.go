@@ -0,0 +1,113 @@
+package hue
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"github.com/ViBiOh/hue/pkg/history"
+)
+
+func TestParseHistoryQuery(t *testing.T) {
+	to := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		url     string
+		accept  string
+		want    historyQuery
+		wantErr error
+	}{
+		"range and resolution": {
+			url: "/api/sensors/1/history?from=2024-01-01T00:00:00Z&to=2024-01-02T00:00:00Z&resolution=1h",
+			want: historyQuery{
+				from:       to.Add(-time.Hour * 24),
+				to:         to,
+				metrics:    history.Metrics,
+				resolution: time.Hour,
+			},
+		},
+		"default range": {
+			url: "/api/sensors/1/history?to=2024-01-02T00:00:00Z&metrics=temperature,Motion&format=csv",
+			want: historyQuery{
+				from:       to.Add(-defaultHistoryDuration),
+				to:         to,
+				metrics:    []history.Metric{history.Temperature, history.Motion},
+				resolution: time.Minute,
+				csv:        true,
+			},
+		},
+		"csv from accept": {
+			url:    "/api/sensors/1/history?to=2024-01-02T00:00:00Z",
+			accept: "text/csv",
+			want: historyQuery{
+				from:       to.Add(-defaultHistoryDuration),
+				to:         to,
+				metrics:    history.Metrics,
+				resolution: time.Minute,
+				csv:        true,
+			},
+		},
+		"default resolution of a long range": {
+			url: "/api/sensors/1/history?from=2023-12-03T00:00:00Z&to=2024-01-02T00:00:00Z",
+			want: historyQuery{
+				from:       to.Add(-time.Hour * 24 * 30),
+				to:         to,
+				metrics:    history.Metrics,
+				resolution: time.Minute*21 + time.Second*36,
+			},
+		},
+		"range over retention": {
+			url:     "/api/sensors/1/history?from=0001-01-01T00:00:00Z&to=2024-01-02T00:00:00Z",
+			wantErr: model.ErrInvalid,
+		},
+		"resolution too small": {
+			url:     "/api/sensors/1/history?resolution=1s",
+			wantErr: model.ErrInvalid,
+		},
+		"too many points": {
+			url:     "/api/sensors/1/history?from=2023-12-26T00:00:00Z&to=2024-01-02T00:00:00Z&resolution=1m",
+			wantErr: model.ErrInvalid,
+		},
+		"inverted range": {
+			url:     "/api/sensors/1/history?from=2024-01-03T00:00:00Z&to=2024-01-02T00:00:00Z",
+			wantErr: model.ErrInvalid,
+		},
+		"unknown metric": {
+			url:     "/api/sensors/1/history?metrics=humidity",
+			wantErr: model.ErrInvalid,
+		},
+		"invalid resolution": {
+			url:     "/api/sensors/1/history?resolution=daily",
+			wantErr: model.ErrInvalid,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.url, nil)
+			if len(tc.accept) != 0 {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			got, gotErr := parseHistoryQuery(req, time.Hour*24*30)
+
+			if tc.wantErr != nil {
+				if !errors.Is(gotErr, tc.wantErr) {
+					t.Errorf("parseHistoryQuery() error = %v, want %v", gotErr, tc.wantErr)
+				}
+				return
+			}
+
+			if gotErr != nil {
+				t.Fatalf("parseHistoryQuery() error = %v", gotErr)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseHistoryQuery() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
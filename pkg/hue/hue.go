@@ -1,37 +1,63 @@
 package hue
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"maps"
 	"net/http"
+	"os"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/ViBiOh/flags"
+	"github.com/ViBiOh/httputils/v4/pkg/model"
 	"github.com/ViBiOh/httputils/v4/pkg/renderer"
+	"github.com/ViBiOh/hue/pkg/activity"
+	"github.com/ViBiOh/hue/pkg/auth"
+	"github.com/ViBiOh/hue/pkg/csrf"
+	"github.com/ViBiOh/hue/pkg/history"
 	v2 "github.com/ViBiOh/hue/pkg/v2"
 	"go.opentelemetry.io/otel/trace"
 )
 
+const DevicesPath = "/devices"
+
 type Service struct {
 	v2Service      *v2.Service
+	history        *history.Service
+	auth           *auth.Service
+	activity       *activity.Service
+	ownership      *ownership
+	charts         chartCache
+	states         map[string]State
+	presets        map[string][]preset
 	scenes         map[string]Scene
 	schedules      map[string]Schedule
 	renderer       *renderer.Service
+	config         configHue
+	configErr      error
 	tracerProvider trace.TracerProvider
 	bridgeUsername string
 	bridgeURL      string
 	configFileName string
+	location       *time.Location
 	mutex          sync.RWMutex
 	update         bool
+	metrics        bool
 }
 
 type Config struct {
 	BridgeIP       string
 	BridgeUsername string
 	Config         string
+	StateFile      string
 	Update         bool
+	Plan           bool
+	Validate       bool
 }
 
 func Flags(fs *flag.FlagSet, prefix string) *Config {
@@ -40,35 +66,195 @@ func Flags(fs *flag.FlagSet, prefix string) *Config {
 	flags.New("BridgeIP", "IP of Bridge").Prefix(prefix).DocPrefix("hue").StringVar(fs, &config.BridgeIP, "", nil)
 	flags.New("Username", "Username for Bridge").Prefix(prefix).DocPrefix("hue").StringVar(fs, &config.BridgeUsername, "", nil)
 	flags.New("Config", "Configuration filename").Prefix(prefix).DocPrefix("hue").StringVar(fs, &config.Config, "", nil)
+	flags.New("StateFile", "Filename for tracking the objects created on the bridge from the configuration, tags only if empty").Prefix(prefix).DocPrefix("hue").StringVar(fs, &config.StateFile, "", nil)
 	flags.New("Update", "Update configuration from file").Prefix(prefix).DocPrefix("hue").BoolVar(fs, &config.Update, false, nil)
+	flags.New("Plan", "Print the changes of the configuration file to the bridge and exit, without applying them").Prefix(prefix).DocPrefix("hue").BoolVar(fs, &config.Plan, false, nil)
+	flags.New("Validate", "Check the configuration file against the bridge, report its issues and exit").Prefix(prefix).DocPrefix("hue").BoolVar(fs, &config.Validate, false, nil)
 
 	return &config
 }
 
-func New(config *Config, tracerProvider trace.TracerProvider, rendererService *renderer.Service, v2Service *v2.Service) (*Service, error) {
+func New(config *Config, metrics bool, tracerProvider trace.TracerProvider, rendererService *renderer.Service, v2Service *v2.Service, historyService *history.Service, authService *auth.Service, activityService *activity.Service) (*Service, error) {
 	service := Service{
 		bridgeURL:      fmt.Sprintf("http://%s/api/%s", config.BridgeIP, config.BridgeUsername),
 		bridgeUsername: config.BridgeUsername,
 		configFileName: config.Config,
 		update:         config.Update,
+		metrics:        metrics,
 		renderer:       rendererService,
 		tracerProvider: tracerProvider,
 		v2Service:      v2Service,
+		history:        historyService,
+		auth:           authService,
+		activity:       activityService,
+	}
+
+	var err error
+
+	if service.config, err = loadConfig(config.Config); err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+
+	if service.ownership, err = newOwnership(config.StateFile); err != nil {
+		return nil, fmt.Errorf("state file: %w", err)
+	}
+
+	// The validation reports the invalid states, presets and profiles instead of failing, with their position in the file
+	service.states, err = buildStates(service.config)
+	if err != nil && !config.Validate {
+		return nil, fmt.Errorf("states: %w", err)
 	}
 
+	service.configErr = err
+
+	service.presets, err = buildPresets(service.config, service.states)
+	if err != nil && !config.Validate {
+		return nil, fmt.Errorf("presets: %w", err)
+	}
+
+	service.configErr = errors.Join(service.configErr, err)
+
+	if err = validateProfiles(v2Service.Profiles()); err != nil && !config.Validate {
+		return nil, fmt.Errorf("profiles: %w", err)
+	}
+
+	service.configErr = errors.Join(service.configErr, err)
+
+	activityService.Attribute(service.bridgeAutomation)
+
 	return &service, nil
 }
 
-func (s *Service) TemplateFunc(_ http.ResponseWriter, _ *http.Request) (renderer.Page, error) {
+func loadConfig(filename string) (configHue, error) {
+	var config configHue
+
+	if len(filename) == 0 {
+		return config, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return config, fmt.Errorf("open: %w", err)
+	}
+
+	defer func() { _ = file.Close() }()
+
+	if err = json.NewDecoder(file).Decode(&config); err != nil {
+		return config, fmt.Errorf("decode: %w", err)
+	}
+
+	return config, nil
+}
+
+// TemplateDevices renders the devices and their firmware
+func (s *Service) TemplateDevices(w http.ResponseWriter, r *http.Request) (renderer.Page, error) {
+	if !unscoped(r.Context()) {
+		return renderer.Page{}, model.WrapForbidden(errors.New("devices aren't available with an access token"))
+	}
+
+	return renderer.NewPage("devices", http.StatusOK, s.withUser(w, r, map[string]any{
+		"Devices": s.v2Service.Devices(),
+	})), nil
+}
+
+func (s *Service) TemplateFunc(w http.ResponseWriter, r *http.Request) (renderer.Page, error) {
+	ctx := r.Context()
+
+	sensors := scopedSensors(ctx, s.v2Service.Sensors())
+	groups := scopedGroups(ctx, s.v2Service.Groups())
+
+	content := s.withUser(w, r, map[string]any{
+		"Groups":            groups,
+		"HiddenGroups":      s.hiddenGroups(groups),
+		"GroupStates":       s.groupStates(groups),
+		"GroupScenes":       s.v2Service.Scenes(),
+		"SceneRecalls":      v2.SceneRecalls,
+		"ActiveSmartScenes": s.activeSmartScenes(groups),
+		"SmartSceneForms":   s.smartSceneForms(groups),
+		"Sensors":           sensors,
+		"MinMirek":          v2.MinMirek,
+		"MaxMirek":          v2.MaxMirek,
+		"DefaultMirek":      v2.DefaultMirek,
+		"Transitions":       transitions,
+		"HasState":          s.hasStates(),
+		"CustomStates":      s.customStates(),
+		"Presets":           s.activePresets(s.now()),
+	})
+
+	if s.history.Enabled() {
+		historyRange := r.URL.Query().Get("range")
+		if _, ok := historyRanges[historyRange]; !ok {
+			historyRange = defaultHistoryRange
+		}
+
+		content["HistoryRange"] = historyRange
+		content["HistoryRanges"] = historyRangeNames
+		content["History"] = s.sensorsHistory(ctx, sensors, historyRanges[historyRange])
+	}
+
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	return renderer.NewPage("public", http.StatusOK, map[string]any{
-		"Groups":    s.v2Service.Groups(),
-		"Scenes":    s.toScenes(),
-		"Schedules": s.toSchedules(),
-		"Sensors":   s.v2Service.Sensors(),
-	}), nil
+	content["Scenes"] = s.toScenes()
+
+	if unscoped(ctx) {
+		content["Schedules"] = s.toSchedules()
+	}
+
+	return renderer.NewPage("public", http.StatusOK, content), nil
+}
+
+// hiddenGroups returns the IDs of the groups hidden from the dashboard by their profile
+func (s *Service) hiddenGroups(groups []v2.Group) map[string]bool {
+	output := make(map[string]bool)
+
+	for _, group := range groups {
+		if profile, ok := s.v2Service.Profile(group.Name); ok && profile.Hidden {
+			output[group.ID] = true
+		}
+	}
+
+	return output
+}
+
+// groupStates tells which states are available for each group, by group ID
+func (s *Service) groupStates(groups []v2.Group) map[string]map[string]bool {
+	output := make(map[string]map[string]bool, len(groups))
+
+	for _, group := range groups {
+		states := make(map[string]bool, len(s.states))
+		for name := range s.states {
+			states[name] = s.allowsState(group.Name, name)
+		}
+
+		output[group.ID] = states
+	}
+
+	return output
+}
+
+// withUser adds what the templates need for rendering the controls allowed to the user, along with the csrf token of the forms
+func (s *Service) withUser(w http.ResponseWriter, r *http.Request, content map[string]any) map[string]any {
+	ctx := r.Context()
+
+	content["CSRF"] = csrf.Token(w, r)
+	content["Operator"] = s.can(ctx, auth.Operator)
+	content["Admin"] = s.can(ctx, auth.Admin)
+	content["ToggleSensors"] = s.can(ctx, sensorRole(ctx, ""))
+	content["ManageTokens"] = s.auth.Enabled() && s.can(ctx, auth.Admin)
+	content["Activity"] = s.activity.Enabled() && s.can(ctx, auth.Admin)
+
+	if user, ok := auth.UserFromContext(ctx); ok {
+		content["User"] = user
+		content["Logout"] = s.auth.LoginEnabled()
+	}
+
+	return content
+}
+
+// can checks if the user of the request has at least the given role
+func (s *Service) can(ctx context.Context, role auth.Role) bool {
+	return s.auth.Check(ctx, role) == nil
 }
 
 func (s *Service) toScenes() map[string]Scene {
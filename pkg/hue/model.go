@@ -1,28 +1,88 @@
 package hue
 
 import (
+	"math"
 	"regexp"
 	"time"
+
+	v2 "github.com/ViBiOh/hue/pkg/v2"
 )
 
 // State description
 type State struct {
+	XY         *[2]float64   `json:"xy,omitempty"`
 	On         bool          `json:"on"`
 	Duration   time.Duration `json:"transitiontime"`
 	Brightness uint64        `json:"bri"`
+	Mirek      int           `json:"ct,omitempty"`
 }
 
-// V1 transform state to its V1 version
+// V1 transform state to its V1 version, brightness being scaled from percent to the 1-254 range of the bridge
 func (s State) V1() map[string]any {
-	return map[string]any{
+	output := map[string]any{
 		"on":             s.On,
-		"bri":            s.Brightness / 100 * 254,
+		"bri":            max(1, int(math.Round(float64(s.Brightness)*254/100))),
 		"transitiontime": s.Duration.Milliseconds() / 100,
 	}
+
+	if s.XY != nil {
+		output["xy"] = *s.XY
+	} else if s.Mirek != 0 {
+		output["ct"] = s.Mirek
+	}
+
+	return output
+}
+
+// group transforms state to the update of a group
+func (s State) group() v2.GroupUpdate {
+	output := v2.GroupUpdate{
+		On:         s.On,
+		Brightness: float64(s.Brightness),
+		Mirek:      s.Mirek,
+		Transition: s.Duration,
+	}
+
+	if s.XY != nil {
+		var color v2.Color
+		color.XY.X = s.XY[0]
+		color.XY.Y = s.XY[1]
+
+		output.Color = &color
+	}
+
+	return output
+}
+
+// action transforms state to the state of a light in a scene, the light keeping its brightness and color if not set
+func (s State) action() v2.LightAction {
+	output := v2.LightAction{
+		On: &v2.On{On: s.On},
+	}
+
+	if !s.On {
+		return output
+	}
+
+	if s.Brightness > 0 {
+		output.Dimming = &v2.Dimming{Brightness: float64(s.Brightness)}
+	}
+
+	if s.XY != nil {
+		var color v2.Color
+		color.XY.X = s.XY[0]
+		color.XY.Y = s.XY[1]
+
+		output.Color = &color
+	} else if s.Mirek != 0 {
+		output.ColorTemperature = &v2.ColorTemperature{Mirek: s.Mirek}
+	}
+
+	return output
 }
 
 var (
-	// States available states of lights
+	// States are the default states of lights, extended or replaced by the ones of the configuration
 	States = map[string]State{
 		"off": {
 			On:       false,
@@ -100,8 +160,16 @@ type Rule struct {
 	ID         string      `json:"-"`
 	Status     string      `json:"status,omitempty"`
 	Name       string      `json:"name,omitempty"`
+	Owner      string      `json:"owner,omitempty"`
 	Actions    []Action    `json:"actions,omitempty"`
 	Conditions []Condition `json:"conditions,omitempty"`
+	// LastTriggered is set by the bridge, in UTC, and never sent back to it
+	LastTriggered string `json:"lasttriggered,omitempty"`
+}
+
+// BridgeConfig is the part of the configuration of the bridge read by the app
+type BridgeConfig struct {
+	Timezone string `json:"timezone"`
 }
 
 // Sensor description
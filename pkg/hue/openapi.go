@@ -0,0 +1,513 @@
+package hue
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ViBiOh/httputils/v4/pkg/httpjson"
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"github.com/ViBiOh/hue/pkg/activity"
+	"github.com/ViBiOh/hue/pkg/history"
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+const (
+	openAPIVersion = "3.0.3"
+	formContent    = "application/x-www-form-urlencoded"
+	jsonContent    = "application/json"
+	htmlContent    = "text/html"
+)
+
+type schema = map[string]any
+
+func (s *Service) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	httpjson.Write(r.Context(), w, http.StatusOK, s.openAPI())
+}
+
+func (s *Service) openAPI() map[string]any {
+	paths := map[string]any{
+		"/api/groups/{id}": map[string]any{
+			"post": operation("Apply a state to a group, or to every group without id, and redirect to the dashboard", "html", []any{pathParameter("id", "Group ID, empty for all groups")},
+				formBody(object([]string{"method", "csrf"}, withGroupPatch(schema{
+					"csrf":   csrfField(),
+					"method": enum(http.MethodPatch),
+				}))), redirectResponses()),
+		},
+		"/api/scenes/{id}": map[string]any{
+			"post": operation("Recall a scene in its group, or save a new one without id, and redirect to the dashboard", "html", []any{pathParameter("id", "Scene ID, empty for saving one")},
+				formBody(object([]string{"method", "csrf"}, withSceneRecall(schema{
+					"csrf":   csrfField(),
+					"method": enum(http.MethodPatch, http.MethodPost),
+					"group":  schema{"type": "string", "description": "With `POST` method, ID of the room or zone"},
+					"name":   schema{"type": "string", "maxLength": 32, "description": "With `POST` method"},
+					"light":  schema{"type": "array", "items": schema{"type": "string"}, "description": "With `POST` method, IDs of the lights whose state is given by the `on.{id}`, `brightness.{id}`, `mode.{id}` (`mirek` or `color`), `mirek.{id}` and `color.{id}` fields"},
+				}))), redirectResponses()),
+		},
+		"/api/smart_scenes/{id}": map[string]any{
+			"post": operation("Replace a smart scene, or create one without id, and redirect to the dashboard", "html", []any{pathParameter("id", "Smart scene ID, empty for creating one")},
+				formBody(object([]string{"method", "csrf", "name"}, schema{
+					"csrf":       csrfField(),
+					"method":     enum(http.MethodPost, http.MethodPut),
+					"name":       schema{"type": "string", "maxLength": 32},
+					"group":      schema{"type": "string", "description": "With `POST` method, ID of the room or zone"},
+					"transition": schema{"type": "string", "example": "1m", "description": "Go duration of the transition between slots"},
+					"row":        schema{"type": "array", "items": schema{"type": "string"}, "description": "Indexes of the slots, whose values are given by the `days.{index}`, `start.{index}` and `scene.{index}` fields"},
+				})), redirectResponses()),
+		},
+		"/api/schedules/{id}": map[string]any{
+			"post": operation("Enable, disable or reschedule a schedule and redirect to the dashboard", "html", []any{pathParameter("id", "Schedule ID")},
+				formBody(object([]string{"method", "csrf"}, schema{
+					"csrf":   csrfField(),
+					"method": enum(http.MethodPatch, http.MethodPut),
+					"status": schema{"type": "string", "enum": []string{"enabled", "disabled"}, "description": "With `PATCH` method"},
+					"days":   schema{"type": "array", "items": schema{"type": "integer"}, "description": "With `PUT` method, bit value of each day, Monday being 64 and Sunday 1"},
+					"time":   schema{"type": "string", "pattern": "^[0-9]{2}:[0-9]{2}$", "description": "With `PUT` method"},
+				})), redirectResponses()),
+		},
+		"/api/sensors/{id}": map[string]any{
+			"post": operation("Enable or disable a sensor, or every sensor with `all` id, and redirect to the dashboard", "html", []any{pathParameter("id", "Sensor ID or `all`")},
+				formBody(object([]string{"method", "on", "csrf"}, schema{
+					"csrf":   csrfField(),
+					"method": enum(http.MethodPatch),
+					"on":     schema{"type": "boolean"},
+				})), redirectResponses()),
+		},
+		"/api/tokens/{id}": map[string]any{
+			"post": operation("Create an access token without id, or revoke one, and redirect to the tokens page", "html", []any{pathParameter("id", "Token ID, empty for creating one")},
+				formBody(object([]string{"method", "csrf"}, schema{
+					"csrf":     csrfField(),
+					"method":   enum(http.MethodPost, http.MethodDelete),
+					"name":     schema{"type": "string", "description": "With `POST` method"},
+					"role":     schema{"type": "string", "enum": []string{"viewer", "operator"}, "description": "With `POST` method"},
+					"duration": schema{"type": "string", "enum": tokenDurationNames, "description": "With `POST` method"},
+					"groups":   schema{"type": "array", "items": schema{"type": "string"}, "description": "With `POST` method, IDs of the groups in scope"},
+					"sensors":  schema{"type": "array", "items": schema{"type": "string"}, "description": "With `POST` method, IDs of the sensors in scope"},
+				})), redirectResponses()),
+		},
+		"/api/sensors/{id}/history": map[string]any{
+			"get": operation("History of a sensor, in JSON or CSV", "history", []any{
+				pathParameter("id", "Sensor ID"),
+				queryParameter("from", "Start of the range, 24 hours before `to` by default", schema{"type": "string", "format": "date-time"}),
+				queryParameter("to", "End of the range, now by default", schema{"type": "string", "format": "date-time"}),
+				queryParameter("resolution", fmt.Sprintf("Go duration for aggregating values, e.g. `1h`, at least %s and at most %d points, the smallest within these bounds by default", minHistoryResolution, maxHistoryPoints), schema{"type": "string"}),
+				queryParameter("metrics", "Comma-separated list of metrics", schema{"type": "string"}),
+				queryParameter("format", "Output format", enum("json", "csv")),
+			}, nil, map[string]any{
+				"200": schema{"description": "History of the sensor", "content": map[string]any{
+					jsonContent:    schema{"schema": ref("History")},
+					csvContentType: schema{"schema": schema{"type": "string"}},
+				}},
+				"400": errorResponse("Invalid query"),
+				"404": errorResponse("Unknown sensor or history disabled"),
+			}),
+		},
+		"/api/v1/groups": map[string]any{
+			"get":   operation("List groups", "groups", nil, nil, jsonResponses(array(ref("Group")))),
+			"patch": operation("Apply a state to every group", "groups", nil, jsonBody(ref("GroupPatch")), noContentResponses()),
+		},
+		"/api/v1/groups/{id}": map[string]any{
+			"get":   operation("Get a group", "groups", []any{pathParameter("id", "Group ID")}, nil, jsonResponses(ref("Group"))),
+			"patch": operation("Apply a state to a group", "groups", []any{pathParameter("id", "Group ID")}, jsonBody(ref("GroupPatch")), noContentResponses()),
+		},
+		"/api/v1/scenes": map[string]any{
+			"get":  operation("List scenes of the rooms and zones", "scenes", []any{queryParameter("group", "Group ID of the scenes, all by default", schema{"type": "string"})}, nil, jsonResponses(array(ref("Scene")))),
+			"post": operation("Save the state of the lights of a group as a new scene, the current state being kept for the lights not given", "scenes", nil, jsonBody(ref("SceneCreate")), createdResponses(ref("Scene"))),
+		},
+		"/api/v1/scenes/{id}": map[string]any{
+			"get":    operation("Get a scene", "scenes", []any{pathParameter("id", "Scene ID")}, nil, jsonResponses(ref("Scene"))),
+			"delete": operation("Delete a scene", "scenes", []any{pathParameter("id", "Scene ID")}, nil, noContentResponses()),
+		},
+		"/api/v1/scenes/{id}/recall": map[string]any{
+			"post": operation("Recall a scene in its group", "scenes", []any{pathParameter("id", "Scene ID")}, jsonBody(ref("SceneRecall")), noContentResponses()),
+		},
+		"/api/v1/smart_scenes": map[string]any{
+			"get":  operation("List smart scenes of the rooms and zones", "smart scenes", []any{queryParameter("group", "Group ID of the smart scenes, all by default", schema{"type": "string"})}, nil, jsonResponses(array(ref("SmartScene")))),
+			"post": operation("Create a smart scene switching the scenes of a group across the week", "smart scenes", nil, jsonBody(ref("SmartScenePayload")), createdResponses(ref("SmartScene"))),
+		},
+		"/api/v1/smart_scenes/{id}": map[string]any{
+			"get":    operation("Get a smart scene", "smart scenes", []any{pathParameter("id", "Smart scene ID")}, nil, jsonResponses(ref("SmartScene"))),
+			"put":    operation("Replace the name, the slots and the transition of a smart scene", "smart scenes", []any{pathParameter("id", "Smart scene ID")}, jsonBody(ref("SmartScenePayload")), jsonResponses(ref("SmartScene"))),
+			"delete": operation("Delete a smart scene", "smart scenes", []any{pathParameter("id", "Smart scene ID")}, nil, noContentResponses()),
+		},
+		"/api/v1/lights": map[string]any{
+			"get": operation("List lights", "lights", nil, nil, jsonResponses(array(ref("Light")))),
+		},
+		"/api/v1/lights/{id}": map[string]any{
+			"get": operation("Get a light", "lights", []any{pathParameter("id", "Light ID")}, nil, jsonResponses(ref("Light"))),
+		},
+		"/api/v1/sensors": map[string]any{
+			"get": operation("List motion sensors", "sensors", nil, nil, jsonResponses(array(ref("Sensor")))),
+		},
+		"/api/v1/sensors/{id}": map[string]any{
+			"get":   operation("Get a motion sensor", "sensors", []any{pathParameter("id", "Sensor ID")}, nil, jsonResponses(ref("Sensor"))),
+			"patch": operation("Enable or disable a motion sensor, or every sensor with `all` id", "sensors", []any{pathParameter("id", "Sensor ID or `all`")}, jsonBody(ref("SensorPatch")), noContentResponses()),
+		},
+		"/api/v1/schedules": map[string]any{
+			"get": operation("List schedules", "schedules", nil, nil, jsonResponses(array(ref("Schedule")))),
+		},
+		"/api/v1/schedules/{id}": map[string]any{
+			"get":    operation("Get a schedule", "schedules", []any{pathParameter("id", "Schedule ID")}, nil, jsonResponses(ref("Schedule"))),
+			"patch":  operation("Enable or disable a schedule", "schedules", []any{pathParameter("id", "Schedule ID")}, jsonBody(ref("SchedulePatch")), jsonResponses(ref("Schedule"))),
+			"put":    operation("Reschedule a schedule", "schedules", []any{pathParameter("id", "Schedule ID")}, jsonBody(ref("SchedulePut")), jsonResponses(ref("Schedule"))),
+			"delete": operation("Delete a schedule", "schedules", []any{pathParameter("id", "Schedule ID")}, nil, noContentResponses()),
+		},
+		"/api/v1/states": map[string]any{
+			"get": operation("List states that can be applied to groups", "states", nil, nil, jsonResponses(array(ref("State")))),
+		},
+		"/api/v1/activity": map[string]any{
+			"get": operation("Activity log of the actions issued by the app and of the external changes, newest first", "activity", activityParameters(), nil, jsonResponses(array(ref("Activity")))),
+		},
+		EventsPath: map[string]any{
+			"get": operation("Server-sent events of groups and sensors changes, `group` and `sensor` events", "events", nil, nil, map[string]any{
+				"200": schema{"description": "Stream of events", "content": map[string]any{"text/event-stream": schema{"schema": schema{"type": "string"}}}},
+			}),
+		},
+		"/api/openapi.json": map[string]any{
+			"get": operation("This document", "meta", nil, nil, map[string]any{
+				"200": schema{"description": "OpenAPI document", "content": map[string]any{jsonContent: schema{"schema": schema{"type": "object"}}}},
+			}),
+		},
+		"/": map[string]any{
+			"get": operation("Dashboard", "html", []any{queryParameter("range", "Range of the history charts", enum(historyRangeNames...))}, nil, htmlResponses()),
+		},
+		DevicesPath: map[string]any{
+			"get": operation("Devices and their firmware", "html", nil, nil, htmlResponses()),
+		},
+		TokensPath: map[string]any{
+			"get": operation("Access tokens", "html", nil, nil, htmlResponses()),
+		},
+		ActivityPath: map[string]any{
+			"get": operation("Activity log", "html", activityParameters(), nil, htmlResponses()),
+		},
+	}
+
+	if s.metrics {
+		paths["/metrics"] = map[string]any{
+			"get": operation("Metrics in Prometheus text format", "meta", nil, nil, map[string]any{
+				"200": schema{"description": "Metrics", "content": map[string]any{"text/plain": schema{"schema": schema{"type": "string"}}}},
+			}),
+		}
+	}
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":   "Hue",
+			"version": model.Version(),
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": s.openAPISchemas(),
+		},
+	}
+}
+
+func (s *Service) openAPISchemas() map[string]any {
+	metrics := make([]string, len(history.Metrics))
+	for i, metric := range history.Metrics {
+		metrics[i] = "`" + string(metric) + "`"
+	}
+
+	return map[string]any{
+		"StateName": enum(s.stateNames()...),
+		"State": object([]string{"name", "on", "brightness", "duration"}, schema{
+			"name":       ref("StateName"),
+			"on":         schema{"type": "boolean"},
+			"brightness": schema{"type": "integer", "minimum": 0, "maximum": 100},
+			"duration":   schema{"type": "string", "description": "Go duration of the transition"},
+			"mirek":      schema{"type": "integer", "description": "Color temperature, the default one if absent"},
+			"xy":         schema{"type": "array", "items": schema{"type": "number"}, "minItems": 2, "maxItems": 2, "description": "Color in the CIE xy color space"},
+		}),
+		"Connectivity": object(nil, schema{
+			"since":  schema{"type": "string", "format": "date-time"},
+			"status": schema{"type": "string", "enum": []string{"connected", "disconnected", "connectivity_issue", "unidirectional_incoming"}},
+		}),
+		"Group": object([]string{"id", "name", "kind", "lights", "on", "brightness", "plug"}, schema{
+			"id":          schema{"type": "string"},
+			"name":        schema{"type": "string"},
+			"kind":        schema{"type": "string", "enum": []string{"room", "zone", "bridge_home"}},
+			"lights":      array(schema{"type": "string"}),
+			"unreachable": array(schema{"type": "string"}),
+			"brightness":  schema{"type": "number"},
+			"on":          schema{"type": "boolean"},
+			"plug":        schema{"type": "boolean"},
+		}),
+		"Light": object([]string{"id", "name", "archetype", "connectivity", "brightness", "on"}, schema{
+			"id":                schema{"type": "string"},
+			"name":              schema{"type": "string"},
+			"archetype":         schema{"type": "string"},
+			"connectivity":      ref("Connectivity"),
+			"brightness":        schema{"type": "number"},
+			"color_temperature": schema{"type": "integer", "description": "In mirek"},
+			"on":                schema{"type": "boolean"},
+		}),
+		"Sensor": object([]string{"id", "name", "enabled", "motion"}, schema{
+			"id":                schema{"type": "string"},
+			"id_v1":             schema{"type": "string"},
+			"motion_id":         schema{"type": "string"},
+			"name":              schema{"type": "string"},
+			"battery_state":     schema{"type": "string"},
+			"battery_level":     schema{"type": "integer"},
+			"light_level_id":    schema{"type": "string"},
+			"light_level_id_v1": schema{"type": "string"},
+			"light_level":       schema{"type": "integer"},
+			"connectivity":      ref("Connectivity"),
+			"temperature":       schema{"type": "number"},
+			"enabled":           schema{"type": "boolean"},
+			"motion":            schema{"type": "boolean"},
+		}),
+		"Schedule": object([]string{"id", "command"}, schema{
+			"id":          schema{"type": "string"},
+			"name":        schema{"type": "string"},
+			"description": schema{"type": "string"},
+			"localtime":   schema{"type": "string", "example": "W124/T07:30:00"},
+			"status":      schema{"type": "string", "enum": []string{"enabled", "disabled"}},
+			"managed":     schema{"type": "boolean", "description": "Created from the configuration file, absent otherwise"},
+			"command": object(nil, schema{
+				"address": schema{"type": "string"},
+				"body":    schema{"type": "object"},
+				"method":  schema{"type": "string"},
+			}),
+		}),
+		"History": object([]string{"id", "name", "from", "to", "metrics"}, schema{
+			"id":         schema{"type": "string"},
+			"name":       schema{"type": "string"},
+			"from":       schema{"type": "string", "format": "date-time"},
+			"to":         schema{"type": "string", "format": "date-time"},
+			"resolution": schema{"type": "string"},
+			"metrics": schema{
+				"type": "object",
+				"additionalProperties": array(object([]string{"timestamp", "value"}, schema{
+					"timestamp": schema{"type": "string", "format": "date-time"},
+					"value":     schema{"type": "number"},
+				})),
+				"description": "Points by metric, among " + strings.Join(metrics, ", "),
+			},
+		}),
+		"Activity": object([]string{"time", "source", "kind", "target", "state"}, schema{
+			"time":   schema{"type": "string", "format": "date-time"},
+			"source": enum(activitySources()...),
+			"actor":  schema{"type": "string", "description": "User issuing the action"},
+			"ip":     schema{"type": "string"},
+			"kind":   enum(activityKinds()...),
+			"target": schema{"type": "string", "description": "ID of the group, sensor or schedule"},
+			"name":   schema{"type": "string"},
+			"state":  schema{"type": "string", "example": "on at 100% in 5s"},
+			"error":  schema{"type": "string"},
+		}),
+		"Scene": object([]string{"id", "name", "group", "status", "colors", "dynamic"}, schema{
+			"id":      schema{"type": "string"},
+			"name":    schema{"type": "string"},
+			"group":   schema{"type": "string", "description": "ID of the room or zone"},
+			"status":  schema{"type": "string", "enum": []string{"inactive", "static", "dynamic_palette"}},
+			"colors":  array(schema{"type": "string", "pattern": "^#[0-9a-f]{6}$"}),
+			"dynamic": schema{"type": "boolean", "description": "True if the scene has a palette to cycle through"},
+		}),
+		"GroupPatch":  object(nil, withGroupPatch(schema{})),
+		"SceneRecall": object(nil, withSceneRecall(schema{})),
+		"SmartSceneSlot": object([]string{"start", "scene"}, schema{
+			"start": schema{"type": "string", "pattern": "^([0-9]{2}:[0-9]{2}|sunset)$", "description": "HH:MM time or `sunset`"},
+			"scene": schema{"type": "string", "description": "ID or name of a scene of the group"},
+		}),
+		"SmartSceneWeek": object([]string{"days", "slots"}, schema{
+			"days":  schema{"type": "array", "items": enum(v2.Weekdays...)},
+			"slots": array(ref("SmartSceneSlot")),
+		}),
+		"SmartScenePayload": object([]string{"name", "week"}, schema{
+			"name":       schema{"type": "string", "maxLength": 32},
+			"group":      schema{"type": "string", "description": "ID of the room or zone, when creating"},
+			"transition": schema{"type": "string", "example": "1m", "description": "Go duration of the transition between slots"},
+			"week":       array(ref("SmartSceneWeek")),
+		}),
+		"SmartScene": object([]string{"id", "name", "group", "transition", "week", "active", "managed"}, schema{
+			"id":          schema{"type": "string"},
+			"name":        schema{"type": "string"},
+			"group":       schema{"type": "string", "description": "ID of the room or zone"},
+			"transition":  schema{"type": "string"},
+			"week":        array(ref("SmartSceneWeek")),
+			"active":      schema{"type": "boolean"},
+			"active_slot": ref("SmartSceneSlot"),
+			"managed":     schema{"type": "boolean", "description": "Created from the configuration file"},
+		}),
+		"SceneCreate": object([]string{"name", "group"}, schema{
+			"name":   schema{"type": "string", "maxLength": 32},
+			"group":  schema{"type": "string", "description": "ID of the room or zone"},
+			"lights": schema{"type": "object", "additionalProperties": ref("GroupPatch"), "description": "State of the lights by light ID, transitions being ignored"},
+		}),
+		"SensorPatch": object([]string{"enabled"}, schema{
+			"enabled": schema{"type": "boolean"},
+		}),
+		"SchedulePatch": object([]string{"status"}, schema{
+			"status": schema{"type": "string", "enum": []string{"enabled", "disabled"}},
+		}),
+		"SchedulePut": object([]string{"time", "days"}, schema{
+			"time": schema{"type": "string", "pattern": "^[0-9]{2}:[0-9]{2}$"},
+			"days": array(enum("monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday")),
+		}),
+		"Error": object([]string{"error"}, schema{
+			"error": schema{"type": "string"},
+		}),
+	}
+}
+
+// withGroupPatch adds the fields of a group state: a preset, and the values overriding it
+func withGroupPatch(properties schema) schema {
+	properties["state"] = ref("StateName")
+	properties["on"] = schema{"type": "boolean", "description": "True by default without preset"}
+	properties["brightness"] = schema{"type": "integer", "minimum": 0, "maximum": maxBrightness, "description": "Percentage, 100 by default without preset"}
+	properties["mirek"] = schema{"type": "integer", "minimum": v2.MinMirek, "maximum": v2.MaxMirek, "description": "Color temperature, from cold to warm"}
+	properties["color"] = schema{"type": "string", "pattern": "^#[0-9a-fA-F]{6}$", "description": "Color, exclusive with mirek"}
+	properties["transition"] = schema{"type": "string", "example": "10m", "description": fmt.Sprintf("Go duration of the transition, up to %s", maxTransition)}
+
+	return properties
+}
+
+// withSceneRecall adds the fields of a scene recall
+func withSceneRecall(properties schema) schema {
+	recalls := make([]string, len(v2.SceneRecalls))
+	for i, recall := range v2.SceneRecalls {
+		recalls[i] = string(recall)
+	}
+
+	properties["action"] = schema{"type": "string", "enum": recalls, "description": "`active` by default"}
+	properties["brightness"] = schema{"type": "number", "minimum": 0, "maximum": maxBrightness, "description": "Percentage overriding the one of the scene, if not zero"}
+
+	return properties
+}
+
+func activityParameters() []any {
+	return []any{
+		queryParameter("source", "Source of the entries", enum(activitySources()...)),
+		queryParameter("kind", "Kind of target of the entries", enum(activityKinds()...)),
+		queryParameter("target", "ID or part of the name of the target", schema{"type": "string"}),
+		queryParameter("from", "Start of the range", schema{"type": "string", "format": "date-time"}),
+		queryParameter("to", "End of the range", schema{"type": "string", "format": "date-time"}),
+		queryParameter("limit", fmt.Sprintf("Number of entries, %d by default", defaultActivityLimit), schema{"type": "integer", "minimum": 1, "maximum": maxActivityLimit}),
+	}
+}
+
+func activitySources() []string {
+	output := make([]string, len(activity.Sources))
+	for i, source := range activity.Sources {
+		output[i] = string(source)
+	}
+
+	return output
+}
+
+func activityKinds() []string {
+	output := make([]string, len(activity.Kinds))
+	for i, kind := range activity.Kinds {
+		output[i] = string(kind)
+	}
+
+	return output
+}
+
+func operation(summary, tag string, parameters []any, body, responses map[string]any) map[string]any {
+	output := map[string]any{
+		"summary":   summary,
+		"tags":      []string{tag},
+		"responses": responses,
+	}
+
+	if len(parameters) != 0 {
+		output["parameters"] = parameters
+	}
+
+	if body != nil {
+		output["requestBody"] = body
+	}
+
+	return output
+}
+
+func pathParameter(name, description string) map[string]any {
+	return map[string]any{"name": name, "in": "path", "required": true, "description": description, "schema": schema{"type": "string"}}
+}
+
+func queryParameter(name, description string, value schema) map[string]any {
+	return map[string]any{"name": name, "in": "query", "description": description, "schema": value}
+}
+
+func jsonBody(value schema) map[string]any {
+	return map[string]any{"required": true, "content": map[string]any{jsonContent: schema{"schema": value}}}
+}
+
+func formBody(value schema) map[string]any {
+	return map[string]any{"required": true, "content": map[string]any{formContent: schema{"schema": value}}}
+}
+
+func jsonResponses(value schema) map[string]any {
+	return map[string]any{
+		"200": schema{"description": "Success", "content": map[string]any{jsonContent: schema{"schema": value}}},
+		"400": errorResponse("Invalid request"),
+		"401": errorResponse("Not authenticated, when authentication is enabled"),
+		"403": errorResponse("Role not allowed, when authentication is enabled"),
+		"404": errorResponse("Not found"),
+		"500": errorResponse("Internal error"),
+	}
+}
+
+func createdResponses(value schema) map[string]any {
+	responses := jsonResponses(value)
+	responses["201"] = responses["200"]
+	delete(responses, "200")
+
+	return responses
+}
+
+func noContentResponses() map[string]any {
+	return map[string]any{
+		"204": schema{"description": "Success"},
+		"400": errorResponse("Invalid request"),
+		"401": errorResponse("Not authenticated, when authentication is enabled"),
+		"403": errorResponse("Role not allowed, when authentication is enabled"),
+		"404": errorResponse("Not found"),
+		"500": errorResponse("Internal error"),
+	}
+}
+
+func csrfField() schema {
+	return schema{"type": "string", "description": "Token of the session, given in the `hue_csrf` cookie, or in the `X-CSRF-Token` header"}
+}
+
+func redirectResponses() map[string]any {
+	return map[string]any{
+		"302":     schema{"description": "Redirect to the dashboard with a success message"},
+		"default": schema{"description": "Error page", "content": map[string]any{htmlContent: schema{"schema": schema{"type": "string"}}}},
+	}
+}
+
+func htmlResponses() map[string]any {
+	return map[string]any{
+		"200": schema{"description": "HTML page", "content": map[string]any{htmlContent: schema{"schema": schema{"type": "string"}}}},
+	}
+}
+
+func errorResponse(description string) map[string]any {
+	return map[string]any{"description": description, "content": map[string]any{jsonContent: schema{"schema": ref("Error")}}}
+}
+
+func object(required []string, properties schema) schema {
+	output := schema{"type": "object", "properties": properties}
+
+	if len(required) != 0 {
+		output["required"] = required
+	}
+
+	return output
+}
+
+func array(items schema) schema {
+	return schema{"type": "array", "items": items}
+}
+
+func enum(values ...string) schema {
+	return schema{"type": "string", "enum": values}
+}
+
+func ref(name string) schema {
+	return schema{"$ref": "#/components/schemas/" + name}
+}
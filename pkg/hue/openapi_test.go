@@ -0,0 +1,54 @@
+package hue
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+var openAPIRef = regexp.MustCompile(`"\$ref":"#/components/schemas/([^"]+)"`)
+
+func TestOpenAPI(t *testing.T) {
+	cases := map[string]struct {
+		instance *Service
+		paths    []string
+	}{
+		"references and paths": {
+			instance: &Service{v2Service: &v2.Service{}, states: States},
+			paths:    []string{"/api/groups/{id}", "/api/v1/groups/{id}", "/api/v1/schedules/{id}", "/api/sensors/{id}/history", "/api/v1/activity", "/api/v1/scenes", "/api/v1/scenes/{id}", "/api/v1/smart_scenes/{id}", "/api/smart_scenes/{id}", "/api/v1/scenes/{id}/recall", "/api/scenes/{id}", "/api/openapi.json"},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			spec := tc.instance.openAPI()
+
+			payload, err := json.Marshal(spec)
+			if err != nil {
+				t.Fatalf("marshal: %s", err)
+			}
+
+			schemas := spec["components"].(map[string]any)["schemas"].(map[string]any)
+
+			for _, match := range openAPIRef.FindAllSubmatch(payload, -1) {
+				if _, ok := schemas[string(match[1])]; !ok {
+					t.Errorf("openAPI() references unknown schema `%s`", match[1])
+				}
+			}
+
+			paths := spec["paths"].(map[string]any)
+			for _, path := range tc.paths {
+				if _, ok := paths[path]; !ok {
+					t.Errorf("openAPI() has no path `%s`", path)
+				}
+			}
+
+			stateNames := schemas["StateName"].(schema)["enum"].([]string)
+			if len(stateNames) != len(States) {
+				t.Errorf("openAPI() has %d states, want %d", len(stateNames), len(States))
+			}
+		})
+	}
+}
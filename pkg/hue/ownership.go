@@ -0,0 +1,221 @@
+package hue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+// managedDescription tags the schedules, and the scenes and smart scenes through their appdata, created by the app
+const managedDescription = "Managed by hue"
+
+const (
+	kindRule       = "rule"
+	kindSchedule   = "schedule"
+	kindScene      = "scene"
+	kindSmartScene = "smart_scene"
+)
+
+// objectRef is an object of the bridge, by kind and ID
+type objectRef struct {
+	Kind string
+	ID   string
+}
+
+// ownership tracks the objects of the bridge created by the app from its configuration, for never touching the ones of the Hue app or other integrations
+type ownership struct {
+	objects  map[string]map[string]struct{}
+	filename string
+	mutex    sync.RWMutex
+}
+
+func newOwnership(filename string) (*ownership, error) {
+	store := ownership{
+		filename: filename,
+		objects:  make(map[string]map[string]struct{}),
+	}
+
+	if len(filename) == 0 {
+		slog.Warn("No state file configured, only the tagged objects of the bridge are known as managed by the app")
+		return &store, nil
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &store, nil
+		}
+
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	var objects map[string][]string
+	if err = json.Unmarshal(content, &objects); err != nil {
+		return nil, fmt.Errorf("unmarshal `%s`: %w", filename, err)
+	}
+
+	for kind, ids := range objects {
+		for _, id := range ids {
+			store.add(objectRef{Kind: kind, ID: id})
+		}
+	}
+
+	return &store, nil
+}
+
+func (o *ownership) owns(kind, id string) bool {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	_, ok := o.objects[kind][id]
+
+	return ok
+}
+
+// ids returns the IDs of the objects of the given kind, sorted
+func (o *ownership) ids(kind string) []string {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	return slices.Sorted(maps.Keys(o.objects[kind]))
+}
+
+func (o *ownership) track(refs ...objectRef) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for _, ref := range refs {
+		o.add(ref)
+	}
+}
+
+func (o *ownership) forget(refs ...objectRef) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for _, ref := range refs {
+		delete(o.objects[ref.Kind], ref.ID)
+	}
+}
+
+// add tracks the object. The mutex has to be held
+func (o *ownership) add(ref objectRef) {
+	if o.objects[ref.Kind] == nil {
+		o.objects[ref.Kind] = make(map[string]struct{})
+	}
+
+	o.objects[ref.Kind][ref.ID] = struct{}{}
+}
+
+// save writes the objects to the state file, through a temporary one for never leaving it half written
+func (o *ownership) save() error {
+	if len(o.filename) == 0 {
+		return nil
+	}
+
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	objects := make(map[string][]string, len(o.objects))
+	for kind, ids := range o.objects {
+		objects[kind] = slices.Sorted(maps.Keys(ids))
+	}
+
+	content, err := json.MarshalIndent(objects, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(o.filename), filepath.Base(o.filename)+".*")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+
+	if _, err = file.Write(content); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+
+		return fmt.Errorf("write: %w", err)
+	}
+
+	if err = file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+
+		return fmt.Errorf("close: %w", err)
+	}
+
+	return os.Rename(file.Name(), o.filename)
+}
+
+// forgetDeleted stops tracking the object deleted from the bridge outside of the reconciliation
+func (s *Service) forgetDeleted(ctx context.Context, ref objectRef) {
+	s.ownership.forget(ref)
+
+	if err := s.ownership.save(); err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "save state file", slog.Any("error", err))
+	}
+}
+
+// ownedRule tells if the rule is tracked, or was created with the username of the app, the bridge tagging rules with the key of their owner
+func (s *Service) ownedRule(rule Rule) bool {
+	return s.ownership.owns(kindRule, rule.ID) || rule.Owner == s.bridgeUsername
+}
+
+// ownedSchedule tells if the schedule is tracked or tagged, schedules created before tagging being recognized by their command calling the bridge with the username of the app
+func (s *Service) ownedSchedule(schedule Schedule) bool {
+	return s.ownership.owns(kindSchedule, schedule.ID) || schedule.Description == managedDescription || strings.HasPrefix(schedule.Command.Address, fmt.Sprintf("/api/%s/", s.bridgeUsername))
+}
+
+// ownsScene tells if the scene is tracked or tagged
+func (s *Service) ownsScene(scene v2.Scene) bool {
+	return s.ownership.owns(kindScene, scene.ID) || scene.Metadata.Appdata == managedDescription
+}
+
+// ownsSmartScene tells if the smart scene is tracked or tagged
+func (s *Service) ownsSmartScene(smartScene v2.SmartScene) bool {
+	return s.ownership.owns(kindSmartScene, smartScene.ID) || smartScene.Metadata.Appdata == managedDescription
+}
+
+// ownedSceneIDs returns the IDs of the scenes tracked or tagged, sorted
+func (s *Service) ownedSceneIDs() []string {
+	ids := s.ownership.ids(kindScene)
+
+	for _, scenes := range s.v2Service.Scenes() {
+		for _, scene := range scenes {
+			if scene.Metadata.Appdata == managedDescription && !slices.Contains(ids, scene.ID) {
+				ids = append(ids, scene.ID)
+			}
+		}
+	}
+
+	slices.Sort(ids)
+
+	return ids
+}
+
+// ownedSmartSceneIDs returns the IDs of the smart scenes tracked or tagged, sorted
+func (s *Service) ownedSmartSceneIDs() []string {
+	ids := s.ownership.ids(kindSmartScene)
+
+	for _, smartScenes := range s.v2Service.SmartScenes() {
+		for _, smartScene := range smartScenes {
+			if smartScene.Metadata.Appdata == managedDescription && !slices.Contains(ids, smartScene.ID) {
+				ids = append(ids, smartScene.ID)
+			}
+		}
+	}
+
+	slices.Sort(ids)
+
+	return ids
+}
@@ -0,0 +1,48 @@
+package hue
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOwnership(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+
+	store, err := newOwnership(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.track(objectRef{Kind: kindRule, ID: "1"}, objectRef{Kind: kindSchedule, ID: "2"}, objectRef{Kind: kindRule, ID: "3"})
+	store.forget(objectRef{Kind: kindRule, ID: "3"})
+
+	if err = store.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var objects map[string][]string
+	if err = json.Unmarshal(content, &objects); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(objects[kindRule], []string{"1"}) || !slices.Equal(objects[kindSchedule], []string{"2"}) {
+		t.Errorf("save() = %s", content)
+	}
+
+	reloaded, err := newOwnership(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reloaded.owns(kindRule, "1") || !reloaded.owns(kindSchedule, "2") || reloaded.owns(kindRule, "3") {
+		t.Errorf("newOwnership() = %v", reloaded.objects)
+	}
+}
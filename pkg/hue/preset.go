@@ -0,0 +1,330 @@
+package hue
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+const presetLayout = "15:04"
+
+// preset overrides some states of a room during a time window of the day
+type preset struct {
+	states map[string]State
+	name   string
+	from   int
+	to     int
+}
+
+// activePreset is the preset currently applied to a room, as displayed in the dashboard
+type activePreset struct {
+	States map[string]string
+	Label  string
+}
+
+// timeSlot is a part of the day without preset change, the whole day if from equals to
+type timeSlot struct {
+	from int
+	to   int
+}
+
+// contains tells if the minute of the day is in the window, that may span midnight
+func (p preset) contains(minute int) bool {
+	if p.from < p.to {
+		return minute >= p.from && minute < p.to
+	}
+
+	return minute >= p.from || minute < p.to
+}
+
+func (p preset) window() string {
+	return formatMinute(p.from) + "-" + formatMinute(p.to)
+}
+
+func (p preset) label() string {
+	if len(p.name) != 0 {
+		return fmt.Sprintf("%s, %s", p.name, p.window())
+	}
+
+	return p.window()
+}
+
+func (ts timeSlot) wholeDay() bool {
+	return ts.from == ts.to
+}
+
+// condition restricts a rule to the slot, on the local time of the bridge
+func (ts timeSlot) condition() Condition {
+	return Condition{
+		Address:  "/config/localtime",
+		Operator: "in",
+		Value:    fmt.Sprintf("T%s:00/T%s:00", formatMinute(ts.from), formatMinute(ts.to)),
+	}
+}
+
+func formatMinute(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+func minuteOfDay(now time.Time) int {
+	return now.Hour()*60 + now.Minute()
+}
+
+func parseMinute(value string) (int, error) {
+	parsed, err := time.Parse(presetLayout, value)
+	if err != nil {
+		return 0, fmt.Errorf("`%s` is not a HH:MM time", value)
+	}
+
+	return minuteOfDay(parsed), nil
+}
+
+// buildPresets validates the presets of the configuration, by lowercased room name
+func buildPresets(config configHue, states map[string]State) (map[string][]preset, error) {
+	output := make(map[string][]preset, len(config.Presets))
+
+	var errs []error
+
+	for _, room := range slices.Sorted(maps.Keys(config.Presets)) {
+		if len(strings.TrimSpace(room)) == 0 {
+			errs = append(errs, configError{path: "presets", err: errors.New("room of a preset is required")})
+			continue
+		}
+
+		key := strings.ToLower(room)
+
+		for i, item := range config.Presets[room] {
+			path := fmt.Sprintf("presets.%s[%d]", room, i)
+
+			current, err := buildPreset(path, item, states)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("preset of `%s`: %w", room, err))
+				continue
+			}
+
+			index := slices.IndexFunc(output[key], func(other preset) bool {
+				return current.contains(other.from) || other.contains(current.from)
+			})
+			if index != -1 {
+				errs = append(errs, fmt.Errorf("presets of `%s`: %w", room, configError{path: path, err: fmt.Errorf("overlap: %s and %s", output[key][index].window(), current.window())}))
+				continue
+			}
+
+			output[key] = append(output[key], current)
+		}
+	}
+
+	return output, errors.Join(errs...)
+}
+
+// buildPreset validates the preset at the given path of the configuration file
+func buildPreset(path string, config configPreset, states map[string]State) (preset, error) {
+	var output preset
+	var err error
+
+	if output.from, err = parseMinute(config.From); err != nil {
+		return output, fmt.Errorf("from: %w", configError{path: path + ".from", err: err})
+	}
+
+	if output.to, err = parseMinute(config.To); err != nil {
+		return output, fmt.Errorf("to: %w", configError{path: path + ".to", err: err})
+	}
+
+	if output.from == output.to {
+		return output, configError{path: path, err: errors.New("from and to must differ")}
+	}
+
+	if len(config.States) == 0 {
+		return output, configError{path: path, err: fmt.Errorf("%s: a state is required", output.window())}
+	}
+
+	output.name = config.Name
+	output.states = make(map[string]State, len(config.States))
+
+	for name, patch := range config.States {
+		if len(patch.State) == 0 {
+			patch.State = name
+		}
+
+		if output.states[name], err = patch.resolve(states); err != nil {
+			return output, fmt.Errorf("%s: state `%s`: %w", output.window(), name, configError{path: path + ".states." + name, err: err})
+		}
+	}
+
+	return output, nil
+}
+
+// presetAt returns the preset of the room at the given minute of the day
+func (s *Service) presetAt(room string, minute int) (preset, bool) {
+	for _, item := range s.presets[strings.ToLower(room)] {
+		if item.contains(minute) {
+			return item, true
+		}
+	}
+
+	return preset{}, false
+}
+
+// roomStates returns the states of the room at the given minute of the day
+func (s *Service) roomStates(room string, minute int) map[string]State {
+	item, hasPreset := s.presetAt(room, minute)
+	profile, hasProfile := s.v2Service.Profile(room)
+
+	if !hasPreset && !hasProfile {
+		return s.states
+	}
+
+	output := maps.Clone(s.states)
+
+	for name, state := range item.states {
+		output[name] = state
+	}
+
+	if hasProfile {
+		for name, state := range output {
+			if !profile.Allows(name) {
+				delete(output, name)
+				continue
+			}
+
+			_, fromPreset := item.states[name]
+			output[name] = profileState(profile, name, state, fromPreset)
+		}
+	}
+
+	return output
+}
+
+// profileState applies the brightness and the color temperature of the profile
+func profileState(profile v2.RoomProfile, name string, state State, fromPreset bool) State {
+	if !state.On {
+		return state
+	}
+
+	if brightness, ok := profile.Brightness[name]; ok && !fromPreset {
+		state.Brightness = brightness
+	}
+
+	if state.XY == nil && state.Mirek == 0 {
+		state.Mirek = profile.Mirek()
+	}
+
+	return state
+}
+
+// validateProfiles returns every invalid value of the room profiles, at its path in the configuration
+func validateProfiles(profiles map[string]v2.RoomProfile) error {
+	var errs []error
+
+	for _, room := range slices.Sorted(maps.Keys(profiles)) {
+		for _, item := range profiles[room].Validate() {
+			errs = append(errs, fmt.Errorf("room `%s`: %w", room, configError{path: "rooms." + room + "." + item.Path, err: item.Err}))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// allowsState tells if the state can be applied to the room, according to its profile
+func (s *Service) allowsState(room, name string) bool {
+	profile, ok := s.v2Service.Profile(room)
+
+	return !ok || profile.Allows(name)
+}
+
+// roomState returns the named state of the room at the given minute of the day, an error if it doesn't exist
+func (s *Service) roomState(room, name string, minute int) (State, error) {
+	state, ok := s.roomStates(room, minute)[name]
+	if !ok {
+		return state, fmt.Errorf("unknown state `%s`", name)
+	}
+
+	return state, nil
+}
+
+// timeSlots splits the day by the presets of the rooms, the whole day being returned if none has one
+func (s *Service) timeSlots(rooms []string) []timeSlot {
+	var bounds []int
+
+	for _, room := range rooms {
+		for _, item := range s.presets[strings.ToLower(room)] {
+			bounds = append(bounds, item.from, item.to)
+		}
+	}
+
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+
+	if len(bounds) == 0 {
+		return []timeSlot{{}}
+	}
+
+	key := func(minute int) string {
+		var output strings.Builder
+
+		for _, room := range rooms {
+			if item, ok := s.presetAt(room, minute); ok {
+				output.WriteString(item.window())
+			}
+
+			output.WriteString("|")
+		}
+
+		return output.String()
+	}
+
+	var output []timeSlot
+
+	for i, bound := range bounds {
+		slot := timeSlot{from: bound, to: bounds[(i+1)%len(bounds)]}
+
+		if last := len(output) - 1; last >= 0 && key(output[last].from) == key(slot.from) {
+			output[last].to = slot.to
+			continue
+		}
+
+		output = append(output, slot)
+	}
+
+	if last := len(output) - 1; last > 0 && key(output[last].from) == key(output[0].from) {
+		output[0].from = output[last].from
+		output = output[:last]
+	}
+
+	if len(output) == 1 {
+		return []timeSlot{{}}
+	}
+
+	return output
+}
+
+// activePresets returns the presets applied to the rooms at the given time, by room name
+func (s *Service) activePresets(now time.Time) map[string]activePreset {
+	output := make(map[string]activePreset)
+	minute := minuteOfDay(now)
+
+	for _, group := range s.v2Service.Groups() {
+		item, ok := s.presetAt(group.Name, minute)
+		if !ok {
+			continue
+		}
+
+		current := activePreset{
+			Label:  item.label(),
+			States: make(map[string]string, len(item.states)),
+		}
+
+		for name, state := range item.states {
+			current.States[name] = state.group().String()
+		}
+
+		output[group.Name] = current
+	}
+
+	return output
+}
@@ -0,0 +1,215 @@
+package hue
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+func TestBuildPresets(t *testing.T) {
+	brightness := uint64(15)
+	mirek := 454
+
+	night := configPreset{Name: "Night", From: "23:00", To: "06:00", States: map[string]groupPatch{"on": {Brightness: &brightness, Mirek: &mirek}}}
+
+	cases := map[string]struct {
+		config  configHue
+		want    map[string][]preset
+		wantErr bool
+	}{
+		"none": {
+			config: configHue{},
+			want:   map[string][]preset{},
+		},
+		"night": {
+			config: configHue{Presets: map[string][]configPreset{"Bedroom": {night}}},
+			want: map[string][]preset{"bedroom": {{
+				name:   "Night",
+				from:   23 * 60,
+				to:     6 * 60,
+				states: map[string]State{"on": {On: true, Brightness: 15, Mirek: 454, Duration: time.Second * 3}},
+			}}},
+		},
+		"overlap": {
+			config:  configHue{Presets: map[string][]configPreset{"Bedroom": {night, {From: "05:00", To: "08:00", States: night.States}}}},
+			wantErr: true,
+		},
+		"empty window": {
+			config:  configHue{Presets: map[string][]configPreset{"Bedroom": {{From: "05:00", To: "05:00", States: night.States}}}},
+			wantErr: true,
+		},
+		"malformed time": {
+			config:  configHue{Presets: map[string][]configPreset{"Bedroom": {{From: "5pm", To: "06:00", States: night.States}}}},
+			wantErr: true,
+		},
+		"unknown state": {
+			config:  configHue{Presets: map[string][]configPreset{"Bedroom": {{From: "23:00", To: "06:00", States: map[string]groupPatch{"party": {Brightness: &brightness}}}}}},
+			wantErr: true,
+		},
+		"no state": {
+			config:  configHue{Presets: map[string][]configPreset{"Bedroom": {{From: "23:00", To: "06:00"}}}},
+			wantErr: true,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			got, err := buildPresets(tc.config, States)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("buildPresets() succeeded, want an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("buildPresets() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("buildPresets() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTimeSlots(t *testing.T) {
+	dimmed := map[string]State{"on": States["dimmed"]}
+
+	instance := Service{
+		v2Service: &v2.Service{},
+		states:    States,
+		presets: map[string][]preset{
+			"bedroom": {{from: 23 * 60, to: 6 * 60, states: dimmed}},
+			"kitchen": {{from: 22 * 60, to: 23 * 60, states: dimmed}, {from: 23 * 60, to: 7 * 60, states: dimmed}},
+		},
+	}
+
+	cases := map[string]struct {
+		rooms []string
+		want  []timeSlot
+	}{
+		"without preset": {
+			rooms: []string{"Office"},
+			want:  []timeSlot{{}},
+		},
+		"single room": {
+			rooms: []string{"Bedroom"},
+			want:  []timeSlot{{from: 6 * 60, to: 23 * 60}, {from: 23 * 60, to: 6 * 60}},
+		},
+		"several rooms": {
+			rooms: []string{"Bedroom", "Kitchen", "Office"},
+			want:  []timeSlot{{from: 6 * 60, to: 7 * 60}, {from: 7 * 60, to: 22 * 60}, {from: 22 * 60, to: 23 * 60}, {from: 23 * 60, to: 6 * 60}},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := instance.timeSlots(tc.rooms); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("timeSlots() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRoomState(t *testing.T) {
+	instance := Service{
+		v2Service: &v2.Service{},
+		states:    States,
+		presets:   map[string][]preset{"bedroom": {{from: 23 * 60, to: 6 * 60, states: map[string]State{"on": States["dimmed"]}}}},
+	}
+
+	cases := map[string]struct {
+		room   string
+		name   string
+		minute int
+		want   State
+	}{
+		"day": {
+			room:   "Bedroom",
+			name:   "on",
+			minute: 12 * 60,
+			want:   States["on"],
+		},
+		"night": {
+			room:   "Bedroom",
+			name:   "on",
+			minute: 2 * 60,
+			want:   States["dimmed"],
+		},
+		"not overridden": {
+			room:   "Bedroom",
+			name:   "off",
+			minute: 2 * 60,
+			want:   States["off"],
+		},
+		"other room": {
+			room:   "Office",
+			name:   "on",
+			minute: 2 * 60,
+			want:   States["on"],
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			got, err := instance.roomState(tc.room, tc.name, tc.minute)
+			if err != nil {
+				t.Fatalf("roomState() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("roomState() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProfileState(t *testing.T) {
+	profile := v2.RoomProfile{Temperature: "warm", Brightness: map[string]uint64{"on": 70}}
+
+	cases := map[string]struct {
+		name       string
+		state      State
+		fromPreset bool
+		want       State
+	}{
+		"on": {
+			name:  "on",
+			state: States["on"],
+			want:  State{On: true, Brightness: 70, Mirek: 370, Duration: time.Second * 3},
+		},
+		"preset": {
+			name:       "on",
+			state:      States["dimmed"],
+			fromPreset: true,
+			want:       State{On: true, Brightness: 0, Mirek: 370, Duration: time.Second * 3},
+		},
+		"explicit mirek": {
+			name:  "half",
+			state: State{On: true, Brightness: 50, Mirek: 200},
+			want:  State{On: true, Brightness: 50, Mirek: 200},
+		},
+		"color": {
+			name:  "on",
+			state: State{On: true, Brightness: 100, XY: &[2]float64{0.5, 0.4}},
+			want:  State{On: true, Brightness: 70, XY: &[2]float64{0.5, 0.4}},
+		},
+		"off": {
+			name:  "off",
+			state: States["off"],
+			want:  States["off"],
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := profileState(profile, tc.name, tc.state, tc.fromPreset); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("profileState() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
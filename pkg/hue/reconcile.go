@@ -0,0 +1,594 @@
+package hue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+const (
+	changeCreate = "create"
+	changeUpdate = "update"
+	changeDelete = "delete"
+)
+
+// change is the creation, the update or the deletion of an object of the bridge for matching the configuration
+type change struct {
+	apply  func(context.Context) error
+	Kind   string
+	Action string
+	Name   string
+}
+
+func (c change) String() string {
+	return fmt.Sprintf("%s %s `%s`", c.Action, c.Kind, c.Name)
+}
+
+// plan is the changes for matching the configuration, along with the objects of the bridge owned by the app
+type plan struct {
+	scenes  map[string]struct{}
+	changes []change
+	matched []objectRef
+	stale   []objectRef
+}
+
+func (p *plan) add(item change) {
+	p.changes = append(p.changes, item)
+}
+
+func (p *plan) match(kind, id string) {
+	p.matched = append(p.matched, objectRef{Kind: kind, ID: id})
+}
+
+// diff compares the configuration with the objects of the bridge and returns the changes to apply, in order
+func (s *Service) diff(ctx context.Context, config configHue) (plan, error) {
+	output := plan{
+		scenes: make(map[string]struct{}),
+	}
+
+	schedules, err := s.listSchedules(ctx)
+	if err != nil {
+		return output, fmt.Errorf("list schedules: %w", err)
+	}
+
+	rules, err := s.listRules(ctx)
+	if err != nil {
+		return output, fmt.Errorf("list rules: %w", err)
+	}
+
+	groups := s.v2Service.Groups()
+
+	prune := s.diffSchedules(ctx, &output, groups, config.Schedules, schedules)
+	prune = s.diffSmartScenes(ctx, &output, groups, config.SmartScenes) && prune
+
+	tapRules, err := s.tapRules(groups, config.Taps)
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "plan tap rules", slog.Any("error", err))
+	}
+
+	pruneRules := err == nil
+
+	sensorRules, err := s.sensorRules(groups, config.Sensors)
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "plan sensor rules", slog.Any("error", err))
+	}
+
+	s.diffRules(&output, append(tapRules, sensorRules...), rules, pruneRules && err == nil)
+
+	if prune {
+		s.pruneScenes(&output)
+	}
+
+	s.staleObjects(&output, schedules, rules)
+
+	return output, nil
+}
+
+// apply performs the changes in order and saves the objects owned by the app
+func (s *Service) apply(ctx context.Context, changes plan) {
+	for _, item := range changes.changes {
+		if err := item.apply(ctx); err != nil {
+			slog.LogAttrs(ctx, slog.LevelError, "apply change", slog.String("change", item.String()), slog.Any("error", err))
+		}
+	}
+
+	s.ownership.track(changes.matched...)
+	s.ownership.forget(changes.stale...)
+
+	if err := s.ownership.save(); err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "save state file", slog.Any("error", err))
+	}
+}
+
+// diffSchedules adds the changes of the schedules and of their scenes. It tells if the stale ones have been deleted
+func (s *Service) diffSchedules(ctx context.Context, output *plan, groups []v2.Group, configs []ScheduleConfig, existing map[string]Schedule) bool {
+	var changes []change
+
+	kept := make(map[string]struct{}, len(configs))
+	prune := true
+
+	for _, config := range configs {
+		group, err := getGroup(groups, config.Group)
+		if err != nil {
+			slog.LogAttrs(ctx, slog.LevelError, "plan schedule", slog.String("name", config.Name), slog.Any("error", err))
+			prune = false
+			continue
+		}
+
+		if len(config.Scene) == 0 {
+			if err := s.diffScheduleScene(output, config, group); err != nil {
+				slog.LogAttrs(ctx, slog.LevelError, "plan schedule scene", slog.String("name", config.Name), slog.Any("error", err))
+				prune = false
+				continue
+			}
+		}
+
+		scene, sceneFound := s.scheduleScene(group.ID, config)
+		if !sceneFound && len(config.Scene) != 0 {
+			slog.LogAttrs(ctx, slog.LevelError, "plan schedule", slog.String("name", config.Name), slog.Any("error", fmt.Errorf("unknown scene `%s` in `%s`", config.Scene, group.Name)))
+			prune = false
+			continue
+		}
+
+		if sceneFound {
+			output.scenes[scene.ID] = struct{}{}
+		}
+
+		schedule := Schedule{
+			APISchedule: APISchedule{
+				Name:        config.Name,
+				Description: managedDescription,
+				Localtime:   config.Localtime,
+				Command: Action{
+					Address: fmt.Sprintf("/api/%s/groups/%s/action", s.bridgeUsername, group.IDV1),
+					Body: map[string]any{
+						"scene": scene.IDV1,
+					},
+					Method: http.MethodPut,
+				},
+			},
+		}
+
+		action := changeCreate
+
+		if current, ok := s.findSchedule(existing, kept, config.Name); ok {
+			kept[current.ID] = struct{}{}
+			output.match(kindSchedule, current.ID)
+
+			if sceneFound && sameSchedule(current.APISchedule, schedule.APISchedule) {
+				continue
+			}
+
+			schedule.ID = current.ID
+			action = changeUpdate
+		}
+
+		changes = append(changes, change{
+			Kind:   kindSchedule,
+			Action: action,
+			Name:   config.Name,
+			apply: func(ctx context.Context) error {
+				return s.applySchedule(ctx, schedule, group.ID, config)
+			},
+		})
+	}
+
+	if prune {
+		for _, id := range slices.Sorted(maps.Keys(existing)) {
+			if _, ok := kept[id]; ok || !s.ownedSchedule(existing[id]) {
+				continue
+			}
+
+			changes = append(changes, change{
+				Kind:   kindSchedule,
+				Action: changeDelete,
+				Name:   existing[id].Name,
+				apply: func(ctx context.Context) error {
+					if err := s.deleteSchedule(ctx, id); err != nil {
+						return err
+					}
+
+					s.ownership.forget(objectRef{Kind: kindSchedule, ID: id})
+
+					return nil
+				},
+			})
+		}
+	}
+
+	output.changes = append(output.changes, changes...)
+
+	return prune
+}
+
+// diffScheduleScene adds the creation or the update of the scene recalled by a schedule, if it doesn't match its state
+func (s *Service) diffScheduleScene(output *plan, config ScheduleConfig, group v2.Group) error {
+	lights, err := s.scheduleLights(config, group)
+	if err != nil {
+		return err
+	}
+
+	if err := s.sceneConflict(group, config.Name); err != nil {
+		return err
+	}
+
+	scene, ok := s.ownedScene(group.ID, config.Name)
+	if !ok {
+		output.add(change{
+			Kind:   kindScene,
+			Action: changeCreate,
+			Name:   config.Name,
+			apply: func(ctx context.Context) error {
+				scene, err := s.v2Service.CreateScene(ctx, group.ID, config.Name, managedDescription, lights)
+				if err != nil {
+					return err
+				}
+
+				s.ownership.track(objectRef{Kind: kindScene, ID: scene.ID})
+
+				return nil
+			},
+		})
+
+		return nil
+	}
+
+	output.match(kindScene, scene.ID)
+
+	if s.v2Service.SceneMatches(scene, lights) {
+		return nil
+	}
+
+	output.add(change{
+		Kind:   kindScene,
+		Action: changeUpdate,
+		Name:   config.Name,
+		apply: func(ctx context.Context) error {
+			_, err := s.v2Service.UpdateSceneActions(ctx, scene.ID, lights)
+			return err
+		},
+	})
+
+	return nil
+}
+
+// applySchedule creates or updates the schedule, recalling the scene of the group that may have been created by a previous change
+func (s *Service) applySchedule(ctx context.Context, schedule Schedule, groupID string, config ScheduleConfig) error {
+	scene, ok := s.scheduleScene(groupID, config)
+	if !ok {
+		return fmt.Errorf("unknown scene for schedule `%s`", config.Name)
+	}
+
+	if len(scene.IDV1) == 0 {
+		return fmt.Errorf("scene `%s` has no v1 id to be recalled by a schedule", scene.Metadata.Name)
+	}
+
+	schedule.Command.Body = map[string]any{
+		"scene": scene.IDV1,
+	}
+
+	if len(schedule.ID) != 0 {
+		return s.updateSchedule(ctx, schedule)
+	}
+
+	if err := s.createSchedule(ctx, &schedule); err != nil {
+		return err
+	}
+
+	s.ownership.track(objectRef{Kind: kindSchedule, ID: schedule.ID})
+
+	return nil
+}
+
+// diffSmartScenes adds the changes of the smart scenes. It tells if the stale ones have been deleted
+func (s *Service) diffSmartScenes(ctx context.Context, output *plan, groups []v2.Group, configs []smartScenePayload) bool {
+	smartScenes := s.v2Service.SmartScenes()
+
+	kept := make(map[string]struct{}, len(configs))
+	prune := true
+
+	for _, config := range configs {
+		group, err := getGroup(groups, config.Group)
+		if err != nil {
+			slog.LogAttrs(ctx, slog.LevelError, "plan smart scene", slog.String("name", config.Name), slog.Any("error", err))
+			prune = false
+			continue
+		}
+
+		definition, err := s.smartSceneDefinition(group, config)
+		if err != nil {
+			slog.LogAttrs(ctx, slog.LevelError, "plan smart scene", slog.String("name", config.Name), slog.Any("error", err))
+			prune = false
+			continue
+		}
+
+		definition.Appdata = managedDescription
+
+		for _, week := range definition.WeekTimeslots {
+			for _, timeslot := range week.Timeslots {
+				output.scenes[timeslot.Target.Rid] = struct{}{}
+			}
+		}
+
+		if err := s.smartSceneConflict(group, smartScenes[group.ID], config.Name); err != nil {
+			slog.LogAttrs(ctx, slog.LevelError, "plan smart scene", slog.String("name", config.Name), slog.Any("error", err))
+			prune = false
+			continue
+		}
+
+		current, ok := s.ownedSmartScene(smartScenes[group.ID], config.Name)
+		if !ok {
+			output.add(change{
+				Kind:   kindSmartScene,
+				Action: changeCreate,
+				Name:   config.Name,
+				apply: func(ctx context.Context) error {
+					smartScene, err := s.v2Service.CreateSmartScene(ctx, group.ID, definition)
+					if err != nil {
+						return err
+					}
+
+					s.ownership.track(objectRef{Kind: kindSmartScene, ID: smartScene.ID})
+
+					return nil
+				},
+			})
+
+			continue
+		}
+
+		kept[current.ID] = struct{}{}
+		output.match(kindSmartScene, current.ID)
+
+		if current.Matches(definition) {
+			continue
+		}
+
+		output.add(change{
+			Kind:   kindSmartScene,
+			Action: changeUpdate,
+			Name:   config.Name,
+			apply: func(ctx context.Context) error {
+				_, err := s.v2Service.UpdateSmartScene(ctx, current.ID, definition)
+				return err
+			},
+		})
+	}
+
+	if !prune {
+		return false
+	}
+
+	for _, id := range s.ownedSmartSceneIDs() {
+		smartScene, ok := s.v2Service.SmartScene(id)
+		if _, done := kept[id]; done || !ok {
+			continue
+		}
+
+		output.add(change{
+			Kind:   kindSmartScene,
+			Action: changeDelete,
+			Name:   smartScene.Metadata.Name,
+			apply: func(ctx context.Context) error {
+				if err := s.v2Service.DeleteSmartScene(ctx, id); err != nil {
+					return err
+				}
+
+				s.ownership.forget(objectRef{Kind: kindSmartScene, ID: id})
+
+				return nil
+			},
+		})
+	}
+
+	return true
+}
+
+// pruneScenes adds the deletion of the scenes of the app that aren't recalled anymore
+func (s *Service) pruneScenes(output *plan) {
+	for _, id := range s.ownedSceneIDs() {
+		scene, ok := s.v2Service.Scene(id)
+		if _, used := output.scenes[id]; used || !ok {
+			continue
+		}
+
+		output.add(change{
+			Kind:   kindScene,
+			Action: changeDelete,
+			Name:   scene.Metadata.Name,
+			apply: func(ctx context.Context) error {
+				if err := s.v2Service.DeleteScene(ctx, id); err != nil {
+					return err
+				}
+
+				s.ownership.forget(objectRef{Kind: kindScene, ID: id})
+
+				return nil
+			},
+		})
+	}
+}
+
+// diffRules adds the changes for matching the desired rules by name
+func (s *Service) diffRules(output *plan, desired []Rule, existing map[string]Rule, prune bool) {
+	ids := slices.Sorted(maps.Keys(existing))
+	kept := make(map[string]struct{}, len(desired))
+
+	for _, rule := range desired {
+		index := slices.IndexFunc(ids, func(id string) bool {
+			_, done := kept[id]
+			return !done && existing[id].Name == rule.Name && s.ownedRule(existing[id])
+		})
+
+		if index == -1 {
+			output.add(change{
+				Kind:   kindRule,
+				Action: changeCreate,
+				Name:   rule.Name,
+				apply: func(ctx context.Context) error {
+					if err := s.createRule(ctx, &rule); err != nil {
+						return err
+					}
+
+					s.ownership.track(objectRef{Kind: kindRule, ID: rule.ID})
+
+					return nil
+				},
+			})
+
+			continue
+		}
+
+		current := existing[ids[index]]
+		kept[current.ID] = struct{}{}
+		output.match(kindRule, current.ID)
+
+		if equivalentJSON(current.Conditions, rule.Conditions) && equivalentJSON(current.Actions, rule.Actions) {
+			continue
+		}
+
+		rule.ID = current.ID
+
+		output.add(change{
+			Kind:   kindRule,
+			Action: changeUpdate,
+			Name:   rule.Name,
+			apply: func(ctx context.Context) error {
+				return s.updateRule(ctx, rule)
+			},
+		})
+	}
+
+	if !prune {
+		return
+	}
+
+	for _, id := range ids {
+		if _, ok := kept[id]; ok || !s.ownedRule(existing[id]) {
+			continue
+		}
+
+		output.add(change{
+			Kind:   kindRule,
+			Action: changeDelete,
+			Name:   existing[id].Name,
+			apply: func(ctx context.Context) error {
+				if err := s.deleteRule(ctx, id); err != nil {
+					return err
+				}
+
+				s.ownership.forget(objectRef{Kind: kindRule, ID: id})
+
+				return nil
+			},
+		})
+	}
+}
+
+// staleObjects adds the objects of the state file that aren't on the bridge anymore, e.g. deleted from the Hue app
+func (s *Service) staleObjects(output *plan, schedules map[string]Schedule, rules map[string]Rule) {
+	exists := map[string]func(string) bool{
+		kindRule: func(id string) bool {
+			_, ok := rules[id]
+			return ok
+		},
+		kindSchedule: func(id string) bool {
+			_, ok := schedules[id]
+			return ok
+		},
+		kindScene: func(id string) bool {
+			_, ok := s.v2Service.Scene(id)
+			return ok
+		},
+		kindSmartScene: func(id string) bool {
+			_, ok := s.v2Service.SmartScene(id)
+			return ok
+		},
+	}
+
+	for _, kind := range slices.Sorted(maps.Keys(exists)) {
+		for _, id := range s.ownership.ids(kind) {
+			if !exists[kind](id) {
+				output.stale = append(output.stale, objectRef{Kind: kind, ID: id})
+			}
+		}
+	}
+}
+
+// findSchedule returns the first schedule of the app with the given name, not already kept
+func (s *Service) findSchedule(schedules map[string]Schedule, kept map[string]struct{}, name string) (Schedule, bool) {
+	for _, id := range slices.Sorted(maps.Keys(schedules)) {
+		if _, ok := kept[id]; !ok && schedules[id].Name == name && s.ownedSchedule(schedules[id]) {
+			return schedules[id], true
+		}
+	}
+
+	return Schedule{}, false
+}
+
+func sameSchedule(current, desired APISchedule) bool {
+	return current.Description == desired.Description && current.Localtime == desired.Localtime && current.Command.Address == desired.Command.Address && strings.EqualFold(current.Command.Method, desired.Command.Method) && equivalentJSON(current.Command.Body, desired.Command.Body)
+}
+
+// equivalentJSON tells if both values have the same JSON representation, numbers being compared within the rounding of the bridge
+func equivalentJSON(a, b any) bool {
+	return equivalentValue(jsonValue(a), jsonValue(b))
+}
+
+func jsonValue(value any) any {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+
+	var output any
+	if err = json.Unmarshal(payload, &output); err != nil {
+		return nil
+	}
+
+	return output
+}
+
+func equivalentValue(a, b any) bool {
+	switch aValue := a.(type) {
+	case map[string]any:
+		bValue, ok := b.(map[string]any)
+		if !ok || len(aValue) != len(bValue) {
+			return false
+		}
+
+		for key, item := range aValue {
+			if other, ok := bValue[key]; !ok || !equivalentValue(item, other) {
+				return false
+			}
+		}
+
+		return true
+	case []any:
+		bValue, ok := b.([]any)
+		if !ok || len(aValue) != len(bValue) {
+			return false
+		}
+
+		for i := range aValue {
+			if !equivalentValue(aValue[i], bValue[i]) {
+				return false
+			}
+		}
+
+		return true
+	case float64:
+		bValue, ok := b.(float64)
+		return ok && math.Abs(aValue-bValue) < 0.001
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,134 @@
+package hue
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestDiffRules(t *testing.T) {
+	tapRule := Rule{
+		Name:       "Tap 2.1.false",
+		Conditions: []Condition{{Address: "/sensors/2/state/buttonevent", Operator: "eq", Value: "34"}},
+		Actions:    []Action{{Address: "/groups/1/action", Method: "PUT", Body: State{On: true, Brightness: 100}.V1()}},
+	}
+
+	var bridgeRule Rule
+	if err := json.Unmarshal([]byte(`{"name": "Tap 2.1.false", "owner": "app", "status": "enabled", "conditions": [{"address": "/sensors/2/state/buttonevent", "operator": "eq", "value": "34"}], "actions": [{"address": "/groups/1/action", "method": "PUT", "body": {"on": true, "bri": 254, "transitiontime": 0}}]}`), &bridgeRule); err != nil {
+		t.Fatal(err)
+	}
+
+	bridgeRule.ID = "1"
+
+	changedRule := bridgeRule
+	changedRule.Conditions = []Condition{{Address: "/sensors/2/state/buttonevent", Operator: "eq", Value: "16"}}
+
+	foreignRule := Rule{ID: "2", Name: "Hue app", Owner: "other"}
+	ownedRule := Rule{ID: "3", Name: "Tap 2.4.false", Owner: "app"}
+	trackedRule := Rule{ID: "4", Name: "Shared key", Owner: "other"}
+	homonymRule := Rule{ID: "5", Name: "Tap 2.1.false", Owner: "other"}
+
+	cases := map[string]struct {
+		desired  []Rule
+		existing map[string]Rule
+		prune    bool
+		want     []string
+	}{
+		"create": {
+			desired:  []Rule{tapRule},
+			existing: map[string]Rule{"2": foreignRule},
+			prune:    true,
+			want:     []string{"create rule `Tap 2.1.false`"},
+		},
+		"unchanged": {
+			desired:  []Rule{tapRule},
+			existing: map[string]Rule{"1": bridgeRule},
+			prune:    true,
+			want:     nil,
+		},
+		"update": {
+			desired:  []Rule{tapRule},
+			existing: map[string]Rule{"1": changedRule},
+			prune:    true,
+			want:     []string{"update rule `Tap 2.1.false`"},
+		},
+		"delete owned only": {
+			desired:  []Rule{tapRule},
+			existing: map[string]Rule{"1": bridgeRule, "2": foreignRule, "3": ownedRule},
+			prune:    true,
+			want:     []string{"delete rule `Tap 2.4.false`"},
+		},
+		"delete tracked": {
+			desired:  []Rule{tapRule},
+			existing: map[string]Rule{"1": bridgeRule, "4": trackedRule},
+			prune:    true,
+			want:     []string{"delete rule `Shared key`"},
+		},
+		"homonym of another app": {
+			desired:  []Rule{tapRule},
+			existing: map[string]Rule{"5": homonymRule},
+			prune:    true,
+			want:     []string{"create rule `Tap 2.1.false`"},
+		},
+		"no prune": {
+			desired:  []Rule{tapRule},
+			existing: map[string]Rule{"1": bridgeRule, "3": ownedRule},
+			prune:    false,
+			want:     nil,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			service := Service{bridgeUsername: "app", ownership: &ownership{objects: make(map[string]map[string]struct{})}}
+			service.ownership.track(objectRef{Kind: kindRule, ID: "4"})
+
+			var output plan
+			service.diffRules(&output, tc.desired, tc.existing, tc.prune)
+
+			var got []string
+			for _, item := range output.changes {
+				got = append(got, item.String())
+			}
+
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("diffRules() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEquivalentJSON(t *testing.T) {
+	cases := map[string]struct {
+		a    any
+		b    any
+		want bool
+	}{
+		"same": {
+			a:    map[string]any{"scene": "abc"},
+			b:    map[string]any{"scene": "abc"},
+			want: true,
+		},
+		"rounded": {
+			a:    map[string]any{"xy": [2]float64{0.45678, 0.41}},
+			b:    map[string]any{"xy": []any{0.4568, 0.41}},
+			want: true,
+		},
+		"different": {
+			a: map[string]any{"bri": 254},
+			b: map[string]any{"bri": 127},
+		},
+		"missing key": {
+			a: map[string]any{"on": true, "ct": 300},
+			b: map[string]any{"on": true},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := equivalentJSON(tc.a, tc.b); got != tc.want {
+				t.Errorf("equivalentJSON() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
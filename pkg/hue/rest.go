@@ -0,0 +1,676 @@
+package hue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/httperror"
+	"github.com/ViBiOh/httputils/v4/pkg/httpjson"
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"github.com/ViBiOh/hue/pkg/auth"
+	"github.com/ViBiOh/hue/pkg/csrf"
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+const maxAPIBodySize = 1 << 16
+
+var days = map[string]int{
+	"monday":    monday,
+	"tuesday":   tuesday,
+	"wednesday": wednesday,
+	"thursday":  thursday,
+	"friday":    friday,
+	"saturday":  saturday,
+	"sunday":    sunday,
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type apiGroup struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Kind        string   `json:"kind"`
+	Lights      []string `json:"lights"`
+	Unreachable []string `json:"unreachable,omitempty"`
+	Brightness  float64  `json:"brightness"`
+	On          bool     `json:"on"`
+	Plug        bool     `json:"plug"`
+}
+
+type apiLight struct {
+	ID               string          `json:"id"`
+	Name             string          `json:"name"`
+	Archetype        string          `json:"archetype"`
+	Connectivity     v2.Connectivity `json:"connectivity"`
+	Brightness       float64         `json:"brightness"`
+	ColorTemperature int             `json:"color_temperature,omitempty"`
+	On               bool            `json:"on"`
+}
+
+type apiState struct {
+	Name       string      `json:"name"`
+	Duration   string      `json:"duration"`
+	Brightness uint64      `json:"brightness"`
+	XY         *[2]float64 `json:"xy,omitempty"`
+	Mirek      int         `json:"mirek,omitempty"`
+	On         bool        `json:"on"`
+}
+
+type apiScene struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Group   string   `json:"group"`
+	Status  string   `json:"status"`
+	Colors  []string `json:"colors"`
+	Dynamic bool     `json:"dynamic"`
+}
+
+type sceneRecall struct {
+	Action     v2.SceneRecall `json:"action"`
+	Brightness float64        `json:"brightness"`
+}
+
+type sceneCreate struct {
+	Lights map[string]groupPatch `json:"lights"`
+	Name   string                `json:"name"`
+	Group  string                `json:"group"`
+}
+
+type apiSmartScene struct {
+	ActiveSlot *smartSceneSlot `json:"active_slot,omitempty"`
+	ID         string          `json:"id"`
+	smartScenePayload
+	Active  bool `json:"active"`
+	Managed bool `json:"managed"`
+}
+
+type sensorPatch struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type schedulePatch struct {
+	Status string `json:"status"`
+}
+
+type schedulePut struct {
+	Time string   `json:"time"`
+	Days []string `json:"days"`
+}
+
+func (s *Service) HandleAPIGroups(w http.ResponseWriter, r *http.Request) {
+	groups := scopedGroups(r.Context(), s.v2Service.Groups())
+
+	output := make([]apiGroup, len(groups))
+	for i, group := range groups {
+		output[i] = toAPIGroup(group)
+	}
+
+	httpjson.Write(r.Context(), w, http.StatusOK, output)
+}
+
+func (s *Service) HandleAPIGroup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	group, ok := s.v2Service.Group(r.PathValue("id"))
+	if !ok || checkGroupScope(ctx, group.ID) != nil {
+		writeAPIError(ctx, w, model.WrapNotFound(fmt.Errorf("unknown group `%s`", r.PathValue("id"))))
+		return
+	}
+
+	httpjson.Write(ctx, w, http.StatusOK, toAPIGroup(group))
+}
+
+func (s *Service) HandleAPIGroupPatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := s.checkAPI(r, auth.Operator); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	payload, err := parseAPIBody[groupPatch](w, r)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	if _, _, err = s.updateGroups(ctx, r.PathValue("id"), payload); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) HandleAPIScenes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	groupID := r.URL.Query().Get("group")
+
+	scenes := s.v2Service.Scenes()
+	output := make([]apiScene, 0)
+
+	for _, group := range scopedGroups(ctx, s.v2Service.Groups()) {
+		if len(groupID) != 0 && group.ID != groupID {
+			continue
+		}
+
+		for _, scene := range scenes[group.ID] {
+			output = append(output, toAPIScene(scene))
+		}
+	}
+
+	httpjson.Write(ctx, w, http.StatusOK, output)
+}
+
+func (s *Service) HandleAPISceneCreate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := s.checkAPI(r, auth.Operator); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	payload, err := parseAPIBody[sceneCreate](w, r)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	scene, _, err := s.createScene(ctx, payload)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	httpjson.Write(ctx, w, http.StatusCreated, toAPIScene(scene))
+}
+
+func (s *Service) HandleAPIScene(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	scene, err := s.existingScene(ctx, r.PathValue("id"))
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	httpjson.Write(ctx, w, http.StatusOK, toAPIScene(scene))
+}
+
+func (s *Service) HandleAPISceneDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := s.checkAPI(r, auth.Admin); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	scene, err := s.existingScene(ctx, r.PathValue("id"))
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	if err = s.deleteScene(ctx, scene.ID); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) HandleAPISceneRecall(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := s.checkAPI(r, auth.Operator); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	payload, err := parseAPIBody[sceneRecall](w, r)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	if _, _, err = s.recallScene(ctx, r.PathValue("id"), payload); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) HandleAPISmartScenes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	groupID := r.URL.Query().Get("group")
+
+	smartScenes := s.v2Service.SmartScenes()
+	output := make([]apiSmartScene, 0)
+
+	for _, group := range scopedGroups(ctx, s.v2Service.Groups()) {
+		if len(groupID) != 0 && group.ID != groupID {
+			continue
+		}
+
+		for _, smartScene := range smartScenes[group.ID] {
+			output = append(output, s.toAPISmartScene(smartScene))
+		}
+	}
+
+	httpjson.Write(ctx, w, http.StatusOK, output)
+}
+
+func (s *Service) HandleAPISmartScene(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	smartScene, err := s.existingSmartScene(ctx, r.PathValue("id"))
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	httpjson.Write(ctx, w, http.StatusOK, s.toAPISmartScene(smartScene))
+}
+
+func (s *Service) HandleAPISmartSceneDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := s.checkAPI(r, auth.Admin); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	smartScene, err := s.existingSmartScene(ctx, r.PathValue("id"))
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	if err = s.deleteSmartScene(ctx, smartScene.ID); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) HandleAPISmartSceneCreate(w http.ResponseWriter, r *http.Request) {
+	s.writeSmartSceneSave(w, r, "", http.StatusCreated)
+}
+
+func (s *Service) HandleAPISmartScenePut(w http.ResponseWriter, r *http.Request) {
+	s.writeSmartSceneSave(w, r, r.PathValue("id"), http.StatusOK)
+}
+
+func (s *Service) writeSmartSceneSave(w http.ResponseWriter, r *http.Request, id string, status int) {
+	ctx := r.Context()
+
+	if err := s.checkAPI(r, auth.Admin); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	payload, err := parseAPIBody[smartScenePayload](w, r)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	smartScene, err := s.saveSmartScene(ctx, id, payload)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	httpjson.Write(ctx, w, status, s.toAPISmartScene(smartScene))
+}
+
+func (s *Service) HandleAPILights(w http.ResponseWriter, r *http.Request) {
+	lights := scopedLights(r.Context(), s.v2Service.Lights(), s.v2Service.Groups())
+
+	output := make([]apiLight, len(lights))
+	for i, light := range lights {
+		output[i] = toAPILight(light)
+	}
+
+	httpjson.Write(r.Context(), w, http.StatusOK, output)
+}
+
+func (s *Service) HandleAPILight(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	light, ok := s.v2Service.Light(r.PathValue("id"))
+	if ok {
+		ok = len(scopedLights(ctx, []v2.Light{light}, s.v2Service.Groups())) != 0
+	}
+
+	if !ok {
+		writeAPIError(ctx, w, model.WrapNotFound(fmt.Errorf("unknown light `%s`", r.PathValue("id"))))
+		return
+	}
+
+	httpjson.Write(ctx, w, http.StatusOK, toAPILight(light))
+}
+
+func (s *Service) HandleAPISensors(w http.ResponseWriter, r *http.Request) {
+	httpjson.Write(r.Context(), w, http.StatusOK, scopedSensors(r.Context(), s.v2Service.Sensors()))
+}
+
+func (s *Service) HandleAPISensor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sensor, ok := s.v2Service.Sensor(r.PathValue("id"))
+	if !ok || checkSensorScope(ctx, sensor.ID) != nil {
+		writeAPIError(ctx, w, model.WrapNotFound(fmt.Errorf("unknown sensor `%s`", r.PathValue("id"))))
+		return
+	}
+
+	httpjson.Write(ctx, w, http.StatusOK, sensor)
+}
+
+func (s *Service) HandleAPISensorPatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := s.checkAPI(r, sensorRole(ctx, r.PathValue("id"))); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	payload, err := parseAPIBody[sensorPatch](w, r)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	if payload.Enabled == nil {
+		writeAPIError(ctx, w, model.WrapInvalid(errors.New("`enabled` is required")))
+		return
+	}
+
+	if _, err = s.updateSensor(ctx, r.PathValue("id"), *payload.Enabled); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) HandleAPISchedules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if !unscoped(ctx) {
+		httpjson.Write(ctx, w, http.StatusOK, []Schedule{})
+		return
+	}
+
+	s.mutex.RLock()
+	output := s.toSchedules()
+	s.mutex.RUnlock()
+
+	httpjson.Write(ctx, w, http.StatusOK, output)
+}
+
+func (s *Service) HandleAPISchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	schedule, err := s.existingSchedule(ctx, r.PathValue("id"))
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	httpjson.Write(ctx, w, http.StatusOK, schedule)
+}
+
+func (s *Service) HandleAPISchedulePatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := s.checkAPI(r, auth.Admin); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	schedule, err := s.existingSchedule(ctx, r.PathValue("id"))
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	payload, err := parseAPIBody[schedulePatch](w, r)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	if payload.Status != "enabled" && payload.Status != "disabled" {
+		writeAPIError(ctx, w, model.WrapInvalid(fmt.Errorf("invalid status `%s`, must be `enabled` or `disabled`", payload.Status)))
+		return
+	}
+
+	s.writeScheduleUpdate(ctx, w, Schedule{ID: schedule.ID, APISchedule: APISchedule{Status: payload.Status}})
+}
+
+func (s *Service) HandleAPISchedulePut(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := s.checkAPI(r, auth.Admin); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	schedule, err := s.existingSchedule(ctx, r.PathValue("id"))
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	payload, err := parseAPIBody[schedulePut](w, r)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	localtime, err := payload.localtime()
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	s.writeScheduleUpdate(ctx, w, Schedule{ID: schedule.ID, APISchedule: APISchedule{Localtime: localtime}})
+}
+
+func (s *Service) HandleAPIScheduleDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := s.checkAPI(r, auth.Admin); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	schedule, err := s.existingSchedule(ctx, r.PathValue("id"))
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	if err = s.deleteSchedule(ctx, schedule.ID); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	if err = s.syncSchedules(ctx); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) HandleAPIStates(w http.ResponseWriter, r *http.Request) {
+	output := make([]apiState, 0, len(s.states))
+
+	for _, name := range s.stateNames() {
+		state := s.states[name]
+
+		output = append(output, apiState{
+			Name:       name,
+			On:         state.On,
+			Brightness: state.Brightness,
+			Mirek:      state.Mirek,
+			XY:         state.XY,
+			Duration:   state.Duration.String(),
+		})
+	}
+
+	httpjson.Write(r.Context(), w, http.StatusOK, output)
+}
+
+func (s *Service) existingSchedule(ctx context.Context, id string) (Schedule, error) {
+	schedule, ok := s.schedule(id)
+	if !ok || !unscoped(ctx) {
+		return schedule, model.WrapNotFound(fmt.Errorf("unknown schedule `%s`", id))
+	}
+
+	return schedule, nil
+}
+
+func (s *Service) writeScheduleUpdate(ctx context.Context, w http.ResponseWriter, schedule Schedule) {
+	if err := s.updateSchedule(ctx, schedule); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	if err := s.syncSchedules(ctx); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	updated, err := s.existingSchedule(ctx, schedule.ID)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	httpjson.Write(ctx, w, http.StatusOK, updated)
+}
+
+func (sp schedulePut) localtime() (string, error) {
+	if _, err := time.Parse("15:04", sp.Time); err != nil {
+		return "", model.WrapInvalid(fmt.Errorf("parse `time` `%s`, must be HH:MM: %w", sp.Time, err))
+	}
+
+	if len(sp.Days) == 0 {
+		return "", model.WrapInvalid(errors.New("`days` is required"))
+	}
+
+	var recurrence int
+
+	for _, day := range sp.Days {
+		value, ok := days[strings.ToLower(day)]
+		if !ok {
+			return "", model.WrapInvalid(fmt.Errorf("unknown day `%s`", day))
+		}
+
+		recurrence |= value
+	}
+
+	return formatLocaltime(recurrence, sp.Time), nil
+}
+
+func parseAPIBody[T any](w http.ResponseWriter, r *http.Request) (T, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAPIBodySize)
+
+	output, err := httpjson.Parse[T](r)
+	if err != nil {
+		return output, model.WrapInvalid(fmt.Errorf("parse body: %w", err))
+	}
+
+	return output, nil
+}
+
+// checkAPI verifies the role of the user and that the request hasn't been issued by another site
+func (s *Service) checkAPI(r *http.Request, role auth.Role) error {
+	if err := s.auth.Check(r.Context(), role); err != nil {
+		return err
+	}
+
+	return csrf.CheckOrigin(r)
+}
+
+func writeAPIError(ctx context.Context, w http.ResponseWriter, err error) {
+	status, message := httperror.ErrorStatus(err)
+	httperror.Log(ctx, err, status, message)
+
+	w.Header().Set("Cache-Control", "no-cache")
+	httpjson.Write(ctx, w, status, apiError{Error: message})
+}
+
+func toAPIGroup(group v2.Group) apiGroup {
+	output := apiGroup{
+		ID:          group.ID,
+		Name:        group.Name,
+		Kind:        group.Kind,
+		Plug:        group.Plug,
+		Lights:      make([]string, len(group.Lights)),
+		Unreachable: group.Unreachable(),
+	}
+
+	for i, light := range group.Lights {
+		output.Lights[i] = light.ID
+	}
+
+	for _, groupedLight := range group.GroupedLights {
+		output.On = output.On || groupedLight.On.On
+		output.Brightness = max(output.Brightness, groupedLight.Dimming.Brightness)
+	}
+
+	return output
+}
+
+func toAPIScene(scene v2.Scene) apiScene {
+	return apiScene{
+		ID:      scene.ID,
+		Name:    scene.Metadata.Name,
+		Group:   scene.Group.Rid,
+		Status:  scene.Status.Active,
+		Colors:  scene.Colors(),
+		Dynamic: scene.Dynamic(),
+	}
+}
+
+func (s *Service) toAPISmartScene(smartScene v2.SmartScene) apiSmartScene {
+	output := apiSmartScene{
+		ID:                smartScene.ID,
+		smartScenePayload: toSmartScenePayload(smartScene),
+		Active:            smartScene.Active(),
+		Managed:           s.ownsSmartScene(smartScene),
+	}
+
+	if slot, ok := smartScene.ActiveSlot(); ok {
+		output.ActiveSlot = &smartSceneSlot{
+			Start: slot.StartTime.String(),
+			Scene: slot.Target.Rid,
+		}
+	}
+
+	return output
+}
+
+func toAPILight(light v2.Light) apiLight {
+	return apiLight{
+		ID:               light.ID,
+		Name:             light.Metadata.Name,
+		Archetype:        light.Metadata.Archetype,
+		On:               light.On.On,
+		Brightness:       light.Dimming.Brightness,
+		ColorTemperature: light.ColorTemperature.Mirek,
+		Connectivity:     light.Connectivity,
+	}
+}
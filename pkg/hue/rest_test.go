@@ -0,0 +1,72 @@
+package hue
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+)
+
+func TestSchedulePutLocaltime(t *testing.T) {
+	cases := map[string]struct {
+		instance schedulePut
+		want     string
+		wantErr  error
+	}{
+		"week days": {
+			instance: schedulePut{
+				Time: "07:30",
+				Days: []string{"monday", "Tuesday", "wednesday", "thursday", "friday"},
+			},
+			want: "W124/T07:30:00",
+		},
+		"weekend": {
+			instance: schedulePut{
+				Time: "10:00",
+				Days: []string{"saturday", "sunday"},
+			},
+			want: "W003/T10:00:00",
+		},
+		"invalid time": {
+			instance: schedulePut{
+				Time: "7h30",
+				Days: []string{"monday"},
+			},
+			wantErr: model.ErrInvalid,
+		},
+		"no day": {
+			instance: schedulePut{
+				Time: "07:30",
+			},
+			wantErr: model.ErrInvalid,
+		},
+		"unknown day": {
+			instance: schedulePut{
+				Time: "07:30",
+				Days: []string{"someday"},
+			},
+			wantErr: model.ErrInvalid,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			got, gotErr := tc.instance.localtime()
+
+			if tc.wantErr != nil {
+				if !errors.Is(gotErr, tc.wantErr) {
+					t.Errorf("localtime() error = %v, want %v", gotErr, tc.wantErr)
+				}
+				return
+			}
+
+			if gotErr != nil {
+				t.Fatalf("localtime() error = %v", gotErr)
+			}
+
+			if got != tc.want {
+				t.Errorf("localtime() = `%s`, want `%s`", got, tc.want)
+			}
+		})
+	}
+}
@@ -7,11 +7,20 @@ import (
 
 func (s *Service) listRules(ctx context.Context) (map[string]Rule, error) {
 	var response map[string]Rule
-	return response, get(ctx, fmt.Sprintf("%s/rules", s.bridgeURL), &response)
+	if err := s.apiGet(ctx, "rules", &response); err != nil {
+		return nil, fmt.Errorf("get: %w", err)
+	}
+
+	for id, rule := range response {
+		rule.ID = id
+		response[id] = rule
+	}
+
+	return response, nil
 }
 
 func (s *Service) createRule(ctx context.Context, o *Rule) error {
-	id, err := create(ctx, fmt.Sprintf("%s/rules", s.bridgeURL), o)
+	id, err := s.apiCreate(ctx, "rules", o)
 	if err != nil {
 		return err
 	}
@@ -21,21 +30,10 @@ func (s *Service) createRule(ctx context.Context, o *Rule) error {
 	return nil
 }
 
-func (s *Service) deleteRule(ctx context.Context, id string) error {
-	return remove(ctx, fmt.Sprintf("%s/rules/%s", s.bridgeURL, id))
+func (s *Service) updateRule(ctx context.Context, o Rule) error {
+	return s.apiUpdate(ctx, "rules", o.ID, o)
 }
 
-func (s *Service) cleanRules(ctx context.Context) error {
-	rules, err := s.listRules(ctx)
-	if err != nil {
-		return err
-	}
-
-	for key := range rules {
-		if err := s.deleteRule(ctx, key); err != nil {
-			return err
-		}
-	}
-
-	return nil
+func (s *Service) deleteRule(ctx context.Context, id string) error {
+	return s.apiRemove(ctx, "rules", id)
 }
@@ -3,106 +3,161 @@ package hue
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strings"
 
+	"github.com/ViBiOh/httputils/v4/pkg/model"
 	v2 "github.com/ViBiOh/hue/pkg/v2"
 )
 
-func (s *Service) listScenes(ctx context.Context) (map[string]Scene, error) {
-	var response map[string]Scene
-
-	if err := get(ctx, fmt.Sprintf("%s/scenes", s.bridgeURL), &response); err != nil {
-		return nil, fmt.Errorf("get: %w", err)
+// groupScene returns the scene of the group with the given name
+func (s *Service) groupScene(groupID, name string) (v2.Scene, bool) {
+	for _, scene := range s.v2Service.Scenes()[groupID] {
+		if strings.EqualFold(scene.Metadata.Name, name) {
+			return scene, true
+		}
 	}
 
-	for id := range response {
-		scene, err := s.getScene(ctx, id)
-		if err != nil {
-			return nil, err
-		}
+	return v2.Scene{}, false
+}
 
-		response[id] = scene
+// ownedScene returns the scene of the group with the given name created by the app
+func (s *Service) ownedScene(groupID, name string) (v2.Scene, bool) {
+	for _, scene := range s.v2Service.Scenes()[groupID] {
+		if strings.EqualFold(scene.Metadata.Name, name) && s.ownsScene(scene) {
+			return scene, true
+		}
 	}
 
-	return response, nil
+	return v2.Scene{}, false
 }
 
-func (s *Service) getScene(ctx context.Context, id string) (Scene, error) {
-	var response Scene
-	if err := get(ctx, fmt.Sprintf("%s/scenes/%s", s.bridgeURL, id), &response); err != nil {
-		return response, err
+// sceneConflict returns an error if a scene of the group has the given name without being one of the app, for never taking it over
+func (s *Service) sceneConflict(group v2.Group, name string) error {
+	if _, ok := s.ownedScene(group.ID, name); ok {
+		return nil
 	}
 
-	response.ID = id
+	if _, ok := s.groupScene(group.ID, name); ok {
+		return fmt.Errorf("scene `%s` of `%s` isn't managed by the app", name, group.Name)
+	}
 
-	return response, nil
+	return nil
+}
+
+// scheduleScene returns the scene recalled by the schedule: the configured one, or the one of the app saved for the schedule recalling a state
+func (s *Service) scheduleScene(groupID string, config ScheduleConfig) (v2.Scene, bool) {
+	if len(config.Scene) != 0 {
+		return s.groupScene(groupID, config.Scene)
+	}
+
+	return s.ownedScene(groupID, config.Name)
 }
 
-func (s *Service) createScene(ctx context.Context, o *Scene) error {
-	id, err := create(ctx, fmt.Sprintf("%s/scenes", s.bridgeURL), o)
+// scheduleLights returns the state of every light of the scene saved for a schedule recalling a state, with the preset and the profile of the room at the time of the schedule
+func (s *Service) scheduleLights(config ScheduleConfig, group v2.Group) (map[string]v2.LightAction, error) {
+	minute, ok := localtimeMinute(config.Localtime)
+	if !ok {
+		minute = minuteOfDay(s.now())
+	}
+
+	state, err := s.roomState(group.Name, config.State, minute)
 	if err != nil {
+		return nil, err
+	}
+
+	lights := make(map[string]v2.LightAction, len(group.Lights))
+	for _, light := range group.Lights {
+		lights[light.ID] = state.action()
+	}
+
+	return lights, nil
+}
+
+// existingScene returns the scene with the given ID, the scenes of the groups out of scope being hidden
+func (s *Service) existingScene(ctx context.Context, id string) (v2.Scene, error) {
+	scene, ok := s.v2Service.Scene(id)
+	if !ok || checkGroupScope(ctx, scene.Group.Rid) != nil {
+		return scene, model.WrapNotFound(fmt.Errorf("unknown scene `%s`", id))
+	}
+
+	return scene, nil
+}
+
+// deleteScene deletes the scene from the bridge, the app forgetting it if it created it
+func (s *Service) deleteScene(ctx context.Context, id string) error {
+	if err := s.v2Service.DeleteScene(ctx, id); err != nil {
 		return err
 	}
 
-	o.ID = id
+	s.forgetDeleted(ctx, objectRef{Kind: kindScene, ID: id})
 
 	return nil
 }
 
-func (s *Service) createSceneFromScheduleConfig(ctx context.Context, config ScheduleConfig, group v2.Group) (Scene, error) {
-	if len(group.IDV1) == 0 {
-		return Scene{}, fmt.Errorf("unknown group id: %s", config.Group)
+// createScene saves the state of the lights of the group as a new scene, the current state being kept for the lights not given. It returns the scene and the name of its group
+func (s *Service) createScene(ctx context.Context, payload sceneCreate) (v2.Scene, string, error) {
+	if err := checkGroupScope(ctx, payload.Group); err != nil {
+		return v2.Scene{}, "", err
 	}
 
-	state, ok := States[config.State]
+	group, ok := s.v2Service.Group(payload.Group)
 	if !ok {
-		return Scene{}, fmt.Errorf("unknown state name: %s", config.State)
+		return v2.Scene{}, "", model.WrapNotFound(fmt.Errorf("unknown group `%s`", payload.Group))
 	}
 
-	lights := make([]string, 0, len(group.Lights))
-	for _, light := range group.Lights {
-		lights = append(lights, light.IDV1)
+	lights, err := s.v2Service.Snapshot(group.ID)
+	if err != nil {
+		return v2.Scene{}, "", err
 	}
 
-	scene := Scene{
-		APIScene: APIScene{
-			Name:    config.Name,
-			Lights:  lights,
-			Recycle: false,
-		},
-	}
+	states := s.roomStates(group.Name, minuteOfDay(s.now()))
 
-	if err := s.createScene(ctx, &scene); err != nil {
-		return scene, err
-	}
+	for id, patch := range payload.Lights {
+		if _, ok := lights[id]; !ok {
+			return v2.Scene{}, "", model.WrapInvalid(fmt.Errorf("light `%s` isn't part of `%s`", id, group.Name))
+		}
 
-	for _, light := range scene.Lights {
-		if err := s.updateSceneLightState(ctx, scene, light, state); err != nil {
-			return scene, err
+		state, err := patch.resolve(states)
+		if err != nil {
+			return v2.Scene{}, "", fmt.Errorf("light `%s`: %w", id, err)
 		}
-	}
 
-	return scene, nil
-}
+		lights[id] = state.action()
+	}
 
-func (s *Service) updateSceneLightState(ctx context.Context, o Scene, lightID string, state State) error {
-	return update(ctx, fmt.Sprintf("%s/scenes/%s/lightstates/%s", s.bridgeURL, o.ID, lightID), state.V1())
-}
+	scene, err := s.v2Service.CreateScene(ctx, group.ID, payload.Name, "", lights)
+	if err != nil {
+		return scene, "", err
+	}
 
-func (s *Service) deleteScene(ctx context.Context, id string) error {
-	return remove(ctx, fmt.Sprintf("%s/scenes/%s", s.bridgeURL, id))
+	return scene, group.Name, nil
 }
 
-func (s *Service) cleanScenes(ctx context.Context) error {
-	scenes, err := s.listScenes(ctx)
-	if err != nil {
-		return err
+// parseSceneForm reads the scene editor of the dashboard, each light having its fields suffixed by its ID and a mode for choosing between its color temperature and its color
+func parseSceneForm(r *http.Request) (sceneCreate, error) {
+	output := sceneCreate{
+		Name:   r.FormValue("name"),
+		Group:  r.FormValue("group"),
+		Lights: make(map[string]groupPatch),
 	}
 
-	for key := range scenes {
-		if err := s.deleteScene(ctx, key); err != nil {
-			return err
+	for _, id := range r.Form["light"] {
+		patch, err := parseGroupValues(func(name string) string {
+			return r.FormValue(name + "." + id)
+		})
+		if err != nil {
+			return output, fmt.Errorf("light `%s`: %w", id, err)
+		}
+
+		if r.FormValue("mode."+id) == "color" {
+			patch.Mirek = nil
+		} else {
+			patch.Color = ""
 		}
+
+		output.Lights[id] = patch
 	}
 
-	return nil
+	return output, nil
 }
@@ -4,16 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log/slog"
-	"net/http"
+	"strings"
 
-	v2 "github.com/ViBiOh/hue/pkg/v2"
+	"github.com/ViBiOh/hue/pkg/activity"
 )
 
 func (s *Service) listSchedules(ctx context.Context) (map[string]Schedule, error) {
 	var response map[string]Schedule
 
-	if err := get(ctx, fmt.Sprintf("%s/schedules", s.bridgeURL), &response); err != nil {
+	if err := s.apiGet(ctx, "schedules", &response); err != nil {
 		return nil, fmt.Errorf("get: %w", err)
 	}
 
@@ -27,46 +26,14 @@ func (s *Service) listSchedules(ctx context.Context) (map[string]Schedule, error
 }
 
 func (s *Service) createSchedule(ctx context.Context, o *Schedule) error {
-	id, err := create(ctx, fmt.Sprintf("%s/schedules", s.bridgeURL), o)
-	if err != nil {
-		return err
+	id, err := s.apiCreate(ctx, "schedules", o)
+	if err == nil {
+		o.ID = id
 	}
 
-	o.ID = id
+	s.activity.Record(ctx, activity.Schedule, o.ID, o.Name, "created", err)
 
-	return nil
-}
-
-func (s *Service) createScheduleFromConfig(ctx context.Context, config ScheduleConfig, groups []v2.Group) error {
-	targetGroup, err := getGroup(groups, config.Group)
-	if err != nil {
-		return err
-	}
-
-	scene, err := s.createSceneFromScheduleConfig(ctx, config, targetGroup)
-	if err != nil {
-		return err
-	}
-
-	schedule := &Schedule{
-		APISchedule: APISchedule{
-			Name:      config.Name,
-			Localtime: config.Localtime,
-			Command: Action{
-				Address: fmt.Sprintf("/api/%s/groups/%s/action", s.bridgeUsername, targetGroup.IDV1),
-				Body: map[string]any{
-					"scene": scene.ID,
-				},
-				Method: http.MethodPut,
-			},
-		},
-	}
-
-	if err := s.createSchedule(ctx, schedule); err != nil {
-		return err
-	}
-
-	return nil
+	return err
 }
 
 func (s *Service) updateSchedule(ctx context.Context, schedule Schedule) error {
@@ -74,32 +41,35 @@ func (s *Service) updateSchedule(ctx context.Context, schedule Schedule) error {
 		return errors.New("missing schedule ID to update")
 	}
 
-	return update(ctx, fmt.Sprintf("%s/schedules/%s", s.bridgeURL, schedule.ID), schedule.APISchedule)
+	err := s.apiUpdate(ctx, "schedules", schedule.ID, schedule.APISchedule)
+	s.recordSchedule(ctx, schedule.ID, scheduleActivityState(schedule.APISchedule), err)
+
+	return err
 }
 
 func (s *Service) deleteSchedule(ctx context.Context, id string) error {
-	return remove(ctx, fmt.Sprintf("%s/schedules/%s", s.bridgeURL, id))
+	err := s.apiRemove(ctx, "schedules", id)
+	s.recordSchedule(ctx, id, "deleted", err)
+
+	return err
 }
 
-func (s *Service) cleanSchedules(ctx context.Context) error {
-	schedules, err := s.listSchedules(ctx)
-	if err != nil {
-		return err
-	}
+func (s *Service) recordSchedule(ctx context.Context, id, state string, err error) {
+	schedule, _ := s.schedule(id)
+	s.activity.Record(ctx, activity.Schedule, id, schedule.Name, state, err)
+}
 
-	for key := range schedules {
-		if err := s.deleteSchedule(ctx, key); err != nil {
-			return err
-		}
-	}
+// scheduleActivityState describes the changes of a schedule in the activity log
+func scheduleActivityState(schedule APISchedule) string {
+	var changes []string
 
-	return nil
-}
+	if len(schedule.Status) != 0 {
+		changes = append(changes, schedule.Status)
+	}
 
-func (s *Service) configureSchedules(ctx context.Context, groups []v2.Group, schedules []ScheduleConfig) {
-	for _, config := range schedules {
-		if err := s.createScheduleFromConfig(ctx, config, groups); err != nil {
-			slog.LogAttrs(ctx, slog.LevelError, "create schedule", slog.Any("error", err))
-		}
+	if len(schedule.Localtime) != 0 {
+		changes = append(changes, "at "+schedule.Localtime)
 	}
+
+	return strings.Join(changes, ", ")
 }
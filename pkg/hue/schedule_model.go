@@ -2,10 +2,12 @@ package hue
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -26,6 +28,7 @@ const (
 type Schedule struct {
 	ID string `json:"id,omitempty"`
 	APISchedule
+	Managed bool `json:"managed,omitempty"`
 }
 
 // ByScheduleID sort Schedule by id
@@ -39,10 +42,11 @@ func (a ByScheduleID) Less(i, j int) bool {
 
 // APISchedule describe schedule as from Hue API
 type APISchedule struct {
-	Name      string `json:"name,omitempty"`
-	Localtime string `json:"localtime,omitempty"`
-	Command   Action `json:"command"`
-	Status    string `json:"status,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Localtime   string `json:"localtime,omitempty"`
+	Command     Action `json:"command"`
+	Status      string `json:"status,omitempty"`
 }
 
 // ScheduleConfig configuration (made simple)
@@ -51,6 +55,12 @@ type ScheduleConfig struct {
 	Localtime string
 	Group     string
 	State     string
+	Scene     string
+}
+
+// formatLocaltime formats a recurrence of days and a HH:MM time to the bridge's weekly local time
+func formatLocaltime(recurrence int, time string) string {
+	return fmt.Sprintf("W%03d/T%s:00", recurrence, time)
 }
 
 func recurrenceStr(recurrence int) string {
@@ -127,3 +137,65 @@ func (s Schedule) FormatLocalTime() string {
 
 	return fmt.Sprintf("%s at %s", recurrenceStr(recurrence), s.Localtime[6:])
 }
+
+// localtimeMinute returns the minute of the day of a weekly or daily local time of the bridge, e.g. `W124/T07:30:00`
+func localtimeMinute(localtime string) (int, bool) {
+	_, clock, ok := strings.Cut(localtime, "T")
+	if !ok || strings.HasPrefix(localtime, "PT") || strings.HasPrefix(localtime, "R") {
+		return 0, false
+	}
+
+	value, err := time.Parse("15:04:05", clock)
+	if err != nil {
+		return 0, false
+	}
+
+	return minuteOfDay(value), true
+}
+
+// validateLocaltime checks a local time of the bridge: a weekly `W124/T07:30:00`, an absolute `2024-01-31T07:30:00` or a timer `PT00:10:00`,
+// optionally repeated as `R05/PT00:10:00`, and optionally randomized by an `A00:30:00` suffix
+func validateLocaltime(localtime string) error {
+	value, random, randomized := strings.Cut(localtime, "A")
+	if randomized {
+		if err := validateClock(random); err != nil {
+			return fmt.Errorf("random delay: %w", err)
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(value, "W"):
+		days, clock, ok := strings.Cut(value[1:], "/T")
+
+		recurrence, err := strconv.Atoi(days)
+		if !ok || len(days) == 0 || len(days) > 3 || err != nil || recurrence < 1 || recurrence > alldays {
+			return fmt.Errorf("days must be between W1 and W%d, e.g. `W124/T07:30:00`", alldays)
+		}
+
+		return validateClock(clock)
+	case strings.HasPrefix(value, "PT"):
+		return validateClock(value[2:])
+	case strings.HasPrefix(value, "R"):
+		count, clock, ok := strings.Cut(value[1:], "/PT")
+		if _, err := strconv.Atoi(count); !ok || (len(count) != 0 && (len(count) != 2 || err != nil)) {
+			return errors.New("repeated timer must be like `R05/PT00:10:00`")
+		}
+
+		return validateClock(clock)
+	default:
+		if _, err := time.Parse("2006-01-02T15:04:05", value); err != nil {
+			return errors.New("expecting a weekly `W124/T07:30:00`, an absolute `2024-01-31T07:30:00` or a timer `PT00:10:00` time")
+		}
+
+		return nil
+	}
+}
+
+// validateClock checks a hh:mm:ss time
+func validateClock(clock string) error {
+	if _, err := time.Parse("15:04:05", clock); err != nil || len(clock) != 8 {
+		return fmt.Errorf("malformed time `%s`, expecting hh:mm:ss", clock)
+	}
+
+	return nil
+}
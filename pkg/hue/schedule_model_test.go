@@ -272,3 +272,98 @@ func TestFormatLocalTime(t *testing.T) {
 		})
 	}
 }
+
+func TestLocaltimeMinute(t *testing.T) {
+	cases := map[string]struct {
+		localtime string
+		want      int
+		wantOk    bool
+	}{
+		"weekly": {
+			localtime: "W124/T07:30:00",
+			want:      7*60 + 30,
+			wantOk:    true,
+		},
+		"absolute": {
+			localtime: "2024-01-01T22:05:00",
+			want:      22*60 + 5,
+			wantOk:    true,
+		},
+		"timer": {
+			localtime: "PT00:10:00",
+		},
+		"malformed": {
+			localtime: "W124",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			got, ok := localtimeMinute(tc.localtime)
+			if ok != tc.wantOk || got != tc.want {
+				t.Errorf("localtimeMinute() = (%d, %t), want (%d, %t)", got, ok, tc.want, tc.wantOk)
+			}
+		})
+	}
+}
+
+func TestValidateLocaltime(t *testing.T) {
+	cases := map[string]struct {
+		localtime string
+		wantErr   bool
+	}{
+		"weekly": {
+			localtime: "W124/T07:30:00",
+		},
+		"randomized": {
+			localtime: "W127/T07:30:00A00:30:00",
+		},
+		"absolute": {
+			localtime: "2024-01-31T22:05:00",
+		},
+		"timer": {
+			localtime: "PT00:10:00",
+		},
+		"repeated timer": {
+			localtime: "R05/PT00:10:00",
+		},
+		"short days": {
+			localtime: "W3/T07:30:00",
+		},
+		"no day": {
+			localtime: "W000/T07:30:00",
+			wantErr:   true,
+		},
+		"too many days": {
+			localtime: "W128/T07:30:00",
+			wantErr:   true,
+		},
+		"missing days": {
+			localtime: "W/T07:30:00",
+			wantErr:   true,
+		},
+		"short hour": {
+			localtime: "W124/T7:30:00",
+			wantErr:   true,
+		},
+		"invalid minute": {
+			localtime: "W124/T07:60:00",
+			wantErr:   true,
+		},
+		"invalid date": {
+			localtime: "2024-02-30T22:05:00",
+			wantErr:   true,
+		},
+		"empty": {
+			wantErr: true,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if err := validateLocaltime(tc.localtime); (err != nil) != tc.wantErr {
+				t.Errorf("validateLocaltime() = %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package hue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"github.com/ViBiOh/hue/pkg/auth"
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+// scopedGroups returns the groups the user of the context can see
+func scopedGroups(ctx context.Context, groups []v2.Group) []v2.Group {
+	scope := auth.ScopeFromContext(ctx)
+	if scope == nil {
+		return groups
+	}
+
+	output := make([]v2.Group, 0, len(scope.Groups))
+	for _, group := range groups {
+		if scope.HasGroup(group.ID) {
+			output = append(output, group)
+		}
+	}
+
+	return output
+}
+
+// scopedSensors returns the sensors the user of the context can see
+func scopedSensors(ctx context.Context, sensors v2.MotionSensors) v2.MotionSensors {
+	scope := auth.ScopeFromContext(ctx)
+	if scope == nil {
+		return sensors
+	}
+
+	output := make(v2.MotionSensors, 0, len(scope.Sensors))
+	for _, sensor := range sensors {
+		if scope.HasSensor(sensor.ID) {
+			output = append(output, sensor)
+		}
+	}
+
+	return output
+}
+
+// scopedLights returns the lights of the groups the user of the context can see
+func scopedLights(ctx context.Context, lights []v2.Light, groups []v2.Group) []v2.Light {
+	scope := auth.ScopeFromContext(ctx)
+	if scope == nil {
+		return lights
+	}
+
+	visible := make(map[string]struct{})
+	for _, group := range scopedGroups(ctx, groups) {
+		for _, light := range group.Lights {
+			visible[light.ID] = struct{}{}
+		}
+	}
+
+	output := make([]v2.Light, 0, len(visible))
+	for _, light := range lights {
+		if _, ok := visible[light.ID]; ok {
+			output = append(output, light)
+		}
+	}
+
+	return output
+}
+
+// checkGroupScope hides the groups out of scope as if they don't exist
+func checkGroupScope(ctx context.Context, id string) error {
+	if !auth.ScopeFromContext(ctx).HasGroup(id) {
+		return model.WrapNotFound(fmt.Errorf("unknown group `%s`", id))
+	}
+
+	return nil
+}
+
+// checkSensorScope hides the sensors out of scope as if they don't exist
+func checkSensorScope(ctx context.Context, id string) error {
+	if !auth.ScopeFromContext(ctx).HasSensor(id) {
+		return model.WrapNotFound(fmt.Errorf("unknown sensor `%s`", id))
+	}
+
+	return nil
+}
+
+// unscoped checks that the user of the context isn't restricted to some groups and sensors, for what concerns the whole bridge
+func unscoped(ctx context.Context) bool {
+	return auth.ScopeFromContext(ctx) == nil
+}
+
+// sensorRole returns the role required for toggling the given sensor: operators can toggle one sensor of their scope, the whole bridge being left to admins
+func sensorRole(ctx context.Context, id string) auth.Role {
+	if unscoped(ctx) || id == allSensors {
+		return auth.Admin
+	}
+
+	return auth.Operator
+}
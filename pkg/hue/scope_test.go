@@ -0,0 +1,118 @@
+package hue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ViBiOh/hue/pkg/auth"
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+func TestInScope(t *testing.T) {
+	scope := &auth.Scope{Groups: []string{"kitchen"}, Sensors: []string{"hall"}}
+
+	cases := map[string]struct {
+		scope  *auth.Scope
+		change v2.Change
+		want   bool
+	}{
+		"unrestricted": {
+			change: v2.Change{Kind: v2.GroupChange, ID: "bedroom"},
+			want:   true,
+		},
+		"group in scope": {
+			scope:  scope,
+			change: v2.Change{Kind: v2.GroupChange, ID: "kitchen"},
+			want:   true,
+		},
+		"group out of scope": {
+			scope:  scope,
+			change: v2.Change{Kind: v2.GroupChange, ID: "bedroom"},
+		},
+		"sensor in scope": {
+			scope:  scope,
+			change: v2.Change{Kind: v2.SensorChange, ID: "hall"},
+			want:   true,
+		},
+		"sensor out of scope": {
+			scope:  scope,
+			change: v2.Change{Kind: v2.SensorChange, ID: "garage"},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := inScope(tc.scope, tc.change); got != tc.want {
+				t.Errorf("inScope() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScopedLights(t *testing.T) {
+	groups := []v2.Group{
+		{ID: "kitchen", Lights: []*v2.Light{{ID: "ceiling"}}},
+		{ID: "bedroom", Lights: []*v2.Light{{ID: "bedside"}}},
+	}
+	lights := []v2.Light{{ID: "ceiling"}, {ID: "bedside"}}
+
+	cases := map[string]struct {
+		ctx  context.Context
+		want int
+	}{
+		"unrestricted": {
+			ctx:  context.Background(),
+			want: 2,
+		},
+		"scoped": {
+			ctx:  auth.WithUser(context.Background(), auth.User{Role: auth.Viewer, Scope: &auth.Scope{Groups: []string{"kitchen"}}}),
+			want: 1,
+		},
+		"sensors only": {
+			ctx:  auth.WithUser(context.Background(), auth.User{Role: auth.Viewer, Scope: &auth.Scope{Sensors: []string{"hall"}}}),
+			want: 0,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := scopedLights(tc.ctx, lights, groups); len(got) != tc.want {
+				t.Errorf("scopedLights() = %+v, want %d lights", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSensorRole(t *testing.T) {
+	scoped := auth.WithUser(context.Background(), auth.User{Role: auth.Operator, Scope: &auth.Scope{Sensors: []string{"hall"}}})
+
+	cases := map[string]struct {
+		ctx  context.Context
+		id   string
+		want auth.Role
+	}{
+		"unrestricted": {
+			ctx:  context.Background(),
+			id:   "hall",
+			want: auth.Admin,
+		},
+		"scoped": {
+			ctx:  scoped,
+			id:   "hall",
+			want: auth.Operator,
+		},
+		"scoped all": {
+			ctx:  scoped,
+			id:   allSensors,
+			want: auth.Admin,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := sensorRole(tc.ctx, tc.id); got != tc.want {
+				t.Errorf("sensorRole() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
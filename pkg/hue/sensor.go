@@ -1,17 +1,41 @@
 package hue
 
 import (
-	"context"
+	"errors"
 	"fmt"
-	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	v2 "github.com/ViBiOh/hue/pkg/v2"
 )
 
-const sensorPresenceURL = "/sensors/%s/state/presence"
+const (
+	sensorPresenceURL = "/sensors/%s/state/presence"
 
-func getGroupsActions(groups []v2.Group, config configSensor, state string) ([]Action, error) {
+	defaultDarkLightLevel = 6000
+)
+
+func (cs configSensor) darkLightLevel() int64 {
+	if cs.DarkLux > 0 {
+		return v2.LuxToLightLevel(cs.DarkLux)
+	}
+
+	return defaultDarkLightLevel
+}
+
+// validateDelay checks the delay of a rule condition, e.g. `PT00:01:00`
+func validateDelay(delay string) error {
+	clock, ok := strings.CutPrefix(delay, "PT")
+	if !ok {
+		return fmt.Errorf("malformed delay `%s`, expecting PThh:mm:ss", delay)
+	}
+
+	return validateClock(clock)
+}
+
+func (s *Service) getGroupsActions(groups []v2.Group, config configSensor, stateName string, minute int) ([]Action, error) {
 	var actions []Action
 
 	for _, group := range config.Groups {
@@ -20,24 +44,71 @@ func getGroupsActions(groups []v2.Group, config configSensor, state string) ([]A
 			return nil, err
 		}
 
+		state, err := s.roomState(targetGroup.Name, stateName, minute)
+		if err != nil {
+			return nil, err
+		}
+
 		actions = append(actions, Action{
 			Address: fmt.Sprintf("/groups/%s/action", targetGroup.IDV1),
 			Method:  http.MethodPut,
-			Body:    States[state].V1(),
+			Body:    state.V1(),
 		})
 	}
 
 	return actions, nil
 }
 
-func (s *Service) createSensorOnRuleDescription(groups []v2.Group, motion v2.MotionSensor, sensor configSensor) (Rule, error) {
-	state := "on"
+// sensorRooms returns the names of the groups of the sensor, as known by the bridge
+func sensorRooms(groups []v2.Group, sensor configSensor) ([]string, error) {
+	rooms := make([]string, len(sensor.Groups))
 
-	actions, err := getGroupsActions(groups, sensor, state)
+	for i, group := range sensor.Groups {
+		targetGroup, err := getGroup(groups, group)
+		if err != nil {
+			return nil, err
+		}
+
+		rooms[i] = targetGroup.Name
+	}
+
+	return rooms, nil
+}
+
+// createSensorRuleDescriptions returns the rule for each time slot of the presets of the sensor's groups
+func (s *Service) createSensorRuleDescriptions(groups []v2.Group, motion v2.MotionSensor, sensor configSensor, state string, conditions []Condition) ([]Rule, error) {
+	rooms, err := sensorRooms(groups, sensor)
 	if err != nil {
-		return Rule{}, fmt.Errorf("get groups actions: %w", err)
+		return nil, err
+	}
+
+	slots := s.timeSlots(rooms)
+	rules := make([]Rule, 0, len(slots))
+
+	for _, slot := range slots {
+		actions, err := s.getGroupsActions(groups, sensor, state, slot.from)
+		if err != nil {
+			return nil, fmt.Errorf("get groups actions: %w", err)
+		}
+
+		newRule := Rule{
+			Name:       fmt.Sprintf("MotionSensor %s - %s", motion.IDV1, state),
+			Conditions: slices.Clone(conditions),
+			Actions:    actions,
+		}
+
+		if !slot.wholeDay() {
+			newRule.Name += " " + formatMinute(slot.from)
+			newRule.Conditions = append(newRule.Conditions, slot.condition())
+		}
+
+		rules = append(rules, newRule)
 	}
 
+	return rules, nil
+}
+
+func (s *Service) createSensorOnRuleDescriptions(groups []v2.Group, motion v2.MotionSensor, sensor configSensor) ([]Rule, error) {
 	conditions := []Condition{
 		{
 			Address:  fmt.Sprintf(sensorPresenceURL, motion.IDV1),
@@ -54,7 +125,7 @@ func (s *Service) createSensorOnRuleDescription(groups []v2.Group, motion v2.Mot
 		conditions = append(conditions, Condition{
 			Address:  fmt.Sprintf("/sensors/%s/state/lightlevel", motion.LightLevelIDV1),
 			Operator: "lt",
-			Value:    "6000",
+			Value:    strconv.FormatInt(sensor.darkLightLevel(), 10),
 		})
 	}
 
@@ -62,7 +133,7 @@ func (s *Service) createSensorOnRuleDescription(groups []v2.Group, motion v2.Mot
 		for _, group := range sensor.Groups {
 			targetGroup, err := getGroup(groups, group)
 			if err != nil {
-				return Rule{}, fmt.Errorf("get all groups off: %w", err)
+				return nil, fmt.Errorf("get all groups off: %w", err)
 			}
 
 			conditions = append(conditions, Condition{
@@ -73,71 +144,56 @@ func (s *Service) createSensorOnRuleDescription(groups []v2.Group, motion v2.Mot
 		}
 	}
 
-	newRule := Rule{
-		Name:       fmt.Sprintf("MotionSensor %s - %s", motion.IDV1, state),
-		Conditions: conditions,
-		Actions:    actions,
-	}
-
-	return newRule, nil
+	return s.createSensorRuleDescriptions(groups, motion, sensor, "on", conditions)
 }
 
-func (s *Service) createSensorOffRuleDescription(groups []v2.Group, motion v2.MotionSensor, sensor configSensor) (Rule, error) {
-	state := "long_off"
-
-	actions, err := getGroupsActions(groups, sensor, state)
-	if err != nil {
-		return Rule{}, fmt.Errorf("get groups actions: %w", err)
-	}
-
-	newRule := Rule{
-		Name: fmt.Sprintf("MotionSensor %s - %s", motion.IDV1, state),
-		Conditions: []Condition{
-			{
-				Address:  fmt.Sprintf(sensorPresenceURL, motion.IDV1),
-				Operator: "eq",
-				Value:    "false",
-			},
-			{
-				Address:  fmt.Sprintf(sensorPresenceURL, motion.IDV1),
-				Operator: "ddx",
-				Value:    sensor.OffDelay,
-			},
+func (s *Service) createSensorOffRuleDescriptions(groups []v2.Group, motion v2.MotionSensor, sensor configSensor) ([]Rule, error) {
+	return s.createSensorRuleDescriptions(groups, motion, sensor, "long_off", []Condition{
+		{
+			Address:  fmt.Sprintf(sensorPresenceURL, motion.IDV1),
+			Operator: "eq",
+			Value:    "false",
 		},
-		Actions: actions,
-	}
-
-	return newRule, nil
+		{
+			Address:  fmt.Sprintf(sensorPresenceURL, motion.IDV1),
+			Operator: "ddx",
+			Value:    sensor.OffDelay,
+		},
+	})
 }
 
-func (s *Service) configureMotionSensor(ctx context.Context, groups []v2.Group, sensors []configSensor) {
+// sensorRules returns the rules of every motion sensor, the ones that can't be resolved being reported in the error
+func (s *Service) sensorRules(groups []v2.Group, sensors []configSensor) ([]Rule, error) {
 	motionDevices := s.v2Service.Sensors()
 
+	var rules []Rule
+	var errs []error
+
 	for _, sensor := range sensors {
 		targetMotion, err := getMotionSensor(motionDevices, sensor.ID)
 		if err != nil {
-			slog.LogAttrs(ctx, slog.LevelError, "unable to configure sensor", slog.String("id", sensor.ID), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("sensor `%s`: %w", sensor.ID, err))
 			continue
 		}
 
-		onRule, err := s.createSensorOnRuleDescription(groups, targetMotion, sensor)
+		onRules, err := s.createSensorOnRuleDescriptions(groups, targetMotion, sensor)
 		if err != nil {
-			slog.LogAttrs(ctx, slog.LevelError, "create motion on rule", slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("sensor `%s` on: %w", sensor.ID, err))
+			continue
 		}
 
-		if err := s.createRule(ctx, &onRule); err != nil {
-			slog.LogAttrs(ctx, slog.LevelError, "create motion rule", slog.Any("error", err))
-		}
+		rules = append(rules, onRules...)
 
 		if len(sensor.OffDelay) != 0 {
-			offRule, err := s.createSensorOffRuleDescription(groups, targetMotion, sensor)
+			offRules, err := s.createSensorOffRuleDescriptions(groups, targetMotion, sensor)
 			if err != nil {
-				slog.LogAttrs(ctx, slog.LevelError, "create motion off rule", slog.Any("error", err))
+				errs = append(errs, fmt.Errorf("sensor `%s` off: %w", sensor.ID, err))
+				continue
 			}
 
-			if err := s.createRule(ctx, &offRule); err != nil {
-				slog.LogAttrs(ctx, slog.LevelError, "create motion rule", slog.Any("error", err))
-			}
+			rules = append(rules, offRules...)
 		}
 	}
+
+	return rules, errors.Join(errs...)
 }
@@ -0,0 +1,386 @@
+package hue
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+// smartSceneBlankRows is the number of empty rows of the editor, for adding slots
+const smartSceneBlankRows = 2
+
+// smartScenePayload describes a smart scene in the configuration file, with the name of its group, and in the API, with the ID of its group
+type smartScenePayload struct {
+	Name       string           `json:"name"`
+	Group      string           `json:"group"`
+	Transition string           `json:"transition"`
+	Week       []smartSceneWeek `json:"week"`
+}
+
+// smartSceneWeek is the slots shared by some days of the week
+type smartSceneWeek struct {
+	Days  []string         `json:"days"`
+	Slots []smartSceneSlot `json:"slots"`
+}
+
+// smartSceneSlot recalls a scene, by name or ID, from a HH:MM time or from the sunset
+type smartSceneSlot struct {
+	Start string `json:"start"`
+	Scene string `json:"scene"`
+}
+
+// smartSceneForm is the editor of a smart scene in the dashboard, one row per slot and its days
+type smartSceneForm struct {
+	ID         string
+	Name       string
+	Transition string
+	Rows       []smartSceneRow
+	Scenes     []v2.Scene
+	Active     bool
+	Managed    bool
+}
+
+type smartSceneRow struct {
+	Days  map[string]bool
+	Start string
+	Scene string
+}
+
+// smartSceneDefinition resolves the days, the starts and the scenes of the payload for the given group
+func (s *Service) smartSceneDefinition(group v2.Group, payload smartScenePayload) (v2.SmartSceneDefinition, error) {
+	output := v2.SmartSceneDefinition{
+		Name: payload.Name,
+	}
+
+	if len(payload.Transition) != 0 {
+		transition, err := time.ParseDuration(payload.Transition)
+		if err != nil {
+			return output, model.WrapInvalid(fmt.Errorf("parse transition: %w", err))
+		}
+
+		if transition < 0 || transition > maxTransition {
+			return output, model.WrapInvalid(fmt.Errorf("transition must be between 0 and %s", maxTransition))
+		}
+
+		output.Transition = transition
+	}
+
+	scenes := s.v2Service.Scenes()[group.ID]
+
+	for _, week := range payload.Week {
+		item := v2.WeekTimeslots{
+			Recurrence: make([]string, len(week.Days)),
+		}
+
+		for i, day := range week.Days {
+			item.Recurrence[i] = strings.ToLower(day)
+		}
+
+		for _, slot := range week.Slots {
+			start, err := parseTimeslotStart(slot.Start)
+			if err != nil {
+				return output, model.WrapInvalid(err)
+			}
+
+			scene, ok := findScene(scenes, slot.Scene)
+			if !ok {
+				return output, model.WrapInvalid(fmt.Errorf("unknown scene `%s` in `%s`", slot.Scene, group.Name))
+			}
+
+			item.Timeslots = append(item.Timeslots, v2.NewTimeslot(start, scene.ID))
+		}
+
+		output.WeekTimeslots = append(output.WeekTimeslots, item)
+	}
+
+	return output, nil
+}
+
+// parseTimeslotStart reads a HH:MM time or `sunset`
+func parseTimeslotStart(value string) (v2.TimeslotStart, error) {
+	if strings.EqualFold(value, v2.TimeslotSunset) {
+		return v2.TimeslotStart{Kind: v2.TimeslotSunset}, nil
+	}
+
+	start, err := time.Parse(presetLayout, value)
+	if err != nil {
+		return v2.TimeslotStart{}, fmt.Errorf("parse start `%s`, must be HH:MM or `%s`", value, v2.TimeslotSunset)
+	}
+
+	return v2.TimeslotStart{
+		Kind: v2.TimeslotTime,
+		Time: &v2.TimeOfDay{Hour: start.Hour(), Minute: start.Minute()},
+	}, nil
+}
+
+// findScene returns the scene with the given ID, or else with the given name
+func findScene(scenes []v2.Scene, value string) (v2.Scene, bool) {
+	for _, scene := range scenes {
+		if scene.ID == value {
+			return scene, true
+		}
+	}
+
+	for _, scene := range scenes {
+		if strings.EqualFold(scene.Metadata.Name, value) {
+			return scene, true
+		}
+	}
+
+	return v2.Scene{}, false
+}
+
+// ownedSmartScene returns the smart scene with the given name created by the app
+func (s *Service) ownedSmartScene(smartScenes []v2.SmartScene, name string) (v2.SmartScene, bool) {
+	for _, smartScene := range smartScenes {
+		if strings.EqualFold(smartScene.Metadata.Name, name) && s.ownsSmartScene(smartScene) {
+			return smartScene, true
+		}
+	}
+
+	return v2.SmartScene{}, false
+}
+
+// smartSceneConflict returns an error if a smart scene of the group has the given name without being one of the app, for never taking it over
+func (s *Service) smartSceneConflict(group v2.Group, smartScenes []v2.SmartScene, name string) error {
+	if _, ok := s.ownedSmartScene(smartScenes, name); ok {
+		return nil
+	}
+
+	for _, smartScene := range smartScenes {
+		if strings.EqualFold(smartScene.Metadata.Name, name) {
+			return fmt.Errorf("smart scene `%s` of `%s` isn't managed by the app", name, group.Name)
+		}
+	}
+
+	return nil
+}
+
+// existingSmartScene returns the smart scene with the given ID, the smart scenes of the groups out of scope being hidden
+func (s *Service) existingSmartScene(ctx context.Context, id string) (v2.SmartScene, error) {
+	smartScene, ok := s.v2Service.SmartScene(id)
+	if !ok || checkGroupScope(ctx, smartScene.Group.Rid) != nil {
+		return smartScene, model.WrapNotFound(fmt.Errorf("unknown smart scene `%s`", id))
+	}
+
+	return smartScene, nil
+}
+
+// deleteSmartScene deletes the smart scene from the bridge, the app forgetting it if it created it
+func (s *Service) deleteSmartScene(ctx context.Context, id string) error {
+	if err := s.v2Service.DeleteSmartScene(ctx, id); err != nil {
+		return err
+	}
+
+	s.forgetDeleted(ctx, objectRef{Kind: kindSmartScene, ID: id})
+
+	return nil
+}
+
+// saveSmartScene creates a smart scene in the group of the payload, or replaces the given one
+func (s *Service) saveSmartScene(ctx context.Context, id string, payload smartScenePayload) (v2.SmartScene, error) {
+	groupID := payload.Group
+	var appdata string
+
+	if len(id) != 0 {
+		smartScene, err := s.existingSmartScene(ctx, id)
+		if err != nil {
+			return smartScene, err
+		}
+
+		groupID = smartScene.Group.Rid
+		appdata = smartScene.Metadata.Appdata
+	}
+
+	if err := checkGroupScope(ctx, groupID); err != nil {
+		return v2.SmartScene{}, err
+	}
+
+	group, ok := s.v2Service.Group(groupID)
+	if !ok {
+		return v2.SmartScene{}, model.WrapNotFound(fmt.Errorf("unknown group `%s`", groupID))
+	}
+
+	definition, err := s.smartSceneDefinition(group, payload)
+	if err != nil {
+		return v2.SmartScene{}, err
+	}
+
+	definition.Appdata = appdata
+
+	if len(id) != 0 {
+		return s.v2Service.UpdateSmartScene(ctx, id, definition)
+	}
+
+	return s.v2Service.CreateSmartScene(ctx, group.ID, definition)
+}
+
+// toSmartScenePayload describes the smart scene with the IDs of its group and scenes
+func toSmartScenePayload(smartScene v2.SmartScene) smartScenePayload {
+	output := smartScenePayload{
+		Name:       smartScene.Metadata.Name,
+		Group:      smartScene.Group.Rid,
+		Transition: smartScene.Transition().String(),
+		Week:       make([]smartSceneWeek, len(smartScene.WeekTimeslots)),
+	}
+
+	for i, week := range smartScene.WeekTimeslots {
+		output.Week[i].Days = week.Recurrence
+
+		for _, timeslot := range week.Timeslots {
+			output.Week[i].Slots = append(output.Week[i].Slots, smartSceneSlot{
+				Start: timeslot.StartTime.String(),
+				Scene: timeslot.Target.Rid,
+			})
+		}
+	}
+
+	return output
+}
+
+// smartSceneForms returns the editors of the smart scenes of each group, by group ID, the last one of each group creating a new smart scene
+func (s *Service) smartSceneForms(groups []v2.Group) map[string][]smartSceneForm {
+	scenes := s.v2Service.Scenes()
+	smartScenes := s.v2Service.SmartScenes()
+
+	output := make(map[string][]smartSceneForm)
+
+	for _, group := range groups {
+		if len(scenes[group.ID]) == 0 {
+			continue
+		}
+
+		var forms []smartSceneForm
+
+		for _, smartScene := range smartScenes[group.ID] {
+			payload := toSmartScenePayload(smartScene)
+
+			form := smartSceneForm{
+				ID:         smartScene.ID,
+				Name:       payload.Name,
+				Transition: payload.Transition,
+				Scenes:     scenes[group.ID],
+				Active:     smartScene.Active(),
+				Managed:    s.ownsSmartScene(smartScene),
+			}
+
+			for _, week := range payload.Week {
+				for _, slot := range week.Slots {
+					form.Rows = append(form.Rows, newSmartSceneRow(week.Days, slot))
+				}
+			}
+
+			forms = append(forms, form.withBlankRows())
+		}
+
+		forms = append(forms, smartSceneForm{Scenes: scenes[group.ID]}.withBlankRows())
+
+		output[group.ID] = forms
+	}
+
+	return output
+}
+
+func newSmartSceneRow(days []string, slot smartSceneSlot) smartSceneRow {
+	output := smartSceneRow{
+		Days:  make(map[string]bool, len(days)),
+		Start: slot.Start,
+		Scene: slot.Scene,
+	}
+
+	for _, day := range days {
+		output.Days[day] = true
+	}
+
+	return output
+}
+
+func (f smartSceneForm) withBlankRows() smartSceneForm {
+	for range smartSceneBlankRows {
+		f.Rows = append(f.Rows, smartSceneRow{})
+	}
+
+	return f
+}
+
+// activeSmartScenes describes the slot currently applied by an active smart scene, by group ID
+func (s *Service) activeSmartScenes(groups []v2.Group) map[string]string {
+	smartScenes := s.v2Service.SmartScenes()
+
+	output := make(map[string]string)
+
+	for _, group := range groups {
+		for _, smartScene := range smartScenes[group.ID] {
+			slot, ok := smartScene.ActiveSlot()
+			if !ok {
+				continue
+			}
+
+			sceneName := slot.Target.Rid
+			if scene, ok := s.v2Service.Scene(slot.Target.Rid); ok {
+				sceneName = scene.Metadata.Name
+			}
+
+			output[group.ID] = fmt.Sprintf("%s: %s since %s", smartScene.Metadata.Name, sceneName, slot.StartTime)
+		}
+	}
+
+	return output
+}
+
+// parseSmartSceneForm reads the editor of the dashboard, days sharing the same slots being grouped
+func parseSmartSceneForm(r *http.Request) smartScenePayload {
+	output := smartScenePayload{
+		Name:       r.FormValue("name"),
+		Group:      r.FormValue("group"),
+		Transition: r.FormValue("transition"),
+	}
+
+	slots := make(map[string][]smartSceneSlot)
+
+	for _, row := range r.Form["row"] {
+		slot := smartSceneSlot{
+			Start: r.FormValue("start." + row),
+			Scene: r.FormValue("scene." + row),
+		}
+
+		if len(slot.Start) == 0 || len(slot.Scene) == 0 {
+			continue
+		}
+
+		for _, day := range r.Form["days."+row] {
+			slots[day] = append(slots[day], slot)
+		}
+	}
+
+	return groupSmartSceneDays(output, slots)
+}
+
+// groupSmartSceneDays adds the slots of each day to the payload, days sharing the same slots being grouped in the order of the week and unknown days ignored
+func groupSmartSceneDays(payload smartScenePayload, slots map[string][]smartSceneSlot) smartScenePayload {
+	for _, day := range v2.Weekdays {
+		daySlots, ok := slots[day]
+		if !ok {
+			continue
+		}
+
+		index := slices.IndexFunc(payload.Week, func(week smartSceneWeek) bool {
+			return slices.Equal(week.Slots, daySlots)
+		})
+
+		if index == -1 {
+			payload.Week = append(payload.Week, smartSceneWeek{Slots: daySlots})
+			index = len(payload.Week) - 1
+		}
+
+		payload.Week[index].Days = append(payload.Week[index].Days, day)
+	}
+
+	return payload
+}
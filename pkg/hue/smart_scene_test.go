@@ -0,0 +1,142 @@
+package hue
+
+import (
+	"reflect"
+	"testing"
+
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+func TestGroupSmartSceneDays(t *testing.T) {
+	morning := smartSceneSlot{Start: "07:00", Scene: "Energize"}
+	evening := smartSceneSlot{Start: "sunset", Scene: "Relax"}
+
+	cases := map[string]struct {
+		slots map[string][]smartSceneSlot
+		want  []smartSceneWeek
+	}{
+		"empty": {
+			slots: map[string][]smartSceneSlot{},
+		},
+		"shared": {
+			slots: map[string][]smartSceneSlot{
+				"friday":   {morning, evening},
+				"monday":   {morning, evening},
+				"sunday":   {evening},
+				"saturday": {evening},
+			},
+			want: []smartSceneWeek{
+				{Days: []string{"monday", "friday"}, Slots: []smartSceneSlot{morning, evening}},
+				{Days: []string{"saturday", "sunday"}, Slots: []smartSceneSlot{evening}},
+			},
+		},
+		"unknown day": {
+			slots: map[string][]smartSceneSlot{"someday": {morning}},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := groupSmartSceneDays(smartScenePayload{}, tc.slots); !reflect.DeepEqual(got.Week, tc.want) {
+				t.Errorf("groupSmartSceneDays() = %+v, want %+v", got.Week, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeslotStart(t *testing.T) {
+	cases := map[string]struct {
+		value   string
+		want    v2.TimeslotStart
+		wantErr bool
+	}{
+		"time": {
+			value: "07:30",
+			want:  v2.TimeslotStart{Kind: v2.TimeslotTime, Time: &v2.TimeOfDay{Hour: 7, Minute: 30}},
+		},
+		"sunset": {
+			value: "Sunset",
+			want:  v2.TimeslotStart{Kind: v2.TimeslotSunset},
+		},
+		"malformed": {
+			value:   "7pm",
+			wantErr: true,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			got, err := parseTimeslotStart(tc.value)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("parseTimeslotStart() succeeded, want an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseTimeslotStart() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseTimeslotStart() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSmartSceneConflict(t *testing.T) {
+	service := Service{ownership: &ownership{objects: make(map[string]map[string]struct{})}}
+	service.ownership.track(objectRef{Kind: kindSmartScene, ID: "owned"})
+
+	newSmartScene := func(id, name, appdata string) v2.SmartScene {
+		var smartScene v2.SmartScene
+		smartScene.ID = id
+		smartScene.Metadata.Name = name
+		smartScene.Metadata.Appdata = appdata
+
+		return smartScene
+	}
+
+	group := v2.Group{ID: "living", Name: "Living room"}
+
+	cases := map[string]struct {
+		smartScenes []v2.SmartScene
+		wantID      string
+		wantErr     bool
+	}{
+		"none": {},
+		"owned": {
+			smartScenes: []v2.SmartScene{newSmartScene("owned", "Daylight", "")},
+			wantID:      "owned",
+		},
+		"of another owner": {
+			smartScenes: []v2.SmartScene{newSmartScene("other", "daylight", "")},
+			wantErr:     true,
+		},
+		"owned and of another owner": {
+			smartScenes: []v2.SmartScene{newSmartScene("other", "Daylight", ""), newSmartScene("owned", "Daylight", "")},
+			wantID:      "owned",
+		},
+		"other name": {
+			smartScenes: []v2.SmartScene{newSmartScene("other", "Evening", "")},
+		},
+		"tagged": {
+			smartScenes: []v2.SmartScene{newSmartScene("tagged", "Daylight", managedDescription)},
+			wantID:      "tagged",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if err := service.smartSceneConflict(group, tc.smartScenes, "Daylight"); (err != nil) != tc.wantErr {
+				t.Errorf("smartSceneConflict() = %v, want error %t", err, tc.wantErr)
+			}
+
+			if got, _ := service.ownedSmartScene(tc.smartScenes, "Daylight"); got.ID != tc.wantID {
+				t.Errorf("ownedSmartScene() = `%s`, want `%s`", got.ID, tc.wantID)
+			}
+		})
+	}
+}
@@ -2,13 +2,13 @@ package hue
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 	"time"
 
 	"github.com/ViBiOh/httputils/v4/pkg/cron"
+	"github.com/ViBiOh/hue/pkg/activity"
 	v2 "github.com/ViBiOh/hue/pkg/v2"
 )
 
@@ -16,6 +16,43 @@ var logError = func(ctx context.Context, err error) {
 	slog.LogAttrs(ctx, slog.LevelError, "error", slog.Any("error", err))
 }
 
+// Init reads the timezone of the bridge, for resolving the presets in the local time of its schedules and rules
+func (s *Service) Init(ctx context.Context) error {
+	var config BridgeConfig
+	if err := s.apiGet(ctx, "config", &config); err != nil {
+		return fmt.Errorf("get config: %w", err)
+	}
+
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return fmt.Errorf("load timezone: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.location = location
+
+	return nil
+}
+
+// now returns the current local time of the bridge
+func (s *Service) now() time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.inLocation(time.Now())
+}
+
+// inLocation converts the time to the local time of the bridge, the one of the process until it's known. The mutex has to be held
+func (s *Service) inLocation(value time.Time) time.Time {
+	if s.location == nil {
+		return value
+	}
+
+	return value.In(s.location)
+}
+
 func (s *Service) Start(ctx context.Context) {
 	config := s.initConfig(ctx)
 
@@ -23,7 +60,7 @@ func (s *Service) Start(ctx context.Context) {
 		item := motionSensorCron
 
 		go cron.New().WithTracerProvider(s.tracerProvider).Days().At(item.Hour).In(item.Timezone).OnError(logError).Start(ctx, func(ctx context.Context) error {
-			return s.updateSensors(ctx, item.Names, item.Enabled)
+			return s.updateSensors(activity.WithOrigin(ctx, activity.Origin{Source: activity.Cron, Actor: "sensors at " + item.Hour}), item.Names, item.Enabled)
 		})
 	}
 
@@ -31,46 +68,56 @@ func (s *Service) Start(ctx context.Context) {
 }
 
 func (s *Service) initConfig(ctx context.Context) (config configHue) {
+	config = s.config
+
 	if len(s.configFileName) == 0 {
 		slog.WarnContext(ctx, "no config init for hue")
 		return config
 	}
 
-	configFile, err := os.Open(s.configFileName)
-	if err != nil {
-		slog.LogAttrs(ctx, slog.LevelError, "open config file", slog.Any("error", err))
+	if !s.update {
 		return config
 	}
 
-	if err := json.NewDecoder(configFile).Decode(&config); err != nil {
-		slog.LogAttrs(ctx, slog.LevelError, "decode config file", slog.Any("error", err))
+	changes, err := s.diff(ctx, config)
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "plan configuration", slog.Any("error", err))
 		return config
 	}
 
-	if s.update {
-		slog.InfoContext(ctx, "Configuring hue...")
-		defer slog.InfoContext(ctx, "Configuration done.")
+	if len(changes.changes) == 0 {
+		slog.InfoContext(ctx, "Configuration is up-to-date.")
+	}
 
-		if err := s.cleanSchedules(ctx); err != nil {
-			slog.LogAttrs(ctx, slog.LevelError, "clean schedule", slog.Any("error", err))
-		}
+	for _, item := range changes.changes {
+		slog.LogAttrs(ctx, slog.LevelInfo, "plan", slog.String("change", item.String()))
+	}
 
-		if err := s.cleanRules(ctx); err != nil {
-			slog.LogAttrs(ctx, slog.LevelError, "clean rule", slog.Any("error", err))
-		}
+	slog.InfoContext(ctx, "Configuring hue...")
+	defer slog.InfoContext(ctx, "Configuration done.")
 
-		if err := s.cleanScenes(ctx); err != nil {
-			slog.LogAttrs(ctx, slog.LevelError, "clean scene", slog.Any("error", err))
-		}
+	s.apply(ctx, changes)
 
-		groups := s.v2Service.Groups()
+	return config
+}
 
-		s.configureSchedules(ctx, groups, config.Schedules)
-		s.configureTap(ctx, groups, config.Taps)
-		s.configureMotionSensor(ctx, groups, config.Sensors)
+// Plan returns the changes of the configuration file to the bridge, without applying them
+func (s *Service) Plan(ctx context.Context) ([]string, error) {
+	if len(s.configFileName) == 0 {
+		return nil, errors.New("no configuration file")
 	}
 
-	return config
+	changes, err := s.diff(ctx, s.config)
+	if err != nil {
+		return nil, fmt.Errorf("plan configuration: %w", err)
+	}
+
+	output := make([]string, len(changes.changes))
+	for i, item := range changes.changes {
+		output[i] = item.String()
+	}
+
+	return output, nil
 }
 
 func (s *Service) updateSensors(ctx context.Context, names []string, enabled bool) error {
@@ -97,6 +144,11 @@ func (s *Service) syncSchedules(ctx context.Context) error {
 		return fmt.Errorf("list schedules: %w", err)
 	}
 
+	for id, schedule := range schedules {
+		schedule.Managed = s.ownedSchedule(schedule)
+		schedules[id] = schedule
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.schedules = schedules
@@ -0,0 +1,38 @@
+package hue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInLocation(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 1, 1, 6, 30, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		location *time.Location
+		want     int
+	}{
+		"unknown timezone": {
+			want: 6*60 + 30,
+		},
+		"timezone of the bridge": {
+			location: paris,
+			want:     7*60 + 30,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			instance := Service{location: tc.location}
+
+			if got := minuteOfDay(instance.inLocation(now)); got != tc.want {
+				t.Errorf("inLocation() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package hue
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+const (
+	maxBrightness = 100
+
+	// maxTransition is the longest transition of the bridge, counting it in tenths of second on 16 bits
+	maxTransition = time.Millisecond * 100 * math.MaxUint16
+)
+
+// iconStates are the states having an icon in the dashboard
+var iconStates = []string{"on", "half", "dimmed", "off"}
+
+// transitions are the durations proposed by the dashboard for custom states
+var transitions = []string{"0s", "3s", "30s", "1m", "5m", "10m", "30m", "1h"}
+
+// groupPatch is the state requested for groups, a preset and/or explicit values
+type groupPatch struct {
+	On         *bool   `json:"on"`
+	Brightness *uint64 `json:"brightness"`
+	Mirek      *int    `json:"mirek"`
+	State      string  `json:"state"`
+	Color      string  `json:"color"`
+	Transition string  `json:"transition"`
+}
+
+func (p groupPatch) custom() bool {
+	return p.On != nil || p.Brightness != nil || p.Mirek != nil || len(p.Color) != 0 || len(p.Transition) != 0
+}
+
+// resolve validates the values and applies them over the preset, if any
+func (p groupPatch) resolve(states map[string]State) (State, error) {
+	state := State{On: true, Brightness: maxBrightness}
+
+	if len(p.State) != 0 {
+		preset, ok := states[p.State]
+		if !ok {
+			return state, model.WrapInvalid(fmt.Errorf("unknown state `%s`", p.State))
+		}
+
+		state = preset
+	} else if !p.custom() {
+		return state, model.WrapInvalid(errors.New("a state or a value among on, brightness, mirek, color and transition is required"))
+	}
+
+	if p.Mirek != nil && len(p.Color) != 0 {
+		return state, model.WrapInvalid(errors.New("mirek and color are exclusive"))
+	}
+
+	if p.On != nil {
+		state.On = *p.On
+	}
+
+	if p.Brightness != nil {
+		if *p.Brightness > maxBrightness {
+			return state, model.WrapInvalid(fmt.Errorf("brightness must be between 0 and %d", maxBrightness))
+		}
+
+		state.Brightness = *p.Brightness
+	}
+
+	if p.Mirek != nil {
+		if *p.Mirek < v2.MinMirek || *p.Mirek > v2.MaxMirek {
+			return state, model.WrapInvalid(fmt.Errorf("mirek must be between %d and %d", v2.MinMirek, v2.MaxMirek))
+		}
+
+		state.Mirek = *p.Mirek
+		state.XY = nil
+	}
+
+	if len(p.Color) != 0 {
+		xy, err := parseColor(p.Color)
+		if err != nil {
+			return state, model.WrapInvalid(fmt.Errorf("parse color: %w", err))
+		}
+
+		state.XY = &xy
+		state.Mirek = 0
+	}
+
+	if len(p.Transition) != 0 {
+		transition, err := time.ParseDuration(p.Transition)
+		if err != nil {
+			return state, model.WrapInvalid(fmt.Errorf("parse transition: %w", err))
+		}
+
+		if transition < 0 || transition > maxTransition {
+			return state, model.WrapInvalid(fmt.Errorf("transition must be between 0 and %s", maxTransition))
+		}
+
+		state.Duration = transition
+	}
+
+	return state, nil
+}
+
+// describe returns the name of the preset, or the values of the state when some have been given
+func (p groupPatch) describe(state State) string {
+	if !p.custom() {
+		return p.State
+	}
+
+	if !state.On {
+		return "off"
+	}
+
+	output := fmt.Sprintf("on at %d%%", state.Brightness)
+
+	if len(p.Color) != 0 {
+		output += fmt.Sprintf(", color %s", p.Color)
+	} else if state.Mirek != 0 {
+		output += fmt.Sprintf(", %d mirek", state.Mirek)
+	}
+
+	if state.Duration > 0 {
+		output += fmt.Sprintf(" in %s", state.Duration)
+	}
+
+	return output
+}
+
+// buildStates merges the states of the configuration with the default ones, or replaces them
+func buildStates(config configHue) (map[string]State, error) {
+	output := make(map[string]State, len(States)+len(config.States))
+
+	if !config.ReplaceStates {
+		maps.Copy(output, States)
+	}
+
+	var errs []error
+
+	for _, name := range slices.Sorted(maps.Keys(config.States)) {
+		if len(strings.TrimSpace(name)) == 0 {
+			errs = append(errs, configError{path: "states", err: errors.New("name of a state is required")})
+			continue
+		}
+
+		state, err := config.States[name].resolve(States)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("state `%s`: %w", name, configError{path: "states." + name, err: err}))
+			continue
+		}
+
+		output[name] = state
+	}
+
+	if len(output) == 0 && len(errs) == 0 {
+		errs = append(errs, configError{path: "replace_states", err: errors.New("at least a state is required when replacing the default ones")})
+	}
+
+	return output, errors.Join(errs...)
+}
+
+// stateNames returns the names of the states, sorted
+func (s *Service) stateNames() []string {
+	return slices.Sorted(maps.Keys(s.states))
+}
+
+// customStates returns the names of the states of the configuration that don't have an icon in the dashboard, sorted
+func (s *Service) customStates() []string {
+	var output []string
+
+	for _, name := range s.stateNames() {
+		if _, ok := s.config.States[name]; ok && !slices.Contains(iconStates, name) {
+			output = append(output, name)
+		}
+	}
+
+	return output
+}
+
+// hasStates tells which of the states having an icon in the dashboard are available
+func (s *Service) hasStates() map[string]bool {
+	output := make(map[string]bool, len(iconStates))
+	for _, name := range iconStates {
+		_, output[name] = s.states[name]
+	}
+
+	return output
+}
+
+// state returns the named state, an error if it doesn't exist
+func (s *Service) state(name string) (State, error) {
+	state, ok := s.states[name]
+	if !ok {
+		return state, fmt.Errorf("unknown state `%s`", name)
+	}
+
+	return state, nil
+}
+
+// parseColor converts a #rrggbb color to the CIE xy color space of the bridge
+func parseColor(value string) ([2]float64, error) {
+	var output [2]float64
+
+	hex, ok := strings.CutPrefix(value, "#")
+	if !ok || len(hex) != 6 {
+		return output, fmt.Errorf("`%s` is not a #rrggbb color", value)
+	}
+
+	var rgb [3]float64
+
+	for i := range rgb {
+		component, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return output, fmt.Errorf("`%s` is not a #rrggbb color", value)
+		}
+
+		rgb[i] = gammaCorrection(float64(component) / 255)
+	}
+
+	x := rgb[0]*0.664511 + rgb[1]*0.154324 + rgb[2]*0.162028
+	y := rgb[0]*0.283881 + rgb[1]*0.668433 + rgb[2]*0.047685
+	z := rgb[0]*0.000088 + rgb[1]*0.072310 + rgb[2]*0.986039
+
+	sum := x + y + z
+	if sum == 0 {
+		return output, errors.New("black isn't a color of light")
+	}
+
+	output[0] = math.Round(x/sum*10000) / 10000
+	output[1] = math.Round(y/sum*10000) / 10000
+
+	return output, nil
+}
+
+func gammaCorrection(value float64) float64 {
+	if value > 0.04045 {
+		return math.Pow((value+0.055)/1.055, 2.4)
+	}
+
+	return value / 12.92
+}
+
+// parseGroupForm reads the state of a form of the dashboard, empty fields being left unset
+func parseGroupForm(r *http.Request) (groupPatch, error) {
+	return parseGroupValues(r.FormValue)
+}
+
+// parseGroupValues reads the state from the given values by field name, empty fields being left unset
+func parseGroupValues(value func(string) string) (groupPatch, error) {
+	output := groupPatch{
+		State:      value("state"),
+		Transition: value("transition"),
+	}
+
+	if rawOn := value("on"); len(rawOn) != 0 {
+		on, err := strconv.ParseBool(rawOn)
+		if err != nil {
+			return output, model.WrapInvalid(fmt.Errorf("parse on: %w", err))
+		}
+
+		output.On = &on
+	}
+
+	if rawBrightness := value("brightness"); len(rawBrightness) != 0 {
+		brightness, err := strconv.ParseUint(rawBrightness, 10, 64)
+		if err != nil {
+			return output, model.WrapInvalid(fmt.Errorf("parse brightness: %w", err))
+		}
+
+		output.Brightness = &brightness
+	}
+
+	output.Color = value("color")
+
+	if rawMirek := value("mirek"); len(rawMirek) != 0 {
+		mirek, err := strconv.Atoi(rawMirek)
+		if err != nil {
+			return output, model.WrapInvalid(fmt.Errorf("parse mirek: %w", err))
+		}
+
+		output.Mirek = &mirek
+	}
+
+	return output, nil
+}
@@ -0,0 +1,221 @@
+package hue
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+)
+
+func TestGroupPatchResolve(t *testing.T) {
+	on := false
+	brightness := uint64(30)
+	tooBright := uint64(101)
+	mirek := 370
+	tooCold := 100
+
+	cases := map[string]struct {
+		instance groupPatch
+		want     State
+		wantErr  error
+	}{
+		"preset": {
+			instance: groupPatch{State: "half"},
+			want:     States["half"],
+		},
+		"preset overridden": {
+			instance: groupPatch{State: "half", Brightness: &brightness, Transition: "10m"},
+			want:     State{On: true, Brightness: 30, Duration: time.Minute * 10},
+		},
+		"custom": {
+			instance: groupPatch{Brightness: &brightness, Mirek: &mirek},
+			want:     State{On: true, Brightness: 30, Mirek: 370},
+		},
+		"color": {
+			instance: groupPatch{State: "on", Color: "#ff0000"},
+			want:     State{On: true, Brightness: 100, Duration: time.Second * 3, XY: &[2]float64{0.7006, 0.2993}},
+		},
+		"color and mirek": {
+			instance: groupPatch{Color: "#ff0000", Mirek: &mirek},
+			wantErr:  model.ErrInvalid,
+		},
+		"custom off": {
+			instance: groupPatch{On: &on, Transition: "1m"},
+			want:     State{On: false, Brightness: 100, Duration: time.Minute},
+		},
+		"empty": {
+			instance: groupPatch{},
+			wantErr:  model.ErrInvalid,
+		},
+		"unknown preset": {
+			instance: groupPatch{State: "party"},
+			wantErr:  model.ErrInvalid,
+		},
+		"brightness": {
+			instance: groupPatch{Brightness: &tooBright},
+			wantErr:  model.ErrInvalid,
+		},
+		"mirek": {
+			instance: groupPatch{Mirek: &tooCold},
+			wantErr:  model.ErrInvalid,
+		},
+		"transition": {
+			instance: groupPatch{Transition: "2h"},
+			wantErr:  model.ErrInvalid,
+		},
+		"negative transition": {
+			instance: groupPatch{Transition: "-1s"},
+			wantErr:  model.ErrInvalid,
+		},
+		"malformed transition": {
+			instance: groupPatch{Transition: "soon"},
+			wantErr:  model.ErrInvalid,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			got, gotErr := tc.instance.resolve(States)
+
+			if tc.wantErr != nil {
+				if !errors.Is(gotErr, tc.wantErr) {
+					t.Errorf("resolve() error = %v, want %v", gotErr, tc.wantErr)
+				}
+
+				return
+			}
+
+			if gotErr != nil {
+				t.Fatalf("resolve() error = %v", gotErr)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("resolve() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildStates(t *testing.T) {
+	brightness := uint64(80)
+
+	cases := map[string]struct {
+		config  configHue
+		want    []string
+		wantErr bool
+	}{
+		"defaults": {
+			config: configHue{},
+			want:   []string{"dimmed", "half", "long_off", "long_on", "off", "on"},
+		},
+		"merged": {
+			config: configHue{States: map[string]groupPatch{"reading": {Brightness: &brightness}}},
+			want:   []string{"dimmed", "half", "long_off", "long_on", "off", "on", "reading"},
+		},
+		"replaced": {
+			config: configHue{States: map[string]groupPatch{"reading": {Brightness: &brightness}, "off": {State: "off"}}, ReplaceStates: true},
+			want:   []string{"off", "reading"},
+		},
+		"nothing left": {
+			config:  configHue{ReplaceStates: true},
+			wantErr: true,
+		},
+		"invalid": {
+			config:  configHue{States: map[string]groupPatch{"night": {Color: "red"}}},
+			wantErr: true,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			states, err := buildStates(tc.config)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("buildStates() succeeded, want an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("buildStates() error = %v", err)
+			}
+
+			if got := (&Service{states: states}).stateNames(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("buildStates() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStateV1(t *testing.T) {
+	cases := map[string]struct {
+		instance State
+		want     map[string]any
+	}{
+		"full": {
+			instance: States["on"],
+			want:     map[string]any{"on": true, "bri": 254, "transitiontime": int64(30)},
+		},
+		"half": {
+			instance: State{On: true, Brightness: 50, Mirek: 300},
+			want:     map[string]any{"on": true, "bri": 127, "transitiontime": int64(0), "ct": 300},
+		},
+		"dimmed": {
+			instance: States["dimmed"],
+			want:     map[string]any{"on": true, "bri": 1, "transitiontime": int64(30)},
+		},
+		"color": {
+			instance: State{On: true, Brightness: 30, XY: &[2]float64{0.5, 0.4}},
+			want:     map[string]any{"on": true, "bri": 76, "transitiontime": int64(0), "xy": [2]float64{0.5, 0.4}},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := tc.instance.V1(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("V1() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStateAction(t *testing.T) {
+	cases := map[string]struct {
+		instance State
+		want     string
+	}{
+		"off": {
+			instance: States["off"],
+			want:     `{"on":{"on":false}}`,
+		},
+		"temperature": {
+			instance: State{On: true, Brightness: 50, Mirek: 300},
+			want:     `{"on":{"on":true},"dimming":{"brightness":50},"color_temperature":{"mirek":300}}`,
+		},
+		"color": {
+			instance: State{On: true, Brightness: 30, XY: &[2]float64{0.5, 0.4}},
+			want:     `{"on":{"on":true},"dimming":{"brightness":30},"color":{"xy":{"x":0.5,"y":0.4}}}`,
+		},
+		"current brightness": {
+			instance: State{On: true},
+			want:     `{"on":{"on":true}}`,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			got, err := json.Marshal(tc.instance.action())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tc.want {
+				t.Errorf("action() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
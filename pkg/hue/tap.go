@@ -1,9 +1,8 @@
 package hue
 
 import (
-	"context"
+	"errors"
 	"fmt"
-	"log/slog"
 	"net/http"
 
 	v2 "github.com/ViBiOh/hue/pkg/v2"
@@ -40,65 +39,126 @@ func getButtonMapping(dial bool, id string, long bool) string {
 	return tapButtonMapping[id]
 }
 
-func (s *Service) createRuleDescription(groups []v2.Group, tapID string, dial bool, button configTapButton) (Rule, error) {
-	newRule := Rule{
-		Name: fmt.Sprintf("Tap %s.%s.%t", tapID, button.ID, button.Long),
-		Conditions: []Condition{
-			{
-				Address:  fmt.Sprintf("/sensors/%s/state/buttonevent", tapID),
-				Operator: "dx",
-			},
-			{
-				Address:  fmt.Sprintf("/sensors/%s/state/buttonevent", tapID),
-				Operator: "eq",
-				Value:    getButtonMapping(dial, button.ID, button.Long),
-			},
-		},
+// lightRoom returns the name of the room of the light given by its v1 ID, empty if it has none
+func lightRoom(groups []v2.Group, id string) string {
+	for _, group := range groups {
+		if !group.Room() {
+			continue
+		}
+
+		for _, light := range group.Lights {
+			if light.IDV1 == id {
+				return group.Name
+			}
+		}
 	}
 
-	for _, group := range button.Groups {
-		targetGroup, err := getGroup(groups, group)
-		if err != nil {
-			return Rule{}, err
+	return ""
+}
+
+// createRuleDescriptions returns the rules of the button, one for each time slot of the presets of its groups and of the rooms of its lights
+func (s *Service) createRuleDescriptions(groups []v2.Group, tapID string, dial bool, button configTapButton) ([]Rule, error) {
+	if _, err := s.state(button.State); err != nil {
+		return nil, err
+	}
+
+	targetGroups := make([]v2.Group, len(button.Groups))
+	rooms := make([]string, len(button.Groups), len(button.Groups)+len(button.Lights))
+
+	for i, group := range button.Groups {
+		var err error
+		if targetGroups[i], err = getGroup(groups, group); err != nil {
+			return nil, err
 		}
 
-		newRule.Actions = append(newRule.Actions, Action{
-			Address: fmt.Sprintf("/groups/%s/action", targetGroup.IDV1),
-			Method:  http.MethodPut,
-			Body:    States[button.State].V1(),
-		})
+		rooms[i] = targetGroups[i].Name
 	}
 
-	for _, light := range button.Lights {
-		newRule.Actions = append(newRule.Actions, Action{
-			Address: fmt.Sprintf("/lights/%s/state", light),
-			Method:  http.MethodPut,
-			Body:    States[button.State].V1(),
-		})
+	lightRooms := make([]string, len(button.Lights))
+	for i, light := range button.Lights {
+		lightRooms[i] = lightRoom(groups, light)
+	}
+
+	slots := s.timeSlots(append(rooms, lightRooms...))
+	rules := make([]Rule, 0, len(slots))
+
+	for _, slot := range slots {
+		newRule := Rule{
+			Name: fmt.Sprintf("Tap %s.%s.%t", tapID, button.ID, button.Long),
+			Conditions: []Condition{
+				{
+					Address:  fmt.Sprintf("/sensors/%s/state/buttonevent", tapID),
+					Operator: "dx",
+				},
+				{
+					Address:  fmt.Sprintf("/sensors/%s/state/buttonevent", tapID),
+					Operator: "eq",
+					Value:    getButtonMapping(dial, button.ID, button.Long),
+				},
+			},
+		}
+
+		if !slot.wholeDay() {
+			newRule.Name += " " + formatMinute(slot.from)
+			newRule.Conditions = append(newRule.Conditions, slot.condition())
+		}
+
+		for _, targetGroup := range targetGroups {
+			groupState, err := s.roomState(targetGroup.Name, button.State, slot.from)
+			if err != nil {
+				return nil, err
+			}
+
+			newRule.Actions = append(newRule.Actions, Action{
+				Address: fmt.Sprintf("/groups/%s/action", targetGroup.IDV1),
+				Method:  http.MethodPut,
+				Body:    groupState.V1(),
+			})
+		}
+
+		for i, light := range button.Lights {
+			lightState, err := s.roomState(lightRooms[i], button.State, slot.from)
+			if err != nil {
+				return nil, err
+			}
+
+			newRule.Actions = append(newRule.Actions, Action{
+				Address: fmt.Sprintf("/lights/%s/state", light),
+				Method:  http.MethodPut,
+				Body:    lightState.V1(),
+			})
+		}
+
+		rules = append(rules, newRule)
 	}
 
-	return newRule, nil
+	return rules, nil
 }
 
-func (s *Service) configureTap(ctx context.Context, groups []v2.Group, taps []configTap) {
+// tapRules returns the rules of every button of the taps, the ones that can't be resolved being reported in the error
+func (s *Service) tapRules(groups []v2.Group, taps []configTap) ([]Rule, error) {
 	tapDevices := s.v2Service.Taps()
 
+	var rules []Rule
+	var errs []error
+
 	for _, tap := range taps {
 		targetTap, err := getTap(tapDevices, tap.ID)
 		if err != nil {
-			slog.LogAttrs(ctx, slog.LevelError, "unable to configure tap", slog.String("id", tap.ID), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("tap `%s`: %w", tap.ID, err))
 			continue
 		}
 
 		for _, button := range tap.Buttons {
-			rule, err := s.createRuleDescription(groups, targetTap.IDV1, targetTap.Dial, button)
+			buttonRules, err := s.createRuleDescriptions(groups, targetTap.IDV1, targetTap.Dial, button)
 			if err != nil {
-				slog.LogAttrs(ctx, slog.LevelError, "create tap rule description", slog.Any("error", err))
+				errs = append(errs, fmt.Errorf("tap `%s` button `%s`: %w", tap.ID, button.ID, err))
+				continue
 			}
 
-			if err := s.createRule(ctx, &rule); err != nil {
-				slog.LogAttrs(ctx, slog.LevelError, "create tap rule", slog.Any("error", err))
-			}
+			rules = append(rules, buttonRules...)
 		}
 	}
+
+	return rules, errors.Join(errs...)
 }
@@ -0,0 +1,65 @@
+package hue
+
+import (
+	"reflect"
+	"testing"
+
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+func TestCreateRuleDescriptions(t *testing.T) {
+	instance := Service{
+		v2Service: &v2.Service{},
+		states:    States,
+		presets:   map[string][]preset{"bedroom": {{from: 23 * 60, to: 6 * 60, states: map[string]State{"on": States["dimmed"]}}}},
+	}
+
+	groups := []v2.Group{
+		{ID: "bedroom", IDV1: "1", Name: "Bedroom", Kind: "room", Lights: []*v2.Light{{ID: "bedside", IDV1: "3"}}},
+		{ID: "upstairs", IDV1: "2", Name: "Upstairs", Kind: "zone", Lights: []*v2.Light{{ID: "landing", IDV1: "4"}}},
+	}
+
+	cases := map[string]struct {
+		button configTapButton
+		want   [][]any
+	}{
+		"light without room": {
+			button: configTapButton{ID: "1", State: "on", Lights: []string{"4"}},
+			want:   [][]any{{States["on"].V1()}},
+		},
+		"light of a room with preset": {
+			button: configTapButton{ID: "1", State: "on", Lights: []string{"3", "4"}},
+			want: [][]any{
+				{States["on"].V1(), States["on"].V1()},
+				{States["dimmed"].V1(), States["on"].V1()},
+			},
+		},
+		"group and light": {
+			button: configTapButton{ID: "1", State: "off", Groups: []string{"Bedroom"}, Lights: []string{"3"}},
+			want: [][]any{
+				{States["off"].V1(), States["off"].V1()},
+				{States["off"].V1(), States["off"].V1()},
+			},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			rules, err := instance.createRuleDescriptions(groups, "5", false, tc.button)
+			if err != nil {
+				t.Fatalf("createRuleDescriptions() error = %v", err)
+			}
+
+			got := make([][]any, len(rules))
+			for i, rule := range rules {
+				for _, action := range rule.Actions {
+					got[i] = append(got[i], action.Body)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("createRuleDescriptions() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
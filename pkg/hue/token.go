@@ -0,0 +1,127 @@
+package hue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"github.com/ViBiOh/httputils/v4/pkg/renderer"
+	"github.com/ViBiOh/hue/pkg/auth"
+)
+
+const TokensPath = "/tokens"
+
+var (
+	tokenDurations = map[string]time.Duration{
+		"hour":  time.Hour,
+		"day":   time.Hour * 24,
+		"week":  time.Hour * 24 * 7,
+		"month": time.Hour * 24 * 30,
+		"year":  time.Hour * 24 * 365,
+	}
+	tokenDurationNames = []string{"hour", "day", "week", "month", "year"}
+)
+
+// TemplateTokens renders the access tokens, for admins only
+func (s *Service) TemplateTokens(w http.ResponseWriter, r *http.Request) (renderer.Page, error) {
+	ctx := r.Context()
+
+	if !s.auth.Enabled() {
+		return renderer.Page{}, model.WrapNotFound(errors.New("access tokens need authentication to be enabled"))
+	}
+
+	if err := s.auth.Check(ctx, auth.Admin); err != nil {
+		return renderer.Page{}, err
+	}
+
+	return renderer.NewPage("tokens", http.StatusOK, s.withUser(w, r, map[string]any{
+		"Tokens":         s.auth.Tokens(),
+		"Groups":         s.v2Service.Groups(),
+		"Sensors":        s.v2Service.Sensors(),
+		"TokenDurations": tokenDurationNames,
+		"TokenParam":     auth.TokenParam,
+	})), nil
+}
+
+func (s *Service) HandleToken(w http.ResponseWriter, r *http.Request) {
+	if !s.auth.Enabled() {
+		s.renderer.Error(w, r, nil, model.WrapNotFound(errors.New("access tokens need authentication to be enabled")))
+		return
+	}
+
+	if err := s.checkForm(r, auth.Admin); err != nil {
+		s.renderer.Error(w, r, nil, err)
+		return
+	}
+
+	switch r.FormValue("method") {
+	case http.MethodPost:
+		s.handleTokenCreate(w, r)
+	case http.MethodDelete:
+		s.handleTokenRevoke(w, r)
+	default:
+		s.renderer.Error(w, r, nil, model.WrapMethodNotAllowed(errors.New("invalid method for updating token")))
+	}
+}
+
+func (s *Service) handleTokenCreate(w http.ResponseWriter, r *http.Request) {
+	role, err := auth.ParseRole(r.FormValue("role"))
+	if err != nil {
+		s.renderer.Error(w, r, nil, model.WrapInvalid(err))
+		return
+	}
+
+	duration, ok := tokenDurations[r.FormValue("duration")]
+	if !ok {
+		s.renderer.Error(w, r, nil, model.WrapInvalid(fmt.Errorf("unknown duration `%s`", r.FormValue("duration"))))
+		return
+	}
+
+	scope := auth.Scope{
+		Groups:  r.Form["groups"],
+		Sensors: r.Form["sensors"],
+	}
+
+	if err = s.checkScope(r.Context(), scope); err != nil {
+		s.renderer.Error(w, r, nil, err)
+		return
+	}
+
+	token, err := s.auth.CreateToken(r.FormValue("name"), role, scope, duration)
+	if err != nil {
+		s.renderer.Error(w, r, nil, err)
+		return
+	}
+
+	s.renderer.Redirect(w, r, TokensPath, renderer.NewSuccessMessage("Token %s created", token.Name))
+}
+
+func (s *Service) handleTokenRevoke(w http.ResponseWriter, r *http.Request) {
+	token, err := s.auth.RevokeToken(r.PathValue("id"))
+	if err != nil {
+		s.renderer.Error(w, r, nil, err)
+		return
+	}
+
+	s.renderer.Redirect(w, r, TokensPath, renderer.NewSuccessMessage("Token %s revoked", token.Name))
+}
+
+// checkScope verifies that the groups and sensors of a scope exist, and are visible to the user of the context
+func (s *Service) checkScope(ctx context.Context, scope auth.Scope) error {
+	for _, id := range scope.Groups {
+		if _, ok := s.v2Service.Group(id); !ok || checkGroupScope(ctx, id) != nil {
+			return model.WrapInvalid(fmt.Errorf("unknown group `%s`", id))
+		}
+	}
+
+	for _, id := range scope.Sensors {
+		if _, ok := s.v2Service.Sensor(id); !ok || checkSensorScope(ctx, id) != nil {
+			return model.WrapInvalid(fmt.Errorf("unknown sensor `%s`", id))
+		}
+	}
+
+	return nil
+}
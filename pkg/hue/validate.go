@@ -0,0 +1,506 @@
+package hue
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+// location is a 1-based position in a file
+type location struct {
+	Line   int
+	Column int
+}
+
+// Issue is a part of the configuration file that can't be resolved against the bridge, or that is malformed
+type Issue struct {
+	Filename string
+	Path     string
+	Message  string
+	Line     int
+	Column   int
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", i.Filename, i.Line, i.Column, i.Path, i.Message)
+}
+
+// configError is an error of the value at the given path of the configuration file
+type configError struct {
+	err  error
+	path string
+}
+
+func (e configError) Error() string {
+	return e.err.Error()
+}
+
+func (e configError) Unwrap() error {
+	return e.err
+}
+
+// validation collects the issues of the configuration file, located by the path of their value
+type validation struct {
+	locations map[string]location
+	filename  string
+	issues    []Issue
+}
+
+func (v *validation) report(path string, err error) {
+	position := v.locate(path)
+
+	v.issues = append(v.issues, Issue{
+		Filename: v.filename,
+		Path:     path,
+		Message:  err.Error(),
+		Line:     position.Line,
+		Column:   position.Column,
+	})
+}
+
+// reportErrors reports every error joined in the given one, at the path of the configuration it relates to if known
+func (v *validation) reportErrors(err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, item := range joined.Unwrap() {
+			v.reportErrors(item)
+		}
+
+		return
+	}
+
+	if err == nil {
+		return
+	}
+
+	var configErr configError
+	if errors.As(err, &configErr) {
+		v.report(configErr.path, configErr.err)
+		return
+	}
+
+	v.report("", err)
+}
+
+// locate returns the position of the value at the path, or else of its closest parent
+func (v *validation) locate(path string) location {
+	key := strings.ToLower(path)
+
+	for len(key) != 0 {
+		if position, ok := v.locations[key]; ok {
+			return position
+		}
+
+		index := strings.LastIndexAny(key, ".[")
+		if index == -1 {
+			break
+		}
+
+		key = key[:index]
+	}
+
+	return v.locations[""]
+}
+
+// Validate checks the configuration file against the inventory of the bridge and returns every group, light, sensor, tap, scene or state
+// it refers to that can't be found, along with the malformed states, presets, times and delays, sorted by their position in the file
+func (s *Service) Validate() ([]Issue, error) {
+	if len(s.configFileName) == 0 {
+		return nil, errors.New("no configuration file")
+	}
+
+	content, err := os.ReadFile(s.configFileName)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	locations, err := jsonLocations(content)
+	if err != nil {
+		return nil, fmt.Errorf("locate `%s`: %w", s.configFileName, err)
+	}
+
+	output := validation{
+		filename:  s.configFileName,
+		locations: locations,
+	}
+
+	output.reportErrors(s.configErr)
+
+	groups := s.v2Service.Groups()
+	scenes := s.plannedScenes(groups)
+
+	s.validateSchedules(&output, groups, scenes)
+	s.validateSensors(&output, groups)
+	s.validateTaps(&output, groups)
+	s.validateSmartScenes(&output, groups, scenes)
+	s.validateMotionSensors(&output)
+	s.validateRooms(&output, groups)
+
+	slices.SortStableFunc(output.issues, func(a, b Issue) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+
+	return output.issues, nil
+}
+
+// plannedScenes returns the lowercased names of the scenes saved for the schedules recalling a state, by group ID, that aren't on the bridge until the configuration is applied
+func (s *Service) plannedScenes(groups []v2.Group) map[string]map[string]struct{} {
+	output := make(map[string]map[string]struct{})
+
+	for _, config := range s.config.Schedules {
+		if len(config.Scene) != 0 {
+			continue
+		}
+
+		group, err := getGroup(groups, config.Group)
+		if err != nil {
+			continue
+		}
+
+		if output[group.ID] == nil {
+			output[group.ID] = make(map[string]struct{})
+		}
+
+		output[group.ID][strings.ToLower(config.Name)] = struct{}{}
+	}
+
+	return output
+}
+
+func (s *Service) validateSchedules(output *validation, groups []v2.Group, scenes map[string]map[string]struct{}) {
+	for i, config := range s.config.Schedules {
+		path := fmt.Sprintf("schedules[%d]", i)
+
+		if len(config.Name) == 0 {
+			output.report(path+".name", errors.New("name is required"))
+		}
+
+		if err := validateLocaltime(config.Localtime); err != nil {
+			output.report(path+".localtime", err)
+		}
+
+		group, err := getGroup(groups, config.Group)
+		if err != nil {
+			output.report(path+".group", err)
+			continue
+		}
+
+		if len(config.Scene) != 0 {
+			if _, ok := s.groupScene(group.ID, config.Scene); !ok {
+				if _, ok = scenes[group.ID][strings.ToLower(config.Scene)]; !ok {
+					output.report(path+".scene", fmt.Errorf("unknown scene `%s` in `%s`", config.Scene, group.Name))
+				}
+			}
+
+			continue
+		}
+
+		if len(config.State) == 0 {
+			output.report(path, errors.New("a scene or a state is required"))
+			continue
+		}
+
+		if err := s.sceneConflict(group, config.Name); err != nil {
+			output.report(path+".name", err)
+		}
+
+		minute, ok := localtimeMinute(config.Localtime)
+		if !ok {
+			minute = minuteOfDay(s.now())
+		}
+
+		if _, err = s.roomState(group.Name, config.State, minute); err != nil {
+			output.report(path+".state", s.roomStateError(group.Name, config.State, err))
+		}
+	}
+}
+
+func (s *Service) validateSensors(output *validation, groups []v2.Group) {
+	motionDevices := s.v2Service.Sensors()
+
+	for i, sensor := range s.config.Sensors {
+		path := fmt.Sprintf("sensors[%d]", i)
+
+		if _, err := getMotionSensor(motionDevices, sensor.ID); err != nil {
+			output.report(path+".id", err)
+		}
+
+		if sensor.DarkLux < 0 {
+			output.report(path+".dark_lux", fmt.Errorf("dark lux must be positive, got %g", sensor.DarkLux))
+		}
+
+		states := []string{"on"}
+
+		if len(sensor.OffDelay) != 0 {
+			states = append(states, "long_off")
+
+			if err := validateDelay(sensor.OffDelay); err != nil {
+				output.report(path+".offDelay", err)
+			}
+		}
+
+		s.validateGroupStates(output, groups, path+".groups", sensor.Groups, states...)
+	}
+}
+
+func (s *Service) validateTaps(output *validation, groups []v2.Group) {
+	tapDevices := s.v2Service.Taps()
+
+	lights := make(map[string]struct{})
+	for _, light := range s.v2Service.Lights() {
+		lights[light.IDV1] = struct{}{}
+	}
+
+	for i, tap := range s.config.Taps {
+		path := fmt.Sprintf("taps[%d]", i)
+
+		targetTap, err := getTap(tapDevices, tap.ID)
+		if err != nil {
+			output.report(path+".id", err)
+		}
+
+		for j, button := range tap.Buttons {
+			buttonPath := fmt.Sprintf("%s.buttons[%d]", path, j)
+
+			if len(getButtonMapping(targetTap.Dial, button.ID, button.Long)) == 0 {
+				output.report(buttonPath+".id", fmt.Errorf("unknown button `%s`", button.ID))
+			}
+
+			if _, err = s.state(button.State); err != nil {
+				output.report(buttonPath+".state", err)
+			} else {
+				s.validateGroupStates(output, groups, buttonPath+".groups", button.Groups, button.State)
+			}
+
+			for k, light := range button.Lights {
+				if _, ok := lights[light]; !ok {
+					output.report(fmt.Sprintf("%s.lights[%d]", buttonPath, k), fmt.Errorf("light `%s` not found", light))
+				}
+			}
+		}
+	}
+}
+
+// validateGroupStates reports the groups that can't be found, and the states that don't exist in a group at any time of the day
+func (s *Service) validateGroupStates(output *validation, groups []v2.Group, path string, names []string, states ...string) {
+	for i, name := range names {
+		groupPath := fmt.Sprintf("%s[%d]", path, i)
+
+		group, err := getGroup(groups, name)
+		if err != nil {
+			output.report(groupPath, err)
+			continue
+		}
+
+		for _, state := range states {
+			for _, slot := range s.timeSlots([]string{group.Name}) {
+				if _, err = s.roomState(group.Name, state, slot.from); err != nil {
+					err = s.roomStateError(group.Name, state, err)
+
+					if !slot.wholeDay() {
+						err = fmt.Errorf("from %s: %w", formatMinute(slot.from), err)
+					}
+
+					output.report(groupPath, err)
+
+					break
+				}
+			}
+		}
+	}
+}
+
+// roomStateError explains why a state is missing from the room, the profile of the room restricting its states
+func (s *Service) roomStateError(room, name string, err error) error {
+	if _, ok := s.states[name]; ok && !s.allowsState(room, name) {
+		return fmt.Errorf("state `%s` isn't allowed by the profile of `%s`", name, room)
+	}
+
+	return fmt.Errorf("%s: %w", room, err)
+}
+
+func (s *Service) validateSmartScenes(output *validation, groups []v2.Group, scenes map[string]map[string]struct{}) {
+	for i, config := range s.config.SmartScenes {
+		path := fmt.Sprintf("smart_scenes[%d]", i)
+
+		if len(config.Name) == 0 {
+			output.report(path+".name", errors.New("name is required"))
+		}
+
+		if len(config.Transition) != 0 {
+			if transition, err := time.ParseDuration(config.Transition); err != nil {
+				output.report(path+".transition", fmt.Errorf("parse transition: %w", err))
+			} else if transition < 0 || transition > maxTransition {
+				output.report(path+".transition", fmt.Errorf("transition must be between 0 and %s", maxTransition))
+			}
+		}
+
+		group, groupErr := getGroup(groups, config.Group)
+		if groupErr != nil {
+			output.report(path+".group", groupErr)
+		} else if err := s.smartSceneConflict(group, s.v2Service.SmartScenes()[group.ID], config.Name); err != nil {
+			output.report(path+".name", err)
+		}
+
+		for j, week := range config.Week {
+			weekPath := fmt.Sprintf("%s.week[%d]", path, j)
+
+			for k, day := range week.Days {
+				if !slices.Contains(v2.Weekdays, strings.ToLower(day)) {
+					output.report(fmt.Sprintf("%s.days[%d]", weekPath, k), fmt.Errorf("unknown day `%s`", day))
+				}
+			}
+
+			for k, slot := range week.Slots {
+				slotPath := fmt.Sprintf("%s.slots[%d]", weekPath, k)
+
+				if _, err := parseTimeslotStart(slot.Start); err != nil {
+					output.report(slotPath+".start", err)
+				}
+
+				if groupErr != nil {
+					continue
+				}
+
+				if _, ok := findScene(s.v2Service.Scenes()[group.ID], slot.Scene); !ok {
+					if _, ok = scenes[group.ID][strings.ToLower(slot.Scene)]; !ok {
+						output.report(slotPath+".scene", fmt.Errorf("unknown scene `%s` in `%s`", slot.Scene, group.Name))
+					}
+				}
+			}
+		}
+	}
+}
+
+func (s *Service) validateMotionSensors(output *validation) {
+	sensors := s.v2Service.Sensors()
+
+	for i, item := range s.config.MotionSensors.Crons {
+		path := fmt.Sprintf("motion_sensors.crons[%d]", i)
+
+		if _, err := parseMinute(item.Hour); err != nil {
+			output.report(path+".hour", err)
+		}
+
+		if len(item.Timezone) != 0 {
+			if _, err := time.LoadLocation(item.Timezone); err != nil {
+				output.report(path+".timezone", fmt.Errorf("unknown timezone `%s`", item.Timezone))
+			}
+		}
+
+		for j, name := range item.Names {
+			if !slices.ContainsFunc(sensors, func(sensor v2.MotionSensor) bool { return sensor.Name == name }) {
+				output.report(fmt.Sprintf("%s.names[%d]", path, j), fmt.Errorf("motion sensor `%s` not found", name))
+			}
+		}
+	}
+}
+
+// validateRooms reports the presets and the profiles of rooms that don't exist, the unknown states of the profiles and the calibrations of unknown sensors
+func (s *Service) validateRooms(output *validation, groups []v2.Group) {
+	for room := range s.config.Presets {
+		if _, err := getGroup(groups, room); err != nil {
+			output.report("presets."+room, err)
+		}
+	}
+
+	for room, profile := range s.v2Service.Profiles() {
+		if _, err := getGroup(groups, room); err != nil {
+			output.report("rooms."+room, err)
+		}
+
+		for i, name := range profile.Presets {
+			if !slices.ContainsFunc(s.stateNames(), func(state string) bool { return strings.EqualFold(state, name) }) {
+				output.report(fmt.Sprintf("rooms.%s.presets[%d]", room, i), fmt.Errorf("unknown state `%s`", name))
+			}
+		}
+	}
+
+	sensors := s.v2Service.Sensors()
+
+	for name := range s.v2Service.TemperatureOffsets() {
+		if !slices.ContainsFunc(sensors, func(sensor v2.MotionSensor) bool { return sensor.Name == name }) {
+			output.report("temperature_offsets."+name, fmt.Errorf("motion sensor `%s` not found", name))
+		}
+	}
+}
+
+// jsonLocations returns the position of every value of the JSON document, by lowercased path like `schedules[0].group`, the document being at the empty path
+func jsonLocations(content []byte) (map[string]location, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	output := make(map[string]location)
+
+	var walk func(path string) error
+
+	walk = func(path string) error {
+		offset := valueStart(content, decoder.InputOffset())
+
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		output[path] = position(content, offset)
+
+		delim, ok := token.(json.Delim)
+		if !ok {
+			return nil
+		}
+
+		for i := 0; decoder.More(); i++ {
+			child := fmt.Sprintf("%s[%d]", path, i)
+
+			if delim == '{' {
+				key, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+
+				child = strings.ToLower(fmt.Sprint(key))
+				if len(path) != 0 {
+					child = path + "." + child
+				}
+			}
+
+			if err = walk(child); err != nil {
+				return err
+			}
+		}
+
+		_, err = decoder.Token()
+
+		return err
+	}
+
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// valueStart skips the whitespaces and the separators preceding a value
+func valueStart(content []byte, offset int64) int64 {
+	for offset < int64(len(content)) && strings.IndexByte(" \t\r\n:,", content[offset]) != -1 {
+		offset++
+	}
+
+	return offset
+}
+
+// position converts an offset of the content to a 1-based line and column
+func position(content []byte, offset int64) location {
+	before := content[:min(offset, int64(len(content)))]
+
+	return location{
+		Line:   bytes.Count(before, []byte("\n")) + 1,
+		Column: len(before) - bytes.LastIndexByte(before, '\n'),
+	}
+}
@@ -0,0 +1,156 @@
+package hue
+
+import (
+	"slices"
+	"testing"
+
+	v2 "github.com/ViBiOh/hue/pkg/v2"
+)
+
+func TestJSONLocations(t *testing.T) {
+	content := []byte(`{
+  "schedules": [
+    {
+      "name": "Wake up",
+      "group": "Bedroom"
+    }
+  ],
+  "Presets": {"Living Room": []}
+}`)
+
+	cases := map[string]struct {
+		path string
+		want location
+	}{
+		"document": {
+			path: "",
+			want: location{Line: 1, Column: 1},
+		},
+		"array": {
+			path: "schedules",
+			want: location{Line: 2, Column: 16},
+		},
+		"item": {
+			path: "schedules[0]",
+			want: location{Line: 3, Column: 5},
+		},
+		"value": {
+			path: "schedules[0].group",
+			want: location{Line: 5, Column: 16},
+		},
+		"lowercased key": {
+			path: "presets.living room",
+			want: location{Line: 8, Column: 30},
+		},
+	}
+
+	locations, err := jsonLocations(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := locations[tc.path]; got != tc.want {
+				t.Errorf("jsonLocations()[`%s`] = %+v, want %+v", tc.path, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidationLocate(t *testing.T) {
+	output := validation{
+		locations: map[string]location{
+			"":                    {Line: 1, Column: 1},
+			"sensors[0]":          {Line: 3, Column: 5},
+			"sensors[0].offdelay": {Line: 5, Column: 19},
+		},
+	}
+
+	cases := map[string]struct {
+		path string
+		want location
+	}{
+		"exact": {
+			path: "sensors[0].offDelay",
+			want: location{Line: 5, Column: 19},
+		},
+		"parent": {
+			path: "sensors[0].groups[1]",
+			want: location{Line: 3, Column: 5},
+		},
+		"document": {
+			path: "taps[0].id",
+			want: location{Line: 1, Column: 1},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := output.locate(tc.path); got != tc.want {
+				t.Errorf("locate() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidationReportErrors(t *testing.T) {
+	brightness := uint64(15)
+
+	cases := map[string]struct {
+		config   configHue
+		profiles map[string]v2.RoomProfile
+		want     []string
+	}{
+		"valid": {
+			config: configHue{States: map[string]groupPatch{"reading": {State: "on"}}},
+		},
+		"unknown state": {
+			config: configHue{States: map[string]groupPatch{"reading": {State: "party"}, "relax": {State: "dimmed"}}},
+			want:   []string{"states.reading"},
+		},
+		"presets": {
+			config: configHue{Presets: map[string][]configPreset{
+				"Bedroom": {
+					{From: "23:00", To: "06:00", States: map[string]groupPatch{"party": {Brightness: &brightness}}},
+					{From: "5pm", To: "06:00", States: map[string]groupPatch{"on": {Brightness: &brightness}}},
+				},
+				"Kitchen": {
+					{From: "22:00", To: "06:00", States: map[string]groupPatch{"on": {Brightness: &brightness}}},
+					{From: "05:00", To: "08:00", States: map[string]groupPatch{"on": {Brightness: &brightness}}},
+				},
+			}},
+			want: []string{"presets.Bedroom[0].states.party", "presets.Bedroom[1].from", "presets.Kitchen[1]"},
+		},
+		"profiles": {
+			profiles: map[string]v2.RoomProfile{
+				"Office":  {Temperature: "hot"},
+				"Bedroom": {Temperature: "warm", Brightness: map[string]uint64{"on": 120}},
+			},
+			want: []string{"rooms.Bedroom.brightness.on", "rooms.Office.temperature"},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var output validation
+
+			states, err := buildStates(tc.config)
+			output.reportErrors(err)
+
+			_, err = buildPresets(tc.config, states)
+			output.reportErrors(err)
+
+			output.reportErrors(validateProfiles(tc.profiles))
+
+			var got []string
+			for _, issue := range output.issues {
+				got = append(got, issue.Path)
+			}
+
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("reportErrors() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
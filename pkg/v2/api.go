@@ -4,10 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"path"
+	"time"
 
 	"github.com/ViBiOh/httputils/v4/pkg/httpjson"
 	"github.com/ViBiOh/httputils/v4/pkg/request"
+	"github.com/ViBiOh/httputils/v4/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // APIResponse description
@@ -18,8 +23,8 @@ type APIResponse[T any] struct {
 	} `json:"errors"`
 }
 
-func list[T any](ctx context.Context, req request.Request, kind string) (output []T, err error) {
-	resp, err := req.Path(path.Join("/clip/v2/resource", kind)).Send(ctx, nil)
+func list[T any](ctx context.Context, s *Service, kind string) (output []T, err error) {
+	resp, err := s.send(ctx, http.MethodGet, kind, "", nil)
 	if err != nil {
 		return output, fmt.Errorf("list: %w", err)
 	}
@@ -34,8 +39,8 @@ func list[T any](ctx context.Context, req request.Request, kind string) (output
 	return output, err
 }
 
-func stream[T any](ctx context.Context, req request.Request, kind string, output chan<- T) (err error) {
-	resp, err := req.Path(path.Join("/clip/v2/resource", kind)).Send(ctx, nil)
+func stream[T any](ctx context.Context, s *Service, kind string, output chan<- T) error {
+	resp, err := s.send(ctx, http.MethodGet, kind, "", nil)
 	if err != nil {
 		return fmt.Errorf("list: %w", err)
 	}
@@ -43,8 +48,8 @@ func stream[T any](ctx context.Context, req request.Request, kind string, output
 	return httpjson.Stream(resp.Body, output, "data", true)
 }
 
-func get[T any](ctx context.Context, req request.Request, kind, id string) (output T, err error) {
-	resp, err := req.Path(path.Join("/clip/v2/resource", kind, id)).Send(ctx, nil)
+func get[T any](ctx context.Context, s *Service, kind, id string) (output T, err error) {
+	resp, err := s.send(ctx, http.MethodGet, kind, id, nil)
 	if err != nil {
 		return output, fmt.Errorf("get: %w", err)
 	}
@@ -60,3 +65,76 @@ func get[T any](ctx context.Context, req request.Request, kind, id string) (outp
 
 	return content.Data[0], nil
 }
+
+// create posts a new resource of the given kind and returns its ID
+func (s *Service) create(ctx context.Context, kind string, payload any) (string, error) {
+	resp, err := s.send(ctx, http.MethodPost, kind, "", payload)
+	if err != nil {
+		return "", fmt.Errorf("create: %w", err)
+	}
+
+	content, err := httpjson.Read[APIResponse[deviceReference]](resp)
+	if err != nil {
+		return "", fmt.Errorf("parse: %w", err)
+	}
+
+	if len(content.Data) == 0 {
+		return "", errors.New("nothing created")
+	}
+
+	return content.Data[0].Rid, nil
+}
+
+func (s *Service) update(ctx context.Context, kind, id string, payload any) error {
+	_, err := s.send(ctx, http.MethodPut, kind, id, payload)
+
+	return err
+}
+
+func (s *Service) remove(ctx context.Context, kind, id string) error {
+	_, err := s.send(ctx, http.MethodDelete, kind, id, nil)
+
+	return err
+}
+
+// send performs a request on the given bridge's resource, traced and timed. Payload is sent as JSON if not nil
+func (s *Service) send(ctx context.Context, method, kind, id string, payload any) (resp *http.Response, err error) {
+	start := time.Now()
+
+	attributes := []attribute.KeyValue{
+		attribute.String("resource", kind),
+		attribute.String("method", method),
+	}
+
+	ctx, end := telemetry.StartSpan(ctx, s.tracer, "bridge", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(append(attributes, attribute.String("id", id))...))
+	defer end(&err)
+
+	req := s.req.Method(method).Path(path.Join("/clip/v2/resource", kind, id))
+
+	if payload != nil {
+		resp, err = req.JSON(ctx, payload)
+	} else {
+		resp, err = req.Send(ctx, nil)
+	}
+
+	if status := responseStatus(resp, err); status != 0 {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int("status", status))
+	}
+
+	s.RecordRequest(ctx, "v2", kind, method, time.Since(start), err)
+
+	return resp, err
+}
+
+func responseStatus(resp *http.Response, err error) int {
+	var responseErr request.Error
+	if errors.As(err, &responseErr) {
+		return responseErr.StatusCode
+	}
+
+	if resp != nil {
+		return resp.StatusCode
+	}
+
+	return 0
+}
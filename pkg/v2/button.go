@@ -0,0 +1,57 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type Button struct {
+	Owner    deviceReference `json:"owner"`
+	ID       string          `json:"id"`
+	Metadata struct {
+		ControlID int `json:"control_id"`
+	} `json:"metadata"`
+}
+
+func (s *Service) buildButtons(ctx context.Context) (map[string]Button, error) {
+	buttons, err := list[Button](ctx, s, "button")
+	if err != nil {
+		return nil, fmt.Errorf("list buttons: %w", err)
+	}
+
+	output := make(map[string]Button, len(buttons))
+	for _, button := range buttons {
+		output[button.ID] = button
+	}
+
+	return output, nil
+}
+
+func (s *Service) countButton(ctx context.Context, id, owner, event string) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	kind := "switch"
+	name := s.devices[owner].Metadata.Name
+	if tap, ok := s.taps[owner]; ok {
+		kind = "tap"
+		name = tap.Name
+	}
+
+	var button string
+	if item, ok := s.buttons[id]; ok {
+		button = strconv.Itoa(item.Metadata.ControlID)
+	}
+
+	s.buttonMetric.Add(ctx, 1, metric.WithAttributes(append(s.deviceAttributes(kind, owner, name),
+		attribute.String("button", button),
+		attribute.String("event", event),
+	)...))
+
+	slog.LogAttrs(ctx, slog.LevelDebug, "Button", slog.String("name", name), slog.String("button", button), slog.String("event", event))
+}
@@ -0,0 +1,208 @@
+package v2
+
+import (
+	"sync"
+)
+
+const (
+	GroupChange  = "group"
+	SensorChange = "sensor"
+)
+
+// Change is the state of a group or a sensor after an event has been received from the bridge
+type Change struct {
+	Group  *GroupState  `json:"group,omitempty"`
+	Sensor *SensorState `json:"sensor,omitempty"`
+	Kind   string       `json:"kind"`
+	ID     string       `json:"id"`
+}
+
+type GroupState struct {
+	Unreachable []string `json:"unreachable"`
+	On          bool     `json:"on"`
+	Reachable   bool     `json:"reachable"`
+}
+
+type SensorState struct {
+	Temperature  float64 `json:"temperature"`
+	Lux          float64 `json:"lux"`
+	BatteryLevel int64   `json:"battery_level"`
+	Enabled      bool    `json:"enabled"`
+	Motion       bool    `json:"motion"`
+	Reachable    bool    `json:"reachable"`
+}
+
+// Subscription receives changes without ever blocking the publisher: only the last change of each group or sensor is kept until read
+type Subscription struct {
+	pending map[string]Change
+	notify  chan struct{}
+	done    chan struct{}
+	mutex   sync.Mutex
+}
+
+// Notify is signaled when changes are pending
+func (s *Subscription) Notify() <-chan struct{} {
+	return s.notify
+}
+
+// Done is closed when the service stops streaming events
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Changes returns and clears the pending changes
+func (s *Subscription) Changes() []Change {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	output := make([]Change, 0, len(s.pending))
+	for _, change := range s.pending {
+		output = append(output, change)
+	}
+
+	clear(s.pending)
+
+	return output
+}
+
+func (s *Subscription) push(change Change) {
+	s.mutex.Lock()
+	s.pending[change.Kind+"/"+change.ID] = change
+	s.mutex.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Service) Subscribe() *Subscription {
+	subscription := &Subscription{
+		pending: make(map[string]Change),
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	s.subscriptionsMutex.Lock()
+	defer s.subscriptionsMutex.Unlock()
+
+	if s.subscriptionsClosed {
+		close(subscription.done)
+		return subscription
+	}
+
+	if s.subscriptions == nil {
+		s.subscriptions = make(map[*Subscription]struct{})
+	}
+
+	s.subscriptions[subscription] = struct{}{}
+
+	return subscription
+}
+
+func (s *Service) Unsubscribe(subscription *Subscription) {
+	s.subscriptionsMutex.Lock()
+	defer s.subscriptionsMutex.Unlock()
+
+	delete(s.subscriptions, subscription)
+}
+
+func (s *Service) closeSubscriptions() {
+	s.subscriptionsMutex.Lock()
+	defer s.subscriptionsMutex.Unlock()
+
+	for subscription := range s.subscriptions {
+		close(subscription.done)
+	}
+
+	s.subscriptions = nil
+	s.subscriptionsClosed = true
+}
+
+func (s *Service) publishChanges(kind, id, owner string) {
+	s.subscriptionsMutex.Lock()
+	defer s.subscriptionsMutex.Unlock()
+
+	if len(s.subscriptions) == 0 {
+		return
+	}
+
+	changes := s.changes(kind, id, owner)
+
+	for subscription := range s.subscriptions {
+		for _, change := range changes {
+			subscription.push(change)
+		}
+	}
+}
+
+// changes returns the state of groups and sensors affected by an event of the given type
+func (s *Service) changes(kind, id, owner string) []Change {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var output []Change
+
+	switch kind {
+	case "motion", "light_level", "temperature", "device_power":
+		output = s.appendSensorChange(output, owner)
+	case "zigbee_connectivity", "zgp_connectivity":
+		output = s.appendSensorChange(output, owner)
+
+		for _, group := range s.groups {
+			for _, light := range group.Lights {
+				if light.Owner.Rid == owner {
+					output = append(output, groupChange(group))
+					break
+				}
+			}
+		}
+	case "light":
+		for _, group := range s.groups {
+			for _, light := range group.Lights {
+				if light.ID == id {
+					output = append(output, groupChange(group))
+					break
+				}
+			}
+		}
+	case "grouped_light":
+		if group, ok := s.getGroupOfGroupedLight(id); ok {
+			output = append(output, groupChange(group))
+		}
+	}
+
+	return output
+}
+
+func (s *Service) appendSensorChange(output []Change, owner string) []Change {
+	motionSensor, ok := s.motionSensors[owner]
+	if !ok {
+		return output
+	}
+
+	return append(output, Change{
+		Kind: SensorChange,
+		ID:   motionSensor.ID,
+		Sensor: &SensorState{
+			Temperature:  motionSensor.Temperature,
+			Lux:          motionSensor.Lux(),
+			BatteryLevel: motionSensor.BatteryLevel,
+			Enabled:      motionSensor.Enabled,
+			Motion:       motionSensor.Motion,
+			Reachable:    motionSensor.Connectivity.Reachable(),
+		},
+	})
+}
+
+func groupChange(group Group) Change {
+	return Change{
+		Kind: GroupChange,
+		ID:   group.ID,
+		Group: &GroupState{
+			On:          group.AnyOn(),
+			Reachable:   group.Reachable(),
+			Unreachable: group.Unreachable(),
+		},
+	}
+}
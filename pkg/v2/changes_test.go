@@ -0,0 +1,79 @@
+package v2
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPublishChanges(t *testing.T) {
+	light := &Light{ID: "light", Owner: deviceReference{Rid: "bulb"}, On: On{On: true}}
+
+	type event struct {
+		kind  string
+		id    string
+		owner string
+	}
+
+	cases := map[string]struct {
+		events []event
+		want   []Change
+	}{
+		"sensor": {
+			events: []event{{kind: "temperature", id: "temperature", owner: "sensor"}},
+			want: []Change{{Kind: SensorChange, ID: "sensor", Sensor: &SensorState{
+				Temperature:  21.5,
+				BatteryLevel: 80,
+				Enabled:      true,
+				Reachable:    true,
+			}}},
+		},
+		"coalesced": {
+			events: []event{
+				{kind: "motion", id: "motion", owner: "sensor"},
+				{kind: "temperature", id: "temperature", owner: "sensor"},
+				{kind: "device_power", id: "power", owner: "sensor"},
+			},
+			want: []Change{{Kind: SensorChange, ID: "sensor", Sensor: &SensorState{
+				Temperature:  21.5,
+				BatteryLevel: 80,
+				Enabled:      true,
+				Reachable:    true,
+			}}},
+		},
+		"light": {
+			events: []event{{kind: "light", id: "light", owner: "bulb"}},
+			want: []Change{{Kind: GroupChange, ID: "room", Group: &GroupState{
+				On:        true,
+				Reachable: true,
+			}}},
+		},
+		"unknown": {
+			events: []event{{kind: "device_power", id: "power", owner: "tap"}},
+			want:   []Change{},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			instance := &Service{
+				motionSensors: map[string]MotionSensor{
+					"sensor": {ID: "sensor", Temperature: 21.5, BatteryLevel: 80, Enabled: true},
+				},
+				groups: map[string]Group{
+					"room": {ID: "room", Lights: []*Light{light}},
+				},
+			}
+
+			subscription := instance.Subscribe()
+			defer instance.Unsubscribe(subscription)
+
+			for _, item := range tc.events {
+				instance.publishChanges(item.kind, item.id, item.owner)
+			}
+
+			if got := subscription.Changes(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("publishChanges() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
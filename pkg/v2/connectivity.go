@@ -0,0 +1,131 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type ConnectivityStatus string
+
+const (
+	Connected              ConnectivityStatus = "connected"
+	Disconnected           ConnectivityStatus = "disconnected"
+	ConnectivityIssue      ConnectivityStatus = "connectivity_issue"
+	UnidirectionalIncoming ConnectivityStatus = "unidirectional_incoming"
+)
+
+var connectivityStatuses = []ConnectivityStatus{Connected, Disconnected, ConnectivityIssue, UnidirectionalIncoming}
+
+type Connectivity struct {
+	Since  time.Time          `json:"since"`
+	Status ConnectivityStatus `json:"status"`
+}
+
+// Reachable returns true when the device is connected or when its status is still unknown
+func (c Connectivity) Reachable() bool {
+	return len(c.Status) == 0 || c.Status == Connected
+}
+
+type ZigbeeConnectivity struct {
+	Owner  deviceReference    `json:"owner"`
+	ID     string             `json:"id"`
+	Status ConnectivityStatus `json:"status"`
+}
+
+func (s *Service) initConnectivity(ctx context.Context) error {
+	for _, kind := range []string{"zigbee_connectivity", "zgp_connectivity"} {
+		connectivities, err := list[ZigbeeConnectivity](ctx, s, kind)
+		if err != nil {
+			return fmt.Errorf("list %s: %w", kind, err)
+		}
+
+		for _, connectivity := range connectivities {
+			s.updateConnectivity(ctx, connectivity.Owner.Rid, connectivity.Status)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) updateConnectivity(ctx context.Context, owner string, status ConnectivityStatus) {
+	if len(status) == 0 {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	var found bool
+
+	for _, light := range s.lights {
+		if light.Owner.Rid == owner {
+			light.Connectivity = s.changeConnectivity(ctx, "light", owner, light.Metadata.Name, light.Connectivity, status, now)
+			found = true
+		}
+	}
+
+	if motionSensor, ok := s.motionSensors[owner]; ok {
+		motionSensor.Connectivity = s.changeConnectivity(ctx, "motion", owner, motionSensor.Name, motionSensor.Connectivity, status, now)
+		s.motionSensors[owner] = motionSensor
+		found = true
+	}
+
+	if tap, ok := s.taps[owner]; ok {
+		tap.Connectivity = s.changeConnectivity(ctx, "tap", owner, tap.Name, tap.Connectivity, status, now)
+		s.taps[owner] = tap
+		found = true
+	}
+
+	if !found {
+		slog.LogAttrs(ctx, slog.LevelDebug, "unknown connectivity owner ID", slog.String("owner", owner))
+	}
+}
+
+func (s *Service) changeConnectivity(ctx context.Context, kind, id, name string, previous Connectivity, status ConnectivityStatus, now time.Time) Connectivity {
+	device := s.deviceAttributes(kind, id, name)
+	attributes := metric.WithAttributes(device...)
+
+	for _, item := range connectivityStatuses {
+		var value int64
+		if item == status {
+			value = 1
+		}
+
+		s.connectivityMetric.Record(ctx, value, metric.WithAttributes(append(device, attribute.String("status", string(item)))...))
+	}
+
+	if previous.Status == status {
+		return previous
+	}
+
+	current := Connectivity{
+		Status: status,
+		Since:  now,
+	}
+
+	switch {
+	case len(previous.Status) == 0:
+	case !previous.Reachable() && current.Reachable():
+		duration := now.Sub(previous.Since)
+
+		s.unreachableMetric.Record(ctx, duration.Seconds(), attributes)
+		slog.LogAttrs(ctx, slog.LevelInfo, "Device reachable again", slog.String("kind", kind), slog.String("name", name), slog.Duration("unreachable", duration))
+
+		return current
+	case !previous.Reachable():
+		// Still unreachable, keep the start of the outage
+		current.Since = previous.Since
+	}
+
+	if !current.Reachable() {
+		slog.LogAttrs(ctx, slog.LevelWarn, "Device unreachable", slog.String("kind", kind), slog.String("name", name), slog.String("status", string(status)))
+	}
+
+	return current
+}
@@ -0,0 +1,90 @@
+package v2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestChangeConnectivity(t *testing.T) {
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	outage := now.Add(-time.Hour)
+
+	cases := map[string]struct {
+		previous        Connectivity
+		status          ConnectivityStatus
+		want            Connectivity
+		wantUnreachable float64
+	}{
+		"first status": {
+			status: Disconnected,
+			want:   Connectivity{Status: Disconnected, Since: now},
+		},
+		"unchanged": {
+			previous: Connectivity{Status: Connected, Since: outage},
+			status:   Connected,
+			want:     Connectivity{Status: Connected, Since: outage},
+		},
+		"unreachable": {
+			previous: Connectivity{Status: Connected, Since: outage},
+			status:   ConnectivityIssue,
+			want:     Connectivity{Status: ConnectivityIssue, Since: now},
+		},
+		"still unreachable": {
+			previous: Connectivity{Status: Disconnected, Since: outage},
+			status:   UnidirectionalIncoming,
+			want:     Connectivity{Status: UnidirectionalIncoming, Since: outage},
+		},
+		"reachable again": {
+			previous:        Connectivity{Status: Disconnected, Since: outage},
+			status:          Connected,
+			want:            Connectivity{Status: Connected, Since: now},
+			wantUnreachable: time.Hour.Seconds(),
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			reader := sdkmetric.NewManualReader()
+
+			var service Service
+			if err := service.createMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := service.changeConnectivity(context.Background(), "light", "1", "Ceiling", tc.previous, tc.status, now); got != tc.want {
+				t.Errorf("changeConnectivity() = %+v, want %+v", got, tc.want)
+			}
+
+			if got := unreachableSum(t, reader); got != tc.wantUnreachable {
+				t.Errorf("unreachable = %f, want %f", got, tc.wantUnreachable)
+			}
+		})
+	}
+}
+
+func unreachableSum(t *testing.T, reader sdkmetric.Reader) float64 {
+	t.Helper()
+
+	var data metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	var output float64
+
+	for _, scope := range data.ScopeMetrics {
+		for _, item := range scope.Metrics {
+			if histogram, ok := item.Data.(metricdata.Histogram[float64]); ok && item.Name == "hue.unreachable" {
+				for _, point := range histogram.DataPoints {
+					output += point.Sum
+				}
+			}
+		}
+	}
+
+	return output
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"runtime"
 	"strings"
+	"time"
 )
 
 type Device struct {
@@ -19,10 +20,12 @@ type Device struct {
 		Archetype string `json:"archetype"`
 		Name      string `json:"name"`
 	} `json:"metadata"`
-	ID       string            `json:"id"`
-	IDV1     string            `json:"id_v1"`
-	Type     string            `json:"type"`
-	Services []deviceReference `json:"services"`
+	SoftwareUpdatedAt time.Time           `json:"-"`
+	ID                string              `json:"id"`
+	IDV1              string              `json:"id_v1"`
+	Type              string              `json:"type"`
+	SoftwareUpdate    SoftwareUpdateState `json:"-"`
+	Services          []deviceReference   `json:"services"`
 }
 
 type deviceReference struct {
@@ -60,7 +63,7 @@ func (s *Service) streamDevices(ctx context.Context, handlers ...func(Device)) e
 
 	devices := make(chan Device, runtime.NumCPU())
 	go func() {
-		err = stream(ctx, s.req, "device", devices)
+		err = stream(ctx, s, "device", devices)
 	}()
 
 	for device := range devices {
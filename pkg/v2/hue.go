@@ -11,21 +11,55 @@ import (
 
 	"github.com/ViBiOh/flags"
 	"github.com/ViBiOh/httputils/v4/pkg/request"
+	"github.com/ViBiOh/httputils/v4/pkg/telemetry"
+	"github.com/ViBiOh/hue/pkg/activity"
+	"github.com/ViBiOh/hue/pkg/history"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Service struct {
 	lights        map[string]*Light
 	groups        map[string]Group
+	rooms         map[string]string
 	motionSensors map[string]MotionSensor
 	taps          map[string]Tap
+	devices       map[string]Device
+	buttons       map[string]Button
+	scenes        map[string]Scene
+	smartScenes   map[string]SmartScene
 
 	temperatureMetric metric.Float64Gauge
 	batteryMetric     metric.Int64Gauge
 	motionMetric      metric.Int64Gauge
 	lightLevelMetric  metric.Int64Gauge
+	luxMetric         metric.Float64Gauge
 
-	config homeConfig
+	connectivityMetric metric.Int64Gauge
+	unreachableMetric  metric.Float64Histogram
+
+	pendingUpdatesMetric metric.Int64Gauge
+
+	lightOnMetric         metric.Int64Gauge
+	lightBrightnessMetric metric.Float64Gauge
+	groupOnMetric         metric.Int64Gauge
+	groupBrightnessMetric metric.Float64Gauge
+
+	eventMetric           metric.Int64Counter
+	buttonMetric          metric.Int64Counter
+	streamMetric          metric.Int64Gauge
+	requestDurationMetric metric.Float64Histogram
+	requestErrorMetric    metric.Int64Counter
+
+	config   homeConfig
+	history  *history.Service
+	activity *activity.Service
+
+	tracer trace.Tracer
+
+	subscriptions       map[*Subscription]struct{}
+	subscriptionsMutex  sync.Mutex
+	subscriptionsClosed bool
 
 	req   request.Request
 	mutex sync.RWMutex
@@ -38,7 +72,8 @@ type Config struct {
 }
 
 type homeConfig struct {
-	Temperatures map[string]string
+	Rooms              map[string]RoomProfile `json:"rooms"`
+	TemperatureOffsets map[string]float64     `json:"temperature_offsets"`
 }
 
 var errNoConfig = errors.New("no v2 config")
@@ -53,9 +88,15 @@ func Flags(fs *flag.FlagSet, prefix string) *Config {
 	return &config
 }
 
-func New(config *Config, meterProvider metric.MeterProvider) (*Service, error) {
+func New(config *Config, meterProvider metric.MeterProvider, tracerProvider trace.TracerProvider, historyService *history.Service, activityService *activity.Service) (*Service, error) {
 	service := &Service{
-		req: request.Get(fmt.Sprintf("https://%s", config.bridgeIP)).Header("hue-application-key", config.bridgeUsername).WithClient(createInsecureClient(10 * time.Second)),
+		history:  historyService,
+		activity: activityService,
+		req:      request.Get(fmt.Sprintf("https://%s", config.bridgeIP)).Header("hue-application-key", config.bridgeUsername).WithClient(telemetry.AddOpenTelemetryToClient(createInsecureClient(10*time.Second), meterProvider, tracerProvider)),
+	}
+
+	if tracerProvider != nil {
+		service.tracer = tracerProvider.Tracer("v2")
 	}
 
 	var err error
@@ -82,7 +123,13 @@ func loadConfig(filename string) (homeConfig, error) {
 		return homeConfig{}, fmt.Errorf("open: %w", err)
 	}
 
+	defer func() { _ = configFile.Close() }()
+
 	var config homeConfig
 
-	return config, json.NewDecoder(configFile).Decode(&config)
+	if err = json.NewDecoder(configFile).Decode(&config); err != nil {
+		return config, fmt.Errorf("decode: %w", err)
+	}
+
+	return config, nil
 }
@@ -3,9 +3,8 @@ package v2
 import (
 	"context"
 	"fmt"
-	"log/slog"
 	"math"
-	"net/http"
+	"sort"
 	"strings"
 )
 
@@ -16,10 +15,17 @@ type Light struct {
 		Archetype string `json:"archetype"`
 		Name      string `json:"name"`
 	} `json:"metadata"`
+	Owner            deviceReference  `json:"owner"`
 	On               On               `json:"on"`
 	Dimming          Dimming          `json:"dimming"`
 	Color            Color            `json:"color"`
 	ColorTemperature ColorTemperature `json:"color_temperature"`
+	Connectivity     Connectivity     `json:"connectivity"`
+}
+
+// Hex returns the color of the light as #rrggbb, at full brightness
+func (l Light) Hex() string {
+	return xyToHex(l.Color.XY.X, l.Color.XY.Y)
 }
 
 type Dimming struct {
@@ -30,6 +36,14 @@ type On struct {
 	On bool `json:"on"`
 }
 
+type LightByName []Light
+
+func (a LightByName) Len() int      { return len(a) }
+func (a LightByName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a LightByName) Less(i, j int) bool {
+	return a[i].Metadata.Name < a[j].Metadata.Name
+}
+
 var temperatures = map[string]int{
 	"warm":    int(math.Round(1000000 / 2700)),
 	"soft":    int(math.Round(1000000 / 3000)),
@@ -37,10 +51,44 @@ var temperatures = map[string]int{
 	"cool":    int(math.Round(1000000 / 5000)),
 }
 
-var defaultTemperature = temperatures["warm"]
+const (
+	// MinMirek is the coldest color temperature supported by the bridge
+	MinMirek = 153
+	// MaxMirek is the warmest color temperature supported by the bridge
+	MaxMirek = 500
+	// DefaultMirek is the color temperature of groups when none is requested
+	DefaultMirek = 239
+)
+
+func (s *Service) Lights() []Light {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	output := make([]Light, 0, len(s.lights))
+
+	for _, item := range s.lights {
+		output = append(output, *item)
+	}
+
+	sort.Sort(LightByName(output))
+
+	return output
+}
+
+func (s *Service) Light(id string) (Light, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	light, ok := s.lights[id]
+	if !ok {
+		return Light{}, false
+	}
+
+	return *light, true
+}
 
 func (s *Service) buildLights(ctx context.Context) (map[string]*Light, error) {
-	lights, err := list[Light](ctx, s.req, "light")
+	lights, err := list[Light](ctx, s, "light")
 	if err != nil {
 		return nil, fmt.Errorf("list lights: %w", err)
 	}
@@ -55,18 +103,13 @@ func (s *Service) buildLights(ctx context.Context) (map[string]*Light, error) {
 	return output, err
 }
 
-func (s *Service) setWhiteLight(ctx context.Context, id, room string) error {
+func (s *Service) setWhiteLight(ctx context.Context, id string, mirek int) error {
 	var color Color
 	color.XY.X = 0.372
 	color.XY.Y = 0.377
 
 	colorTemperature := ColorTemperature{
-		Mirek: temperatures[s.config.Temperatures[room]],
-	}
-
-	if colorTemperature.Mirek == 0 {
-		slog.LogAttrs(ctx, slog.LevelWarn, "Using default color temperature", slog.String("id", id), slog.String("room", room))
-		colorTemperature.Mirek = defaultTemperature
+		Mirek: mirek,
 	}
 
 	payload := map[string]any{
@@ -74,7 +117,7 @@ func (s *Service) setWhiteLight(ctx context.Context, id, room string) error {
 		"color_temperature": colorTemperature,
 	}
 
-	if _, err := s.req.Method(http.MethodPut).Path("/clip/v2/resource/light/"+id).JSON(ctx, payload); err != nil {
+	if err := s.update(ctx, "light", id, payload); err != nil {
 		return fmt.Errorf("update light `%s`: %w", id, err)
 	}
 
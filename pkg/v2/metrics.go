@@ -1,8 +1,11 @@
 package v2
 
 import (
+	"context"
 	"fmt"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 )
 
@@ -37,5 +40,176 @@ func (s *Service) createMetrics(meterProvider metric.MeterProvider) error {
 		return fmt.Errorf("create light level metric: %w", err)
 	}
 
+	s.luxMetric, err = meter.Float64Gauge("hue.lux", metric.WithUnit("lx"))
+	if err != nil {
+		return fmt.Errorf("create lux metric: %w", err)
+	}
+
+	s.connectivityMetric, err = meter.Int64Gauge("hue.connectivity")
+	if err != nil {
+		return fmt.Errorf("create connectivity metric: %w", err)
+	}
+
+	s.unreachableMetric, err = meter.Float64Histogram("hue.unreachable", metric.WithUnit("s"))
+	if err != nil {
+		return fmt.Errorf("create unreachable metric: %w", err)
+	}
+
+	s.pendingUpdatesMetric, err = meter.Int64Gauge("hue.software_update.pending")
+	if err != nil {
+		return fmt.Errorf("create pending updates metric: %w", err)
+	}
+
+	s.lightOnMetric, err = meter.Int64Gauge("hue.light.on")
+	if err != nil {
+		return fmt.Errorf("create light on metric: %w", err)
+	}
+
+	s.lightBrightnessMetric, err = meter.Float64Gauge("hue.light.brightness", metric.WithUnit("%"))
+	if err != nil {
+		return fmt.Errorf("create light brightness metric: %w", err)
+	}
+
+	s.groupOnMetric, err = meter.Int64Gauge("hue.group.on")
+	if err != nil {
+		return fmt.Errorf("create group on metric: %w", err)
+	}
+
+	s.groupBrightnessMetric, err = meter.Float64Gauge("hue.group.brightness", metric.WithUnit("%"))
+	if err != nil {
+		return fmt.Errorf("create group brightness metric: %w", err)
+	}
+
+	s.eventMetric, err = meter.Int64Counter("hue.events")
+	if err != nil {
+		return fmt.Errorf("create event metric: %w", err)
+	}
+
+	s.buttonMetric, err = meter.Int64Counter("hue.button")
+	if err != nil {
+		return fmt.Errorf("create button metric: %w", err)
+	}
+
+	s.streamMetric, err = meter.Int64Gauge("hue.stream.connected")
+	if err != nil {
+		return fmt.Errorf("create stream metric: %w", err)
+	}
+
+	s.requestDurationMetric, err = meter.Float64Histogram("hue.bridge.duration", metric.WithUnit("s"))
+	if err != nil {
+		return fmt.Errorf("create request duration metric: %w", err)
+	}
+
+	s.requestErrorMetric, err = meter.Int64Counter("hue.bridge.errors")
+	if err != nil {
+		return fmt.Errorf("create request error metric: %w", err)
+	}
+
 	return nil
 }
+
+func (s *Service) recordLight(ctx context.Context, light *Light) {
+	attributes := metric.WithAttributes(s.deviceAttributes("light", light.Owner.Rid, light.Metadata.Name)...)
+
+	s.lightOnMetric.Record(ctx, boolToInt(light.On.On), attributes)
+	s.lightBrightnessMetric.Record(ctx, light.Dimming.Brightness, attributes)
+}
+
+func (s *Service) recordGroup(ctx context.Context, group Group, groupedLight GroupedLight) {
+	attributes := metric.WithAttributes(
+		attribute.String("room", group.Name),
+		attribute.String("kind", group.Kind),
+	)
+
+	s.groupOnMetric.Record(ctx, boolToInt(groupedLight.On.On), attributes)
+	s.groupBrightnessMetric.Record(ctx, groupedLight.Dimming.Brightness, attributes)
+}
+
+func (s *Service) recordLightsAndGroups(ctx context.Context) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, light := range s.lights {
+		s.recordLight(ctx, light)
+	}
+
+	for _, group := range s.groups {
+		for _, groupedLight := range group.GroupedLights {
+			s.recordGroup(ctx, group, groupedLight)
+		}
+	}
+}
+
+// recordSensors records the current values of the motion sensors and the taps, for exposing them before their first event
+func (s *Service) recordSensors(ctx context.Context) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, motionSensor := range s.motionSensors {
+		attributes := metric.WithAttributes(s.deviceAttributes("motion", motionSensor.ID, motionSensor.Name)...)
+
+		var motion int64
+		if motionSensor.Motion {
+			motion = motionValue
+		}
+
+		s.motionMetric.Record(ctx, motion, attributes)
+		s.temperatureMetric.Record(ctx, motionSensor.Temperature, attributes)
+		s.lightLevelMetric.Record(ctx, motionSensor.LightLevelValue, attributes)
+		s.luxMetric.Record(ctx, motionSensor.Lux(), attributes)
+		s.batteryMetric.Record(ctx, motionSensor.BatteryLevel, attributes)
+	}
+
+	for _, tap := range s.taps {
+		s.batteryMetric.Record(ctx, tap.BatteryLevel, metric.WithAttributes(s.deviceAttributes("tap", tap.ID, tap.Name)...))
+	}
+}
+
+// deviceAttributes identifies a device in the metrics by its room, its name and its kind
+func (s *Service) deviceAttributes(kind, id, name string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("room", s.rooms[id]),
+		attribute.String("device", name),
+		attribute.String("kind", kind),
+	}
+}
+
+// deviceRooms returns the name of the room of each device, by device ID
+func deviceRooms(groups map[string]Group) map[string]string {
+	output := make(map[string]string)
+
+	for _, group := range groups {
+		if group.Kind != roomKind {
+			continue
+		}
+
+		for _, device := range group.devices {
+			output[device] = group.Name
+		}
+	}
+
+	return output
+}
+
+func boolToInt(value bool) int64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
+// RecordRequest times a call to the bridge and counts it as failed on error, by version of the API, resource and method
+func (s *Service) RecordRequest(ctx context.Context, version, resource, method string, duration time.Duration, err error) {
+	attributes := metric.WithAttributes(
+		attribute.String("api", version),
+		attribute.String("resource", resource),
+		attribute.String("method", method),
+	)
+
+	s.requestDurationMetric.Record(ctx, duration.Seconds(), attributes)
+
+	if err != nil {
+		s.requestErrorMetric.Add(ctx, 1, attributes)
+	}
+}
@@ -0,0 +1,67 @@
+package v2
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+func TestRecordLight(t *testing.T) {
+	cases := map[string]struct {
+		groups map[string]Group
+		want   string
+	}{
+		"in a room": {
+			groups: map[string]Group{
+				"1": {Name: "Office", Kind: roomKind, devices: []string{"device-1"}},
+			},
+			want: `hue_light_on{device="Desk",kind="light",room="Office"} 1`,
+		},
+		"in a zone only": {
+			groups: map[string]Group{
+				"1": {Name: "Downstairs", Kind: zoneKind, devices: []string{"device-1"}},
+			},
+			want: `hue_light_on{device="Desk",kind="light",room=""} 1`,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+
+			exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry), otelprometheus.WithoutScopeInfo())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			service := Service{rooms: deviceRooms(tc.groups)}
+			if err := service.createMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(exporter))); err != nil {
+				t.Fatal(err)
+			}
+
+			light := &Light{ID: "light-1", Owner: deviceReference{Rid: "device-1"}, On: On{On: true}}
+			light.Metadata.Name = "Desk"
+
+			service.recordLight(context.Background(), light)
+
+			writer := httptest.NewRecorder()
+			promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(writer, httptest.NewRequest("GET", "/metrics", nil))
+
+			content, err := io.ReadAll(writer.Result().Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := string(content); !strings.Contains(got, tc.want) {
+				t.Errorf("/metrics = `%s`, want `%s`", got, tc.want)
+			}
+		})
+	}
+}
@@ -3,12 +3,18 @@ package v2
 import (
 	"context"
 	"fmt"
-	"net/http"
+	"maps"
+	"math"
 	"sort"
 	"strings"
 
 	"github.com/ViBiOh/httputils/v4/pkg/breaksync"
 	"github.com/ViBiOh/httputils/v4/pkg/concurrent"
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"github.com/ViBiOh/httputils/v4/pkg/telemetry"
+	"github.com/ViBiOh/hue/pkg/activity"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type MotionSensor struct {
@@ -22,12 +28,37 @@ type MotionSensor struct {
 	LightLevelIDV1  string `json:"light_level_id_v1"`
 	LightLevelValue int64  `json:"light_level"`
 
+	Connectivity Connectivity `json:"connectivity"`
+
 	Temperature  float64 `json:"temperature"`
 	BatteryLevel int64   `json:"battery_level"`
 	Enabled      bool    `json:"enabled"`
 	Motion       bool    `json:"motion"`
 }
 
+// Lux returns the light level measured by the sensor in lux
+func (ms MotionSensor) Lux() float64 {
+	return Lux(ms.LightLevelValue)
+}
+
+// Lux converts a CLIP light level, 10000*log10(lux)+1, to lux
+func Lux(lightLevel int64) float64 {
+	if lightLevel <= 0 {
+		return 0
+	}
+
+	return math.Pow(10, float64(lightLevel-1)/10000)
+}
+
+// LuxToLightLevel converts lux to a CLIP light level, 10000*log10(lux)+1
+func LuxToLightLevel(lux float64) int64 {
+	if lux <= 0 {
+		return 0
+	}
+
+	return max(int64(math.Round(10000*math.Log10(lux)))+1, 0)
+}
+
 type MotionSensors []MotionSensor
 
 func (ms MotionSensors) HasEnabled() bool {
@@ -131,7 +162,19 @@ func (s *Service) Sensors() MotionSensors {
 	return output
 }
 
-func (s *Service) UpdateSensor(ctx context.Context, id string, enabled bool) (MotionSensor, error) {
+func (s *Service) Sensor(id string) (MotionSensor, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	motionSensor, ok := s.motionSensors[id]
+
+	return motionSensor, ok
+}
+
+func (s *Service) UpdateSensor(ctx context.Context, id string, enabled bool) (motionSensor MotionSensor, err error) {
+	ctx, end := telemetry.StartSpan(ctx, s.tracer, "update_sensor", trace.WithAttributes(attribute.String("id", id), attribute.Bool("enabled", enabled)))
+	defer end(&err)
+
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
@@ -141,13 +184,24 @@ func (s *Service) UpdateSensor(ctx context.Context, id string, enabled bool) (Mo
 
 	motionSensor, ok := s.motionSensors[id]
 	if !ok {
-		return motionSensor, fmt.Errorf("unknown motion sensor with id `%s`", id)
+		return motionSensor, model.WrapNotFound(fmt.Errorf("unknown motion sensor with id `%s`", id))
 	}
 
-	_, err := s.req.Method(http.MethodPut).Path("/clip/v2/resource/motion/"+motionSensor.MotionID).JSON(ctx, payload)
+	err = s.update(ctx, "motion", motionSensor.MotionID, payload)
+	s.activity.Record(ctx, activity.Sensor, motionSensor.ID, motionSensor.Name, sensorActivityState(enabled), err)
+
 	return motionSensor, err
 }
 
+// sensorActivityState describes the state of a sensor in the activity log
+func sensorActivityState(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+
+	return "disabled"
+}
+
 func (s *Service) buildMotionSensor(ctx context.Context, devices []Device, devicePowers []DevicePower) (map[string]MotionSensor, error) {
 	var motions []Motion
 	var lightLevels []LightLevel
@@ -156,7 +210,7 @@ func (s *Service) buildMotionSensor(ctx context.Context, devices []Device, devic
 	wg := concurrent.NewFailFast(2)
 
 	wg.Go(func() (err error) {
-		motions, err = list[Motion](ctx, s.req, "motion")
+		motions, err = list[Motion](ctx, s, "motion")
 		if err != nil {
 			return fmt.Errorf("list motions: %w", err)
 		}
@@ -167,7 +221,7 @@ func (s *Service) buildMotionSensor(ctx context.Context, devices []Device, devic
 	})
 
 	wg.Go(func() (err error) {
-		lightLevels, err = list[LightLevel](ctx, s.req, "light_level")
+		lightLevels, err = list[LightLevel](ctx, s, "light_level")
 		if err != nil {
 			return fmt.Errorf("list light levels: %w", err)
 		}
@@ -178,7 +232,7 @@ func (s *Service) buildMotionSensor(ctx context.Context, devices []Device, devic
 	})
 
 	wg.Go(func() (err error) {
-		temperatures, err = list[Temperature](ctx, s.req, "temperature")
+		temperatures, err = list[Temperature](ctx, s, "temperature")
 		if err != nil {
 			return fmt.Errorf("list temperatures: %w", err)
 		}
@@ -243,7 +297,7 @@ func (s *Service) buildMotionSensor(ctx context.Context, devices []Device, devic
 			}
 
 			if syncFlags&1<<3 == 0 {
-				sensor.Temperature = values[3].(Temperature).Temperature.Temperature
+				sensor.Temperature = s.calibrateTemperature(sensor.Name, values[3].(Temperature).Temperature.Temperature)
 			}
 
 			if syncFlags&1<<4 == 0 {
@@ -257,3 +311,12 @@ func (s *Service) buildMotionSensor(ctx context.Context, devices []Device, devic
 			return nil
 		})
 }
+
+func (s *Service) calibrateTemperature(name string, temperature float64) float64 {
+	return temperature + s.config.TemperatureOffsets[name]
+}
+
+// TemperatureOffsets returns the calibration of the configuration, by sensor name
+func (s *Service) TemperatureOffsets() map[string]float64 {
+	return maps.Clone(s.config.TemperatureOffsets)
+}
@@ -0,0 +1,74 @@
+package v2
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLux(t *testing.T) {
+	cases := map[string]struct {
+		lightLevel int64
+		want       float64
+	}{
+		"darkness": {
+			lightLevel: 0,
+			want:       0,
+		},
+		"one lux": {
+			lightLevel: 1,
+			want:       1,
+		},
+		"ten lux": {
+			lightLevel: 10001,
+			want:       10,
+		},
+		"bright room": {
+			lightLevel: 30001,
+			want:       1000,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := Lux(tc.lightLevel); math.Abs(got-tc.want) > 0.001 {
+				t.Errorf("Lux() = %f, want %f", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLuxToLightLevel(t *testing.T) {
+	cases := map[string]struct {
+		lux  float64
+		want int64
+	}{
+		"darkness": {
+			lux:  0,
+			want: 0,
+		},
+		"below one lux": {
+			lux:  0.1,
+			want: 0,
+		},
+		"one lux": {
+			lux:  1,
+			want: 1,
+		},
+		"ten lux": {
+			lux:  10,
+			want: 10001,
+		},
+		"default threshold": {
+			lux:  Lux(6000),
+			want: 6000,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := LuxToLightLevel(tc.lux); got != tc.want {
+				t.Errorf("LuxToLightLevel() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
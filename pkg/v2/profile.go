@@ -0,0 +1,81 @@
+package v2
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// RoomProfile is how the app lights a room: its color temperature, the brightness of its states and the states it allows
+type RoomProfile struct {
+	Brightness  map[string]uint64 `json:"brightness"`
+	Temperature string            `json:"temperature"`
+	Presets     []string          `json:"presets"`
+	Hidden      bool              `json:"hidden"`
+}
+
+// ProfileError is an invalid value of a room profile, at its path in the profile like `brightness.on`
+type ProfileError struct {
+	Err  error
+	Path string
+}
+
+// Validate returns every invalid value of the profile
+func (rp RoomProfile) Validate() []ProfileError {
+	var output []ProfileError
+
+	if len(rp.Temperature) != 0 {
+		if _, ok := temperatures[rp.Temperature]; !ok {
+			output = append(output, ProfileError{Path: "temperature", Err: fmt.Errorf("unknown temperature `%s`", rp.Temperature)})
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(rp.Brightness)) {
+		if rp.Brightness[name] > 100 {
+			output = append(output, ProfileError{Path: "brightness." + name, Err: fmt.Errorf("brightness of `%s` must be between 0 and 100", name)})
+		}
+	}
+
+	return output
+}
+
+// Mirek returns the color temperature of the profile, 0 if it has none
+func (rp RoomProfile) Mirek() int {
+	return temperatures[rp.Temperature]
+}
+
+// Allows tells if the state can be applied to the room, every state being allowed if the profile doesn't restrict them
+func (rp RoomProfile) Allows(state string) bool {
+	if len(rp.Presets) == 0 {
+		return true
+	}
+
+	for _, preset := range rp.Presets {
+		if strings.EqualFold(preset, state) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Profile returns the profile of the room, matching its name case-insensitively
+func (s *Service) Profile(room string) (RoomProfile, bool) {
+	if profile, ok := s.config.Rooms[room]; ok {
+		return profile, true
+	}
+
+	for name, profile := range s.config.Rooms {
+		if strings.EqualFold(name, room) {
+			return profile, true
+		}
+	}
+
+	return RoomProfile{}, false
+}
+
+// Profiles returns the profiles of the configuration, by room name as written in it
+func (s *Service) Profiles() map[string]RoomProfile {
+	return maps.Clone(s.config.Rooms)
+}
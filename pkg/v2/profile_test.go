@@ -0,0 +1,76 @@
+package v2
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRoomProfileValidate(t *testing.T) {
+	cases := map[string]struct {
+		instance RoomProfile
+		want     []string
+	}{
+		"empty": {
+			instance: RoomProfile{},
+		},
+		"valid": {
+			instance: RoomProfile{Temperature: "soft", Brightness: map[string]uint64{"on": 80}, Presets: []string{"on", "off"}},
+		},
+		"unknown temperature": {
+			instance: RoomProfile{Temperature: "hot"},
+			want:     []string{"temperature"},
+		},
+		"brightness": {
+			instance: RoomProfile{Brightness: map[string]uint64{"on": 120}},
+			want:     []string{"brightness.on"},
+		},
+		"every value": {
+			instance: RoomProfile{Temperature: "hot", Brightness: map[string]uint64{"on": 120, "half": 50, "dimmed": 101}},
+			want:     []string{"temperature", "brightness.dimmed", "brightness.on"},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var got []string
+			for _, err := range tc.instance.Validate() {
+				got = append(got, err.Path)
+			}
+
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("Validate() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRoomProfileAllows(t *testing.T) {
+	cases := map[string]struct {
+		instance RoomProfile
+		state    string
+		want     bool
+	}{
+		"unrestricted": {
+			instance: RoomProfile{},
+			state:    "half",
+			want:     true,
+		},
+		"allowed": {
+			instance: RoomProfile{Presets: []string{"on", "Off"}},
+			state:    "off",
+			want:     true,
+		},
+		"not allowed": {
+			instance: RoomProfile{Presets: []string{"on", "off"}},
+			state:    "half",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := tc.instance.Allows(tc.state); got != tc.want {
+				t.Errorf("Allows() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -4,10 +4,22 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"github.com/ViBiOh/httputils/v4/pkg/telemetry"
+	"github.com/ViBiOh/hue/pkg/activity"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	roomKind   = "room"
+	zoneKind   = "zone"
+	bridgeKind = "bridge_home"
 )
 
 type Group struct {
@@ -15,7 +27,9 @@ type Group struct {
 	ID            string
 	IDV1          string
 	Name          string
+	Kind          string
 	Lights        []*Light
+	devices       []string
 	Bridge        bool
 	Plug          bool
 }
@@ -30,6 +44,39 @@ func (g Group) AnyOn() bool {
 	return false
 }
 
+// Room returns true if the group is a room, a light belonging to a single one
+func (g Group) Room() bool {
+	return g.Kind == roomKind
+}
+
+// Reachable returns true if at least one light of the group is reachable
+func (g Group) Reachable() bool {
+	if len(g.Lights) == 0 {
+		return true
+	}
+
+	for _, light := range g.Lights {
+		if light.Connectivity.Reachable() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Unreachable returns the name of the unreachable lights of the group
+func (g Group) Unreachable() []string {
+	var output []string
+
+	for _, light := range g.Lights {
+		if !light.Connectivity.Reachable() {
+			output = append(output, light.Metadata.Name)
+		}
+	}
+
+	return output
+}
+
 func isPlug(lights []*Light) bool {
 	var count int
 
@@ -94,33 +141,102 @@ func (s *Service) Groups() []Group {
 	return output
 }
 
-func (s *Service) UpdateGroup(ctx context.Context, id string, on bool, brightness float64, transitionTime time.Duration) (Group, error) {
+func (s *Service) Group(id string) (Group, bool) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	var colorTemperature ColorTemperature
-	colorTemperature.Mirek = 239
+	group, ok := s.groups[id]
+
+	return group, ok
+}
+
+// GroupUpdate is the state applied to every light of a group
+type GroupUpdate struct {
+	Color      *Color
+	Transition time.Duration
+	Brightness float64
+	Mirek      int
+	On         bool
+}
 
+// payload builds the body of the update, the default color temperature being used if neither color nor mirek is set
+func (gu GroupUpdate) payload() map[string]any {
 	payload := map[string]any{
 		"on": On{
-			On: on,
+			On: gu.On,
 		},
 		"dimming": Dimming{
-			Brightness: brightness,
+			Brightness: gu.Brightness,
 		},
-		"color_temperature": colorTemperature,
 		"dynamics": map[string]any{
-			"duration": transitionTime.Milliseconds(),
+			"duration": gu.Transition.Milliseconds(),
 		},
 	}
 
+	if gu.Color != nil {
+		payload["color"] = *gu.Color
+		return payload
+	}
+
+	colorTemperature := ColorTemperature{Mirek: DefaultMirek}
+	if gu.Mirek != 0 {
+		colorTemperature.Mirek = gu.Mirek
+	}
+
+	payload["color_temperature"] = colorTemperature
+
+	return payload
+}
+
+// String describes the state in the activity log
+func (gu GroupUpdate) String() string {
+	if !gu.On {
+		return "off"
+	}
+
+	state := fmt.Sprintf("on at %s%%", strconv.FormatFloat(gu.Brightness, 'f', -1, 64))
+
+	if gu.Color != nil {
+		state += fmt.Sprintf(" and color %s,%s", strconv.FormatFloat(gu.Color.XY.X, 'f', -1, 64), strconv.FormatFloat(gu.Color.XY.Y, 'f', -1, 64))
+	} else if gu.Mirek != 0 {
+		state += fmt.Sprintf(" and %d mirek", gu.Mirek)
+	}
+
+	if gu.Transition > 0 {
+		state += fmt.Sprintf(" in %s", gu.Transition)
+	}
+
+	return state
+}
+
+func (s *Service) UpdateGroup(ctx context.Context, id string, state GroupUpdate) (group Group, err error) {
+	ctx, end := telemetry.StartSpan(ctx, s.tracer, "update_group", trace.WithAttributes(attribute.String("id", id), attribute.Bool("on", state.On), attribute.Float64("brightness", state.Brightness), attribute.Int("mirek", state.Mirek)))
+	defer end(&err)
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	group, ok := s.groups[id]
 	if !ok {
-		return group, fmt.Errorf("unknown group with id `%s`", id)
+		return group, model.WrapNotFound(fmt.Errorf("unknown group with id `%s`", id))
 	}
 
+	if profile, ok := s.Profile(group.Name); ok && state.Color == nil && state.Mirek == 0 {
+		state.Mirek = profile.Mirek()
+	}
+
+	payload := state.payload()
+
+	for _, other := range s.overlappingGroups(group) {
+		s.activity.Expect(activity.Group, other)
+	}
+
+	defer func() {
+		s.activity.Record(ctx, activity.Group, group.ID, group.Name, state.String(), err)
+	}()
+
 	for _, groupedLight := range group.GroupedLights {
-		if _, err := s.req.Method(http.MethodPut).Path("/clip/v2/resource/grouped_light/"+groupedLight.ID).JSON(ctx, payload); err != nil {
+		if err = s.update(ctx, "grouped_light", groupedLight.ID, payload); err != nil {
 			return group, fmt.Errorf("update grouped light `%s`: %w", groupedLight.ID, err)
 		}
 	}
@@ -128,20 +244,45 @@ func (s *Service) UpdateGroup(ctx context.Context, id string, on bool, brightnes
 	return group, nil
 }
 
+// overlappingGroups returns the IDs of the other groups sharing lights with the given one, their state changing along. The mutex has to be held
+func (s *Service) overlappingGroups(group Group) []string {
+	lights := make(map[string]struct{}, len(group.Lights))
+	for _, light := range group.Lights {
+		lights[light.ID] = struct{}{}
+	}
+
+	var output []string
+
+	for _, other := range s.groups {
+		if other.ID == group.ID {
+			continue
+		}
+
+		for _, light := range other.Lights {
+			if _, ok := lights[light.ID]; ok {
+				output = append(output, other.ID)
+				break
+			}
+		}
+	}
+
+	return output
+}
+
 func (s *Service) buildGroup(ctx context.Context) (output map[string]Group, err error) {
 	output = make(map[string]Group)
 
-	err = s.buildDeviceGroup(ctx, "room", output)
+	err = s.buildDeviceGroup(ctx, roomKind, output)
 	if err != nil {
 		return output, err
 	}
 
-	err = s.buildDeviceGroup(ctx, "zone", output)
+	err = s.buildDeviceGroup(ctx, zoneKind, output)
 	if err != nil {
 		return output, err
 	}
 
-	err = s.buildDeviceGroup(ctx, "bridge_home", output)
+	err = s.buildDeviceGroup(ctx, bridgeKind, output)
 	if err != nil {
 		return output, err
 	}
@@ -150,12 +291,12 @@ func (s *Service) buildGroup(ctx context.Context) (output map[string]Group, err
 }
 
 func (s *Service) buildDeviceGroup(ctx context.Context, name string, output map[string]Group) error {
-	groupDevices, err := list[Room](ctx, s.req, name)
+	groupDevices, err := list[Room](ctx, s, name)
 	if err != nil {
 		return fmt.Errorf("list rooms: %w", err)
 	}
 
-	isBridge := name == "bridge_home"
+	isBridge := name == bridgeKind
 
 	for _, item := range groupDevices {
 		groupedLights, err := s.buildServices(ctx, name, item.Services)
@@ -173,9 +314,9 @@ func (s *Service) buildDeviceGroup(ctx context.Context, name string, output map[
 			groupName = "Bridge"
 		}
 
-		if len(s.config.Temperatures) != 0 {
+		if profile, ok := s.Profile(groupName); ok && profile.Mirek() != 0 {
 			for _, light := range lights {
-				if err := s.setWhiteLight(ctx, light.ID, groupName); err != nil {
+				if err := s.setWhiteLight(ctx, light.ID, profile.Mirek()); err != nil {
 					slog.LogAttrs(ctx, slog.LevelError, "white light", slog.Any("error", err))
 				}
 			}
@@ -185,8 +326,10 @@ func (s *Service) buildDeviceGroup(ctx context.Context, name string, output map[
 			ID:            item.ID,
 			IDV1:          strings.TrimPrefix(item.IDV1, "/groups/"),
 			Name:          groupName,
+			Kind:          name,
 			GroupedLights: groupedLights,
 			Lights:        lights,
+			devices:       childDevices(item.Children),
 			Plug:          isPlug(lights),
 			Bridge:        isBridge,
 		}
@@ -203,7 +346,7 @@ func (s *Service) buildServices(ctx context.Context, name string, services []dev
 		case "grouped_light_level":
 		case "grouped_motion":
 		case "grouped_light":
-			groupedLight, err := get[GroupedLight](ctx, s.req, service.Rtype, service.Rid)
+			groupedLight, err := get[GroupedLight](ctx, s, service.Rtype, service.Rid)
 			if err != nil {
 				return nil, fmt.Errorf("get grouped light `%s`: %w", service.Rid, err)
 			}
@@ -230,7 +373,7 @@ func (s *Service) buildChildren(ctx context.Context, children []deviceReference)
 				output = append(output, light)
 			}
 		case "device":
-			device, err := get[Device](ctx, s.req, service.Rtype, service.Rid)
+			device, err := get[Device](ctx, s, service.Rtype, service.Rid)
 			if err != nil {
 				return nil, fmt.Errorf("get device `%s`: %w", service.Rid, err)
 			}
@@ -247,6 +390,18 @@ func (s *Service) buildChildren(ctx context.Context, children []deviceReference)
 	return output, nil
 }
 
+func childDevices(children []deviceReference) []string {
+	var output []string
+
+	for _, child := range children {
+		if child.Rtype == "device" {
+			output = append(output, child.Rid)
+		}
+	}
+
+	return output
+}
+
 func (s *Service) getGroupOfGroupedLight(groupedLightID string) (Group, bool) {
 	for _, group := range s.groups {
 		if _, ok := group.GroupedLights[groupedLightID]; ok {
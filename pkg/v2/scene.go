@@ -0,0 +1,605 @@
+package v2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"github.com/ViBiOh/httputils/v4/pkg/telemetry"
+	"github.com/ViBiOh/hue/pkg/activity"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	maxPaletteColors = 5
+	maxSceneName     = 32
+)
+
+// SceneRecall is the way a scene is recalled
+type SceneRecall string
+
+const (
+	SceneActive         SceneRecall = "active"
+	SceneDynamicPalette SceneRecall = "dynamic_palette"
+	SceneStatic         SceneRecall = "static"
+)
+
+// SceneRecalls are the ways a scene can be recalled
+var SceneRecalls = []SceneRecall{SceneActive, SceneDynamicPalette, SceneStatic}
+
+const sceneInactive = "inactive"
+
+type SceneStatus struct {
+	Active string `json:"active"`
+}
+
+// LightAction is the state of a light in a scene
+type LightAction struct {
+	On               *On               `json:"on,omitempty"`
+	Dimming          *Dimming          `json:"dimming,omitempty"`
+	Color            *Color            `json:"color,omitempty"`
+	ColorTemperature *ColorTemperature `json:"color_temperature,omitempty"`
+}
+
+type SceneAction struct {
+	Target deviceReference `json:"target"`
+	Action LightAction     `json:"action"`
+}
+
+type ScenePalette struct {
+	Color []struct {
+		Color   Color   `json:"color"`
+		Dimming Dimming `json:"dimming"`
+	} `json:"color"`
+	ColorTemperature []struct {
+		ColorTemperature ColorTemperature `json:"color_temperature"`
+		Dimming          Dimming          `json:"dimming"`
+	} `json:"color_temperature"`
+}
+
+type Scene struct {
+	ID       string `json:"id"`
+	IDV1     string `json:"id_v1"`
+	Metadata struct {
+		Name    string `json:"name"`
+		Appdata string `json:"appdata,omitempty"`
+	} `json:"metadata"`
+	Group   deviceReference `json:"group"`
+	Actions []SceneAction   `json:"actions"`
+	Palette ScenePalette    `json:"palette"`
+	Status  SceneStatus     `json:"status"`
+	Speed   float64         `json:"speed"`
+}
+
+// Active tells if the scene is the one currently applied to its group
+func (s Scene) Active() bool {
+	return len(s.Status.Active) != 0 && s.Status.Active != sceneInactive
+}
+
+// Dynamic tells if the scene has a palette to cycle through
+func (s Scene) Dynamic() bool {
+	return len(s.Palette.Color)+len(s.Palette.ColorTemperature) > 1
+}
+
+// Colors returns the colors of the scene as #rrggbb, from its palette or from the state of its lights
+func (s Scene) Colors() []string {
+	var output []string
+
+	add := func(color string) {
+		if len(output) < maxPaletteColors && !slices.Contains(output, color) {
+			output = append(output, color)
+		}
+	}
+
+	for _, item := range s.Palette.Color {
+		add(xyToHex(item.Color.XY.X, item.Color.XY.Y))
+	}
+
+	for _, item := range s.Palette.ColorTemperature {
+		add(mirekToHex(item.ColorTemperature.Mirek))
+	}
+
+	if len(output) != 0 {
+		return output
+	}
+
+	for _, item := range s.Actions {
+		switch {
+		case item.Action.On != nil && !item.Action.On.On:
+		case item.Action.Color != nil:
+			add(xyToHex(item.Action.Color.XY.X, item.Action.Color.XY.Y))
+		case item.Action.ColorTemperature != nil && item.Action.ColorTemperature.Mirek != 0:
+			add(mirekToHex(item.Action.ColorTemperature.Mirek))
+		}
+	}
+
+	return output
+}
+
+type SceneByName []Scene
+
+func (a SceneByName) Len() int      { return len(a) }
+func (a SceneByName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a SceneByName) Less(i, j int) bool {
+	return a[i].Metadata.Name < a[j].Metadata.Name
+}
+
+// Scenes returns the scenes of every room and zone, by group ID and sorted by name
+func (s *Service) Scenes() map[string][]Scene {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	output := make(map[string][]Scene)
+
+	for _, scene := range s.scenes {
+		output[scene.Group.Rid] = append(output[scene.Group.Rid], scene)
+	}
+
+	for _, scenes := range output {
+		sort.Sort(SceneByName(scenes))
+	}
+
+	return output
+}
+
+func (s *Service) Scene(id string) (Scene, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	scene, ok := s.scenes[id]
+
+	return scene, ok
+}
+
+func (s *Service) buildScenes(ctx context.Context) (map[string]Scene, error) {
+	scenes, err := list[Scene](ctx, s, "scene")
+	if err != nil {
+		return nil, fmt.Errorf("list scenes: %w", err)
+	}
+
+	output := make(map[string]Scene, len(scenes))
+	for _, scene := range scenes {
+		scene.IDV1 = strings.TrimPrefix(scene.IDV1, "/scenes/")
+
+		output[scene.ID] = scene
+	}
+
+	return output, nil
+}
+
+// updateScene keeps the scenes up-to-date from the events of the bridge, the scene being fetched again unless only its status changed
+func (s *Service) updateScene(ctx context.Context, eventType, id string, status *SceneStatus) {
+	if eventType == "delete" {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		delete(s.scenes, id)
+
+		return
+	}
+
+	if eventType == "update" && status != nil && s.updateSceneStatus(id, *status) {
+		return
+	}
+
+	scene, err := get[Scene](ctx, s, "scene", id)
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "get scene", slog.String("id", id), slog.Any("error", err))
+		return
+	}
+
+	scene.IDV1 = strings.TrimPrefix(scene.IDV1, "/scenes/")
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.scenes[id] = scene
+}
+
+func (s *Service) updateSceneStatus(id string, status SceneStatus) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	scene, ok := s.scenes[id]
+	if ok {
+		scene.Status = status
+		s.scenes[id] = scene
+	}
+
+	return ok
+}
+
+// RecallScene applies the scene to its group, brightness overriding the one of the scene if not zero
+func (s *Service) RecallScene(ctx context.Context, id string, action SceneRecall, brightness float64) (scene Scene, err error) {
+	ctx, end := telemetry.StartSpan(ctx, s.tracer, "recall_scene", trace.WithAttributes(attribute.String("id", id), attribute.String("action", string(action)), attribute.Float64("brightness", brightness)))
+	defer end(&err)
+
+	if !slices.Contains(SceneRecalls, action) {
+		return scene, model.WrapInvalid(fmt.Errorf("unknown recall `%s`", action))
+	}
+
+	if brightness < 0 || brightness > 100 {
+		return scene, model.WrapInvalid(fmt.Errorf("brightness must be between 0 and 100"))
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	scene, ok := s.scenes[id]
+	if !ok {
+		return scene, model.WrapNotFound(fmt.Errorf("unknown scene with id `%s`", id))
+	}
+
+	recall := map[string]any{
+		"action": action,
+	}
+
+	if brightness > 0 {
+		recall["dimming"] = Dimming{Brightness: brightness}
+	}
+
+	if group, ok := s.groups[scene.Group.Rid]; ok {
+		for _, other := range s.overlappingGroups(group) {
+			s.activity.Expect(activity.Group, other)
+		}
+
+		defer func() {
+			s.activity.Record(ctx, activity.Group, group.ID, group.Name, sceneActivityState(scene, action, brightness), err)
+		}()
+	}
+
+	if err = s.update(ctx, "scene", id, map[string]any{"recall": recall}); err != nil {
+		return scene, fmt.Errorf("recall scene `%s`: %w", id, err)
+	}
+
+	return scene, nil
+}
+
+// Snapshot returns the current state of every light of the group, by light ID, as it would be saved in a scene
+func (s *Service) Snapshot(groupID string) (map[string]LightAction, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	group, ok := s.groups[groupID]
+	if !ok {
+		return nil, model.WrapNotFound(fmt.Errorf("unknown group with id `%s`", groupID))
+	}
+
+	output := make(map[string]LightAction, len(group.Lights))
+	for _, light := range group.Lights {
+		output[light.ID] = lightAction(*light)
+	}
+
+	return output, nil
+}
+
+// lightAction describes the state of the light, its color temperature prevailing over its color as the bridge reports both in white mode
+func lightAction(light Light) LightAction {
+	output := LightAction{
+		On: &On{On: light.On.On},
+	}
+
+	if !light.On.On || light.Metadata.Archetype == "plug" {
+		return output
+	}
+
+	if light.Dimming.Brightness > 0 {
+		output.Dimming = &Dimming{Brightness: light.Dimming.Brightness}
+	}
+
+	if light.ColorTemperature.Mirek != 0 {
+		output.ColorTemperature = &ColorTemperature{Mirek: light.ColorTemperature.Mirek}
+	} else if light.Color.XY.X != 0 || light.Color.XY.Y != 0 {
+		color := light.Color
+		output.Color = &color
+	}
+
+	return output
+}
+
+// metadata describes a scene or a smart scene by name, the appdata tagging it for its owner
+func metadata(name, appdata string) map[string]string {
+	output := map[string]string{
+		"name": name,
+	}
+
+	if len(appdata) != 0 {
+		output["appdata"] = appdata
+	}
+
+	return output
+}
+
+// CreateScene saves the given state of the lights of a group, by light ID, as a new scene of the group, tagged with the appdata if any
+func (s *Service) CreateScene(ctx context.Context, groupID, name, appdata string, lights map[string]LightAction) (scene Scene, err error) {
+	ctx, end := telemetry.StartSpan(ctx, s.tracer, "create_scene", trace.WithAttributes(attribute.String("group", groupID), attribute.String("name", name)))
+	defer end(&err)
+
+	name = strings.TrimSpace(name)
+	if len(name) == 0 || len(name) > maxSceneName {
+		return scene, model.WrapInvalid(fmt.Errorf("name must be between 1 and %d characters", maxSceneName))
+	}
+
+	group, ok := s.Group(groupID)
+	if !ok {
+		return scene, model.WrapNotFound(fmt.Errorf("unknown group with id `%s`", groupID))
+	}
+
+	if group.Bridge {
+		return scene, model.WrapInvalid(errors.New("scenes can't be created for the whole bridge"))
+	}
+
+	actions, err := sceneActions(group, lights)
+	if err != nil {
+		return scene, model.WrapInvalid(err)
+	}
+
+	payload := map[string]any{
+		"type":     "scene",
+		"metadata": metadata(name, appdata),
+		"group": deviceReference{
+			Rid:   group.ID,
+			Rtype: group.Kind,
+		},
+		"actions": actions,
+	}
+
+	id, err := s.create(ctx, "scene", payload)
+	if err != nil {
+		return scene, fmt.Errorf("create scene `%s`: %w", name, err)
+	}
+
+	return s.refreshScene(ctx, id)
+}
+
+// UpdateSceneActions replaces the saved state of the lights of the scene, by light ID, the scene keeping its IDs
+func (s *Service) UpdateSceneActions(ctx context.Context, id string, lights map[string]LightAction) (scene Scene, err error) {
+	ctx, end := telemetry.StartSpan(ctx, s.tracer, "update_scene_actions", trace.WithAttributes(attribute.String("id", id)))
+	defer end(&err)
+
+	scene, ok := s.Scene(id)
+	if !ok {
+		return scene, model.WrapNotFound(fmt.Errorf("unknown scene with id `%s`", id))
+	}
+
+	group, ok := s.Group(scene.Group.Rid)
+	if !ok {
+		return scene, model.WrapNotFound(fmt.Errorf("unknown group with id `%s`", scene.Group.Rid))
+	}
+
+	actions, err := sceneActions(group, lights)
+	if err != nil {
+		return scene, model.WrapInvalid(err)
+	}
+
+	if err = s.update(ctx, "scene", id, map[string]any{"actions": actions}); err != nil {
+		return scene, fmt.Errorf("update scene `%s`: %w", id, err)
+	}
+
+	return s.refreshScene(ctx, id)
+}
+
+// SceneMatches tells if the scene already saves the given state of the lights, by light ID, within the rounding of the bridge
+func (s *Service) SceneMatches(scene Scene, lights map[string]LightAction) bool {
+	group, ok := s.Group(scene.Group.Rid)
+	if !ok {
+		return false
+	}
+
+	actions, err := sceneActions(group, lights)
+	if err != nil || len(actions) != len(scene.Actions) {
+		return false
+	}
+
+	for _, action := range actions {
+		index := slices.IndexFunc(scene.Actions, func(item SceneAction) bool {
+			return item.Target.Rid == action.Target.Rid
+		})
+
+		if index == -1 || !action.Action.equivalent(scene.Actions[index].Action) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// DeleteScene deletes the scene from the bridge
+func (s *Service) DeleteScene(ctx context.Context, id string) (err error) {
+	ctx, end := telemetry.StartSpan(ctx, s.tracer, "delete_scene", trace.WithAttributes(attribute.String("id", id)))
+	defer end(&err)
+
+	if err = s.remove(ctx, "scene", id); err != nil {
+		return fmt.Errorf("delete scene `%s`: %w", id, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.scenes, id)
+
+	return nil
+}
+
+func (s *Service) refreshScene(ctx context.Context, id string) (Scene, error) {
+	scene, err := get[Scene](ctx, s, "scene", id)
+	if err != nil {
+		return scene, fmt.Errorf("get scene `%s`: %w", id, err)
+	}
+
+	scene.IDV1 = strings.TrimPrefix(scene.IDV1, "/scenes/")
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.scenes[id] = scene
+
+	return scene, nil
+}
+
+// sceneActions checks the state of each light and returns them in the order of the group, plugs keeping only their on state
+func sceneActions(group Group, lights map[string]LightAction) ([]SceneAction, error) {
+	if len(lights) == 0 {
+		return nil, errors.New("a scene needs the state of at least one light")
+	}
+
+	var output []SceneAction
+
+	for _, light := range group.Lights {
+		action, ok := lights[light.ID]
+		if !ok {
+			continue
+		}
+
+		if err := action.validate(); err != nil {
+			return nil, fmt.Errorf("light `%s`: %w", light.Metadata.Name, err)
+		}
+
+		if light.Metadata.Archetype == "plug" {
+			action = LightAction{On: action.On}
+		}
+
+		output = append(output, SceneAction{
+			Target: deviceReference{
+				Rid:   light.ID,
+				Rtype: "light",
+			},
+			Action: action,
+		})
+	}
+
+	if len(output) != len(lights) {
+		return nil, fmt.Errorf("some lights aren't part of `%s`", group.Name)
+	}
+
+	return output, nil
+}
+
+// equivalent tells if both states are the same, brightness and color being compared within the rounding of the bridge
+func (la LightAction) equivalent(other LightAction) bool {
+	if (la.On == nil) != (other.On == nil) || (la.On != nil && la.On.On != other.On.On) {
+		return false
+	}
+
+	if (la.Dimming == nil) != (other.Dimming == nil) || (la.Dimming != nil && math.Abs(la.Dimming.Brightness-other.Dimming.Brightness) > 1) {
+		return false
+	}
+
+	if (la.ColorTemperature == nil) != (other.ColorTemperature == nil) || (la.ColorTemperature != nil && la.ColorTemperature.Mirek != other.ColorTemperature.Mirek) {
+		return false
+	}
+
+	if (la.Color == nil) != (other.Color == nil) {
+		return false
+	}
+
+	return la.Color == nil || (math.Abs(la.Color.XY.X-other.Color.XY.X) < 0.001 && math.Abs(la.Color.XY.Y-other.Color.XY.Y) < 0.001)
+}
+
+func (la LightAction) validate() error {
+	if la.On == nil {
+		return errors.New("on is required")
+	}
+
+	if la.Dimming != nil && (la.Dimming.Brightness <= 0 || la.Dimming.Brightness > 100) {
+		return errors.New("brightness must be between 1 and 100")
+	}
+
+	if la.Color != nil && la.ColorTemperature != nil {
+		return errors.New("color and color temperature are exclusive")
+	}
+
+	if la.ColorTemperature != nil && (la.ColorTemperature.Mirek < MinMirek || la.ColorTemperature.Mirek > MaxMirek) {
+		return fmt.Errorf("mirek must be between %d and %d", MinMirek, MaxMirek)
+	}
+
+	if la.Color != nil && (la.Color.XY.X < 0 || la.Color.XY.X > 1 || la.Color.XY.Y < 0 || la.Color.XY.Y > 1) {
+		return errors.New("x and y must be between 0 and 1")
+	}
+
+	return nil
+}
+
+func sceneActivityState(scene Scene, action SceneRecall, brightness float64) string {
+	state := fmt.Sprintf("scene %s (%s)", scene.Metadata.Name, action)
+
+	if brightness > 0 {
+		state += fmt.Sprintf(" at %s%%", strconv.FormatFloat(brightness, 'f', -1, 64))
+	}
+
+	return state
+}
+
+// xyToHex converts a color of the CIE xy color space to #rrggbb, at full brightness
+func xyToHex(x, y float64) string {
+	if y == 0 {
+		return "#000000"
+	}
+
+	X := x / y
+	Z := (1 - x - y) / y
+
+	rgb := [3]float64{
+		X*1.656492 - 0.354851 - Z*0.255038,
+		-X*0.707196 + 1.655397 + Z*0.036152,
+		X*0.051713 - 0.121364 + Z*1.011530,
+	}
+
+	if highest := max(rgb[0], rgb[1], rgb[2]); highest > 1 {
+		for i := range rgb {
+			rgb[i] /= highest
+		}
+	}
+
+	for i, value := range rgb {
+		if value <= 0.0031308 {
+			rgb[i] = 12.92 * value
+		} else {
+			rgb[i] = 1.055*math.Pow(value, 1/2.4) - 0.055
+		}
+	}
+
+	return toHex(rgb[0]*255, rgb[1]*255, rgb[2]*255)
+}
+
+// mirekToHex approximates the color of a white light of the given temperature as #rrggbb
+func mirekToHex(mirek int) string {
+	if mirek <= 0 {
+		return "#ffffff"
+	}
+
+	temperature := 1000000 / float64(mirek) / 100
+
+	red, green, blue := 255.0, 255.0, 255.0
+
+	if temperature <= 66 {
+		green = 99.4708025861*math.Log(temperature) - 161.1195681661
+
+		if temperature <= 19 {
+			blue = 0
+		} else {
+			blue = 138.5177312231*math.Log(temperature-10) - 305.0447927307
+		}
+	} else {
+		red = 329.698727446 * math.Pow(temperature-60, -0.1332047592)
+		green = 288.1221695283 * math.Pow(temperature-60, -0.0755148492)
+	}
+
+	return toHex(red, green, blue)
+}
+
+func toHex(red, green, blue float64) string {
+	component := func(value float64) int {
+		return int(math.Round(min(max(value, 0), 255)))
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x", component(red), component(green), component(blue))
+}
@@ -0,0 +1,232 @@
+package v2
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSceneColors(t *testing.T) {
+	cases := map[string]struct {
+		content string
+		want    []string
+	}{
+		"palette": {
+			content: `{"palette": {"color": [{"color": {"xy": {"x": 0.7006, "y": 0.2993}}}], "color_temperature": [{"color_temperature": {"mirek": 500}}]}}`,
+			want:    []string{"#ff0000", "#ff890e"},
+		},
+		"actions": {
+			content: `{"actions": [{"action": {"on": {"on": true}, "color_temperature": {"mirek": 153}}}, {"action": {"on": {"on": false}, "color": {"xy": {"x": 0.7006, "y": 0.2993}}}}, {"action": {"on": {"on": true}, "color_temperature": {"mirek": 153}}}]}`,
+			want:    []string{"#fffffb"},
+		},
+		"empty": {
+			content: `{}`,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var scene Scene
+			if err := json.Unmarshal([]byte(tc.content), &scene); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := scene.Colors(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Colors() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEventStatus(t *testing.T) {
+	cases := map[string]struct {
+		content string
+		want    eventStatus
+	}{
+		"connectivity": {
+			content: `"connected"`,
+			want:    eventStatus{Connectivity: Connected},
+		},
+		"scene": {
+			content: `{"active": "static"}`,
+			want:    eventStatus{Scene: &SceneStatus{Active: "static"}},
+		},
+		"null": {
+			content: `null`,
+		},
+		"unexpected object": {
+			content: `{"active": 1}`,
+		},
+		"unexpected shape": {
+			content: `[1, 2]`,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var got eventStatus
+			if err := json.Unmarshal([]byte(tc.content), &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("UnmarshalJSON() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLightAction(t *testing.T) {
+	cases := map[string]struct {
+		content string
+		want    string
+	}{
+		"off": {
+			content: `{"on": {"on": false}, "dimming": {"brightness": 40}, "color_temperature": {"mirek": 300}}`,
+			want:    `{"on":{"on":false}}`,
+		},
+		"temperature": {
+			content: `{"on": {"on": true}, "dimming": {"brightness": 40}, "color": {"xy": {"x": 0.45, "y": 0.41}}, "color_temperature": {"mirek": 300}}`,
+			want:    `{"on":{"on":true},"dimming":{"brightness":40},"color_temperature":{"mirek":300}}`,
+		},
+		"color": {
+			content: `{"on": {"on": true}, "dimming": {"brightness": 40}, "color": {"xy": {"x": 0.7, "y": 0.3}}, "color_temperature": {"mirek": null}}`,
+			want:    `{"on":{"on":true},"dimming":{"brightness":40},"color":{"xy":{"x":0.7,"y":0.3}}}`,
+		},
+		"plug": {
+			content: `{"metadata": {"archetype": "plug"}, "on": {"on": true}, "dimming": {"brightness": 100}}`,
+			want:    `{"on":{"on":true}}`,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var light Light
+			if err := json.Unmarshal([]byte(tc.content), &light); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := json.Marshal(lightAction(light))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tc.want {
+				t.Errorf("lightAction() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSceneActions(t *testing.T) {
+	lamp := &Light{ID: "lamp"}
+	plug := &Light{ID: "plug"}
+	plug.Metadata.Archetype = "plug"
+
+	group := Group{Name: "Living", Lights: []*Light{lamp, plug}}
+
+	on := &On{On: true}
+
+	cases := map[string]struct {
+		lights  map[string]LightAction
+		want    []SceneAction
+		wantErr bool
+	}{
+		"ordered": {
+			lights: map[string]LightAction{
+				"plug": {On: on, Dimming: &Dimming{Brightness: 50}},
+				"lamp": {On: on, ColorTemperature: &ColorTemperature{Mirek: 300}},
+			},
+			want: []SceneAction{
+				{Target: deviceReference{Rid: "lamp", Rtype: "light"}, Action: LightAction{On: on, ColorTemperature: &ColorTemperature{Mirek: 300}}},
+				{Target: deviceReference{Rid: "plug", Rtype: "light"}, Action: LightAction{On: on}},
+			},
+		},
+		"empty": {
+			lights:  map[string]LightAction{},
+			wantErr: true,
+		},
+		"unknown light": {
+			lights:  map[string]LightAction{"other": {On: on}},
+			wantErr: true,
+		},
+		"missing on": {
+			lights:  map[string]LightAction{"lamp": {Dimming: &Dimming{Brightness: 50}}},
+			wantErr: true,
+		},
+		"invalid mirek": {
+			lights:  map[string]LightAction{"lamp": {On: on, ColorTemperature: &ColorTemperature{Mirek: 50}}},
+			wantErr: true,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			got, err := sceneActions(group, tc.lights)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("sceneActions() succeeded, want an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("sceneActions() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("sceneActions() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLightActionEquivalent(t *testing.T) {
+	cases := map[string]struct {
+		action string
+		other  string
+		want   bool
+	}{
+		"same": {
+			action: `{"on": {"on": true}, "dimming": {"brightness": 50}, "color_temperature": {"mirek": 300}}`,
+			other:  `{"on": {"on": true}, "dimming": {"brightness": 50}, "color_temperature": {"mirek": 300}}`,
+			want:   true,
+		},
+		"rounded": {
+			action: `{"on": {"on": true}, "dimming": {"brightness": 50}, "color": {"xy": {"x": 0.70001, "y": 0.3}}}`,
+			other:  `{"on": {"on": true}, "dimming": {"brightness": 50.2}, "color": {"xy": {"x": 0.7, "y": 0.3}}}`,
+			want:   true,
+		},
+		"brightness": {
+			action: `{"on": {"on": true}, "dimming": {"brightness": 50}}`,
+			other:  `{"on": {"on": true}, "dimming": {"brightness": 80}}`,
+		},
+		"on": {
+			action: `{"on": {"on": true}}`,
+			other:  `{"on": {"on": false}}`,
+		},
+		"mode": {
+			action: `{"on": {"on": true}, "color_temperature": {"mirek": 300}}`,
+			other:  `{"on": {"on": true}, "color": {"xy": {"x": 0.45, "y": 0.41}}}`,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var action, other LightAction
+
+			if err := json.Unmarshal([]byte(tc.action), &action); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := json.Unmarshal([]byte(tc.other), &other); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := action.equivalent(other); got != tc.want {
+				t.Errorf("equivalent() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,378 @@
+package v2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"github.com/ViBiOh/httputils/v4/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TimeslotTime starts a slot at a given time of the day
+	TimeslotTime = "time"
+	// TimeslotSunset starts a slot at the sunset
+	TimeslotSunset = "sunset"
+
+	smartSceneActive = "active"
+)
+
+// Weekdays are the days of a smart scene, as named by the bridge
+var Weekdays = []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+type TimeOfDay struct {
+	Hour   int `json:"hour"`
+	Minute int `json:"minute"`
+	Second int `json:"second"`
+}
+
+type TimeslotStart struct {
+	Time *TimeOfDay `json:"time,omitempty"`
+	Kind string     `json:"kind"`
+}
+
+// String returns the start as HH:MM, or its kind when relative to the sun
+func (ts TimeslotStart) String() string {
+	if ts.Kind != TimeslotTime || ts.Time == nil {
+		return ts.Kind
+	}
+
+	return fmt.Sprintf("%02d:%02d", ts.Time.Hour, ts.Time.Minute)
+}
+
+func (ts TimeslotStart) validate() error {
+	switch ts.Kind {
+	case TimeslotSunset:
+		return nil
+	case TimeslotTime:
+		if ts.Time == nil || ts.Time.Hour < 0 || ts.Time.Hour > 23 || ts.Time.Minute < 0 || ts.Time.Minute > 59 || ts.Time.Second < 0 || ts.Time.Second > 59 {
+			return errors.New("invalid time of the day")
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown start `%s`", ts.Kind)
+	}
+}
+
+type Timeslot struct {
+	StartTime TimeslotStart   `json:"start_time"`
+	Target    deviceReference `json:"target"`
+}
+
+// NewTimeslot creates a slot recalling the given scene
+func NewTimeslot(start TimeslotStart, sceneID string) Timeslot {
+	return Timeslot{
+		StartTime: start,
+		Target: deviceReference{
+			Rid:   sceneID,
+			Rtype: "scene",
+		},
+	}
+}
+
+type WeekTimeslots struct {
+	Timeslots  []Timeslot `json:"timeslots"`
+	Recurrence []string   `json:"recurrence"`
+}
+
+type ActiveTimeslot struct {
+	Weekday    string `json:"weekday"`
+	TimeslotID int    `json:"timeslot_id"`
+}
+
+type SmartScene struct {
+	ActiveTimeslot *ActiveTimeslot `json:"active_timeslot,omitempty"`
+	ID             string          `json:"id"`
+	Metadata       struct {
+		Name    string `json:"name"`
+		Appdata string `json:"appdata,omitempty"`
+	} `json:"metadata"`
+	Group              deviceReference `json:"group"`
+	State              string          `json:"state"`
+	WeekTimeslots      []WeekTimeslots `json:"week_timeslots"`
+	TransitionDuration int64           `json:"transition_duration"`
+}
+
+// Active tells if the smart scene is currently switching the scenes of its group
+func (ss SmartScene) Active() bool {
+	return ss.State == smartSceneActive
+}
+
+// Transition returns the duration of the transition between slots
+func (ss SmartScene) Transition() time.Duration {
+	return time.Duration(ss.TransitionDuration) * time.Millisecond
+}
+
+// ActiveSlot returns the slot currently applied, if the smart scene is active
+func (ss SmartScene) ActiveSlot() (Timeslot, bool) {
+	if !ss.Active() || ss.ActiveTimeslot == nil {
+		return Timeslot{}, false
+	}
+
+	for _, week := range ss.WeekTimeslots {
+		if !slices.Contains(week.Recurrence, ss.ActiveTimeslot.Weekday) {
+			continue
+		}
+
+		if ss.ActiveTimeslot.TimeslotID >= 0 && ss.ActiveTimeslot.TimeslotID < len(week.Timeslots) {
+			return week.Timeslots[ss.ActiveTimeslot.TimeslotID], true
+		}
+	}
+
+	return Timeslot{}, false
+}
+
+// SmartSceneDefinition is the editable part of a smart scene
+type SmartSceneDefinition struct {
+	Name          string
+	Appdata       string
+	WeekTimeslots []WeekTimeslots
+	Transition    time.Duration
+}
+
+// Matches tells if the smart scene already has the given name, appdata, slots and transition
+func (ss SmartScene) Matches(definition SmartSceneDefinition) bool {
+	return ss.Metadata.Name == strings.TrimSpace(definition.Name) && ss.Metadata.Appdata == definition.Appdata && ss.Transition() == definition.Transition && reflect.DeepEqual(ss.WeekTimeslots, definition.WeekTimeslots)
+}
+
+func (ssd SmartSceneDefinition) payload() map[string]any {
+	return map[string]any{
+		"metadata":            metadata(ssd.Name, ssd.Appdata),
+		"week_timeslots":      ssd.WeekTimeslots,
+		"transition_duration": ssd.Transition.Milliseconds(),
+	}
+}
+
+type SmartSceneByName []SmartScene
+
+func (a SmartSceneByName) Len() int      { return len(a) }
+func (a SmartSceneByName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a SmartSceneByName) Less(i, j int) bool {
+	return a[i].Metadata.Name < a[j].Metadata.Name
+}
+
+// SmartScenes returns the smart scenes of every room and zone, by group ID and sorted by name
+func (s *Service) SmartScenes() map[string][]SmartScene {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	output := make(map[string][]SmartScene)
+
+	for _, smartScene := range s.smartScenes {
+		output[smartScene.Group.Rid] = append(output[smartScene.Group.Rid], smartScene)
+	}
+
+	for _, smartScenes := range output {
+		sort.Sort(SmartSceneByName(smartScenes))
+	}
+
+	return output
+}
+
+func (s *Service) SmartScene(id string) (SmartScene, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	smartScene, ok := s.smartScenes[id]
+
+	return smartScene, ok
+}
+
+func (s *Service) buildSmartScenes(ctx context.Context) (map[string]SmartScene, error) {
+	smartScenes, err := list[SmartScene](ctx, s, "smart_scene")
+	if err != nil {
+		return nil, fmt.Errorf("list smart scenes: %w", err)
+	}
+
+	output := make(map[string]SmartScene, len(smartScenes))
+	for _, smartScene := range smartScenes {
+		output[smartScene.ID] = smartScene
+	}
+
+	return output, nil
+}
+
+// updateSmartScene keeps the smart scenes up-to-date from the events of the bridge, the smart scene being fetched again on every change
+func (s *Service) updateSmartScene(ctx context.Context, eventType, id string) {
+	if eventType == "delete" {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		delete(s.smartScenes, id)
+
+		return
+	}
+
+	smartScene, err := get[SmartScene](ctx, s, "smart_scene", id)
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "get smart scene", slog.String("id", id), slog.Any("error", err))
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.smartScenes[id] = smartScene
+}
+
+// CreateSmartScene creates a smart scene switching the scenes of the group across the week
+func (s *Service) CreateSmartScene(ctx context.Context, groupID string, definition SmartSceneDefinition) (smartScene SmartScene, err error) {
+	ctx, end := telemetry.StartSpan(ctx, s.tracer, "create_smart_scene", trace.WithAttributes(attribute.String("group", groupID), attribute.String("name", definition.Name)))
+	defer end(&err)
+
+	definition.Name = strings.TrimSpace(definition.Name)
+
+	group, ok := s.Group(groupID)
+	if !ok {
+		return smartScene, model.WrapNotFound(fmt.Errorf("unknown group with id `%s`", groupID))
+	}
+
+	if group.Bridge {
+		return smartScene, model.WrapInvalid(errors.New("smart scenes can't be created for the whole bridge"))
+	}
+
+	if err = s.validateSmartScene(group.ID, definition); err != nil {
+		return smartScene, model.WrapInvalid(err)
+	}
+
+	payload := definition.payload()
+	payload["type"] = "smart_scene"
+	payload["group"] = deviceReference{
+		Rid:   group.ID,
+		Rtype: group.Kind,
+	}
+
+	id, err := s.create(ctx, "smart_scene", payload)
+	if err != nil {
+		return smartScene, fmt.Errorf("create smart scene `%s`: %w", definition.Name, err)
+	}
+
+	return s.refreshSmartScene(ctx, id)
+}
+
+// UpdateSmartScene replaces the name, the slots and the transition of the smart scene
+func (s *Service) UpdateSmartScene(ctx context.Context, id string, definition SmartSceneDefinition) (smartScene SmartScene, err error) {
+	ctx, end := telemetry.StartSpan(ctx, s.tracer, "update_smart_scene", trace.WithAttributes(attribute.String("id", id), attribute.String("name", definition.Name)))
+	defer end(&err)
+
+	definition.Name = strings.TrimSpace(definition.Name)
+
+	smartScene, ok := s.SmartScene(id)
+	if !ok {
+		return smartScene, model.WrapNotFound(fmt.Errorf("unknown smart scene with id `%s`", id))
+	}
+
+	if err = s.validateSmartScene(smartScene.Group.Rid, definition); err != nil {
+		return smartScene, model.WrapInvalid(err)
+	}
+
+	if err = s.update(ctx, "smart_scene", id, definition.payload()); err != nil {
+		return smartScene, fmt.Errorf("update smart scene `%s`: %w", id, err)
+	}
+
+	return s.refreshSmartScene(ctx, id)
+}
+
+// DeleteSmartScene deletes the smart scene from the bridge
+func (s *Service) DeleteSmartScene(ctx context.Context, id string) (err error) {
+	ctx, end := telemetry.StartSpan(ctx, s.tracer, "delete_smart_scene", trace.WithAttributes(attribute.String("id", id)))
+	defer end(&err)
+
+	if err = s.remove(ctx, "smart_scene", id); err != nil {
+		return fmt.Errorf("delete smart scene `%s`: %w", id, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.smartScenes, id)
+
+	return nil
+}
+
+func (s *Service) refreshSmartScene(ctx context.Context, id string) (SmartScene, error) {
+	smartScene, err := get[SmartScene](ctx, s, "smart_scene", id)
+	if err != nil {
+		return smartScene, fmt.Errorf("get smart scene `%s`: %w", id, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.smartScenes[id] = smartScene
+
+	return smartScene, nil
+}
+
+// validateSmartScene checks that every day is given once at most, with distinct starts recalling scenes of the group
+func (s *Service) validateSmartScene(groupID string, definition SmartSceneDefinition) error {
+	if len(definition.Name) == 0 || len(definition.Name) > maxSceneName {
+		return fmt.Errorf("name must be between 1 and %d characters", maxSceneName)
+	}
+
+	if definition.Transition < 0 {
+		return errors.New("transition can't be negative")
+	}
+
+	if len(definition.WeekTimeslots) == 0 {
+		return errors.New("a smart scene needs at least a day with slots")
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	days := make(map[string]struct{}, len(Weekdays))
+
+	for _, week := range definition.WeekTimeslots {
+		if len(week.Recurrence) == 0 {
+			return errors.New("slots need at least a day")
+		}
+
+		for _, day := range week.Recurrence {
+			if !slices.Contains(Weekdays, day) {
+				return fmt.Errorf("unknown day `%s`", day)
+			}
+
+			if _, ok := days[day]; ok {
+				return fmt.Errorf("day `%s` has several sets of slots", day)
+			}
+
+			days[day] = struct{}{}
+		}
+
+		if len(week.Timeslots) == 0 {
+			return fmt.Errorf("%s need at least a slot", strings.Join(week.Recurrence, ", "))
+		}
+
+		starts := make(map[string]struct{}, len(week.Timeslots))
+
+		for _, timeslot := range week.Timeslots {
+			if err := timeslot.StartTime.validate(); err != nil {
+				return err
+			}
+
+			start := timeslot.StartTime.String()
+			if _, ok := starts[start]; ok {
+				return fmt.Errorf("several slots start at %s", start)
+			}
+
+			starts[start] = struct{}{}
+
+			if scene, ok := s.scenes[timeslot.Target.Rid]; !ok || scene.Group.Rid != groupID {
+				return fmt.Errorf("unknown scene with id `%s` in the group", timeslot.Target.Rid)
+			}
+		}
+	}
+
+	return nil
+}
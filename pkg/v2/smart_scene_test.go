@@ -0,0 +1,129 @@
+package v2
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSmartSceneActiveSlot(t *testing.T) {
+	week := `"week_timeslots": [{"recurrence": ["monday", "tuesday"], "timeslots": [{"start_time": {"kind": "time", "time": {"hour": 7}}, "target": {"rid": "morning"}}, {"start_time": {"kind": "sunset"}, "target": {"rid": "evening"}}]}, {"recurrence": ["sunday"], "timeslots": [{"start_time": {"kind": "time", "time": {"hour": 9}}, "target": {"rid": "lazy"}}]}]`
+
+	cases := map[string]struct {
+		content string
+		want    string
+		wantOk  bool
+	}{
+		"weekday": {
+			content: `{"state": "active", "active_timeslot": {"weekday": "tuesday", "timeslot_id": 1}, ` + week + `}`,
+			want:    "evening",
+			wantOk:  true,
+		},
+		"sunday": {
+			content: `{"state": "active", "active_timeslot": {"weekday": "sunday", "timeslot_id": 0}, ` + week + `}`,
+			want:    "lazy",
+			wantOk:  true,
+		},
+		"inactive": {
+			content: `{"state": "inactive", "active_timeslot": {"weekday": "sunday", "timeslot_id": 0}, ` + week + `}`,
+		},
+		"out of range": {
+			content: `{"state": "active", "active_timeslot": {"weekday": "sunday", "timeslot_id": 3}, ` + week + `}`,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var smartScene SmartScene
+			if err := json.Unmarshal([]byte(tc.content), &smartScene); err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok := smartScene.ActiveSlot()
+			if ok != tc.wantOk || got.Target.Rid != tc.want {
+				t.Errorf("ActiveSlot() = (%s, %t), want (%s, %t)", got.Target.Rid, ok, tc.want, tc.wantOk)
+			}
+		})
+	}
+}
+
+func TestValidateSmartScene(t *testing.T) {
+	morning := Scene{ID: "morning", Group: deviceReference{Rid: "living"}}
+	other := Scene{ID: "other", Group: deviceReference{Rid: "kitchen"}}
+
+	instance := Service{
+		scenes: map[string]Scene{morning.ID: morning, other.ID: other},
+	}
+
+	seven := NewTimeslot(TimeslotStart{Kind: TimeslotTime, Time: &TimeOfDay{Hour: 7}}, "morning")
+	sunset := NewTimeslot(TimeslotStart{Kind: TimeslotSunset}, "morning")
+
+	cases := map[string]struct {
+		definition SmartSceneDefinition
+		wantErr    bool
+	}{
+		"valid": {
+			definition: SmartSceneDefinition{Name: "Daylight", WeekTimeslots: []WeekTimeslots{{Recurrence: []string{"monday", "friday"}, Timeslots: []Timeslot{seven, sunset}}}},
+		},
+		"no name": {
+			definition: SmartSceneDefinition{WeekTimeslots: []WeekTimeslots{{Recurrence: []string{"monday"}, Timeslots: []Timeslot{seven}}}},
+			wantErr:    true,
+		},
+		"no slot": {
+			definition: SmartSceneDefinition{Name: "Daylight"},
+			wantErr:    true,
+		},
+		"unknown day": {
+			definition: SmartSceneDefinition{Name: "Daylight", WeekTimeslots: []WeekTimeslots{{Recurrence: []string{"someday"}, Timeslots: []Timeslot{seven}}}},
+			wantErr:    true,
+		},
+		"repeated day": {
+			definition: SmartSceneDefinition{Name: "Daylight", WeekTimeslots: []WeekTimeslots{{Recurrence: []string{"monday"}, Timeslots: []Timeslot{seven}}, {Recurrence: []string{"monday"}, Timeslots: []Timeslot{sunset}}}},
+			wantErr:    true,
+		},
+		"same start": {
+			definition: SmartSceneDefinition{Name: "Daylight", WeekTimeslots: []WeekTimeslots{{Recurrence: []string{"monday"}, Timeslots: []Timeslot{seven, seven}}}},
+			wantErr:    true,
+		},
+		"invalid time": {
+			definition: SmartSceneDefinition{Name: "Daylight", WeekTimeslots: []WeekTimeslots{{Recurrence: []string{"monday"}, Timeslots: []Timeslot{NewTimeslot(TimeslotStart{Kind: TimeslotTime, Time: &TimeOfDay{Hour: 25}}, "morning")}}}},
+			wantErr:    true,
+		},
+		"scene of another group": {
+			definition: SmartSceneDefinition{Name: "Daylight", WeekTimeslots: []WeekTimeslots{{Recurrence: []string{"monday"}, Timeslots: []Timeslot{NewTimeslot(seven.StartTime, "other")}}}},
+			wantErr:    true,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			err := instance.validateSmartScene("living", tc.definition)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("validateSmartScene() = %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestTimeslotStartString(t *testing.T) {
+	cases := map[string]struct {
+		instance TimeslotStart
+		want     string
+	}{
+		"time": {
+			instance: TimeslotStart{Kind: TimeslotTime, Time: &TimeOfDay{Hour: 7, Minute: 5}},
+			want:     "07:05",
+		},
+		"sunset": {
+			instance: TimeslotStart{Kind: TimeslotSunset},
+			want:     "sunset",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := tc.instance.String(); got != tc.want {
+				t.Errorf("String() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package v2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+type SoftwareUpdateState string
+
+const (
+	NoUpdate       SoftwareUpdateState = "no_update"
+	UpdatePending  SoftwareUpdateState = "update_pending"
+	ReadyToInstall SoftwareUpdateState = "ready_to_install"
+	Installing     SoftwareUpdateState = "installing"
+)
+
+// Pending returns true if an update is available, downloading or being installed
+func (sus SoftwareUpdateState) Pending() bool {
+	return len(sus) != 0 && sus != NoUpdate
+}
+
+// parseSoftwareUpdateState returns the state of an event, empty if it isn't a string
+func parseSoftwareUpdateState(content json.RawMessage) SoftwareUpdateState {
+	var state SoftwareUpdateState
+	_ = json.Unmarshal(content, &state)
+
+	return state
+}
+
+type DeviceSoftwareUpdate struct {
+	Owner deviceReference     `json:"owner"`
+	ID    string              `json:"id"`
+	State SoftwareUpdateState `json:"state"`
+}
+
+type DeviceByName []Device
+
+func (a DeviceByName) Len() int      { return len(a) }
+func (a DeviceByName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a DeviceByName) Less(i, j int) bool {
+	return a[i].Metadata.Name < a[j].Metadata.Name
+}
+
+func (s *Service) Devices() []Device {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	output := make([]Device, 0, len(s.devices))
+
+	for _, item := range s.devices {
+		output = append(output, item)
+	}
+
+	sort.Sort(DeviceByName(output))
+
+	return output
+}
+
+func (s *Service) initSoftwareUpdates(ctx context.Context) error {
+	softwareUpdates, err := list[DeviceSoftwareUpdate](ctx, s, "device_software_update")
+	if err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, softwareUpdate := range softwareUpdates {
+		if device, ok := s.devices[softwareUpdate.Owner.Rid]; ok {
+			device.SoftwareUpdate = softwareUpdate.State
+			s.devices[device.ID] = device
+		}
+	}
+
+	s.recordPendingUpdates(ctx)
+
+	return nil
+}
+
+func (s *Service) updateSoftwareUpdate(ctx context.Context, owner string, state SoftwareUpdateState) {
+	if len(state) == 0 {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	device, ok := s.devices[owner]
+	if !ok {
+		slog.LogAttrs(ctx, slog.LevelWarn, "unknown software update owner ID", slog.String("owner", owner))
+		return
+	}
+
+	if device.SoftwareUpdate != state {
+		device.SoftwareUpdate = state
+		device.SoftwareUpdatedAt = time.Now()
+
+		slog.LogAttrs(ctx, slog.LevelInfo, "Software update", slog.String("state", string(state)), slog.String("name", device.Metadata.Name))
+	}
+
+	s.devices[owner] = device
+
+	s.recordPendingUpdates(ctx)
+}
+
+func (s *Service) updateSoftwareVersion(ctx context.Context, id, version string) {
+	if len(version) == 0 {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if device, ok := s.devices[id]; ok {
+		if device.ProductData.SoftwareVersion != version {
+			device.ProductData.SoftwareVersion = version
+			device.SoftwareUpdatedAt = time.Now()
+
+			slog.LogAttrs(ctx, slog.LevelInfo, "Software version", slog.String("version", version), slog.String("name", device.Metadata.Name))
+		}
+
+		s.devices[id] = device
+	}
+}
+
+func (s *Service) recordPendingUpdates(ctx context.Context) {
+	var count int64
+
+	for _, device := range s.devices {
+		if device.SoftwareUpdate.Pending() {
+			count++
+		}
+	}
+
+	s.pendingUpdatesMetric.Record(ctx, count)
+}
@@ -0,0 +1,83 @@
+package v2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestUpdateSoftwareUpdate(t *testing.T) {
+	updatedAt := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		previous    SoftwareUpdateState
+		state       SoftwareUpdateState
+		want        SoftwareUpdateState
+		wantUpdated bool
+	}{
+		"available": {
+			previous:    NoUpdate,
+			state:       UpdatePending,
+			want:        UpdatePending,
+			wantUpdated: true,
+		},
+		"installing": {
+			previous:    ReadyToInstall,
+			state:       Installing,
+			want:        Installing,
+			wantUpdated: true,
+		},
+		"unchanged": {
+			previous: UpdatePending,
+			state:    UpdatePending,
+			want:     UpdatePending,
+		},
+		"empty": {
+			previous: Installing,
+			want:     Installing,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			service := Service{devices: map[string]Device{"bulb": {ID: "bulb", SoftwareUpdate: tc.previous, SoftwareUpdatedAt: updatedAt}}}
+			if err := service.createMetrics(noop.NewMeterProvider()); err != nil {
+				t.Fatal(err)
+			}
+
+			service.updateSoftwareUpdate(context.Background(), "bulb", tc.state)
+
+			got := service.devices["bulb"]
+			if got.SoftwareUpdate != tc.want {
+				t.Errorf("updateSoftwareUpdate() = `%s`, want `%s`", got.SoftwareUpdate, tc.want)
+			}
+
+			if updated := !got.SoftwareUpdatedAt.Equal(updatedAt); updated != tc.wantUpdated {
+				t.Errorf("updateSoftwareUpdate() updated = %t, want %t", updated, tc.wantUpdated)
+			}
+		})
+	}
+}
+
+func TestSoftwareUpdateStatePending(t *testing.T) {
+	cases := map[string]struct {
+		state SoftwareUpdateState
+		want  bool
+	}{
+		"unknown":          {},
+		"no update":        {state: NoUpdate},
+		"update pending":   {state: UpdatePending, want: true},
+		"ready to install": {state: ReadyToInstall, want: true},
+		"installing":       {state: Installing, want: true},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := tc.state.Pending(); got != tc.want {
+				t.Errorf("Pending() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -9,7 +9,9 @@ import (
 )
 
 func (s *Service) Start(ctx context.Context) {
-	s.streamIndefinitely(ctx.Done())
+	defer s.closeSubscriptions()
+
+	s.streamIndefinitely(ctx)
 }
 
 func (s *Service) Init(ctx context.Context) (err error) {
@@ -18,8 +20,9 @@ func (s *Service) Init(ctx context.Context) (err error) {
 
 	var tapDevices []Device
 	var motionDevices []Device
+	devices := make(map[string]Device)
 
-	devicePowers, err := list[DevicePower](ctx, s.req, "device_power")
+	devicePowers, err := list[DevicePower](ctx, s, "device_power")
 	if err != nil {
 		return fmt.Errorf("list devices' powers: %w", err)
 	}
@@ -37,10 +40,18 @@ func (s *Service) Init(ctx context.Context) (err error) {
 		if strings.EqualFold(device.ProductData.ProductName, "Hue motion sensor") {
 			motionDevices = append(motionDevices, device)
 		}
+	}, func(device Device) {
+		devices[device.ID] = device
 	}); err != nil {
 		return fmt.Errorf("stream devices: %w", err)
 	}
 
+	s.devices = devices
+
+	if err = s.initSoftwareUpdates(ctx); err != nil {
+		return fmt.Errorf("init software updates: %w", err)
+	}
+
 	s.lights, err = s.buildLights(ctx)
 	if err != nil {
 		return fmt.Errorf("build lights: %w", err)
@@ -51,6 +62,18 @@ func (s *Service) Init(ctx context.Context) (err error) {
 		return fmt.Errorf("build groups: %w", err)
 	}
 
+	s.rooms = deviceRooms(s.groups)
+
+	s.scenes, err = s.buildScenes(ctx)
+	if err != nil {
+		return fmt.Errorf("build scenes: %w", err)
+	}
+
+	s.smartScenes, err = s.buildSmartScenes(ctx)
+	if err != nil {
+		return fmt.Errorf("build smart scenes: %w", err)
+	}
+
 	s.motionSensors, err = s.buildMotionSensor(ctx, motionDevices, devicePowers)
 	if err != nil {
 		return fmt.Errorf("build motion sensor: %w", err)
@@ -61,5 +84,17 @@ func (s *Service) Init(ctx context.Context) (err error) {
 		return fmt.Errorf("build motion sensor: %w", err)
 	}
 
+	if err = s.initConnectivity(ctx); err != nil {
+		return fmt.Errorf("init connectivity: %w", err)
+	}
+
+	s.buttons, err = s.buildButtons(ctx)
+	if err != nil {
+		return fmt.Errorf("build buttons: %w", err)
+	}
+
+	s.recordLightsAndGroups(ctx)
+	s.recordSensors(ctx)
+
 	return nil
 }
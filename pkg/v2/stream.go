@@ -11,12 +11,39 @@ import (
 	"time"
 
 	"github.com/ViBiOh/httputils/v4/pkg/request"
+	"github.com/ViBiOh/httputils/v4/pkg/telemetry"
+	"github.com/ViBiOh/hue/pkg/activity"
+	"github.com/ViBiOh/hue/pkg/history"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var dataPrefix = []byte("data: ")
 
+// eventStatus is the status of a connectivity, given as a string, or the one of a scene, given as an object
+type eventStatus struct {
+	Scene        *SceneStatus
+	Connectivity ConnectivityStatus
+}
+
+// UnmarshalJSON never fails, an unexpected shape being ignored for not dropping the other events of the batch
+func (es *eventStatus) UnmarshalJSON(content []byte) error {
+	content = bytes.TrimSpace(content)
+
+	switch {
+	case bytes.HasPrefix(content, []byte("{")):
+		var scene SceneStatus
+		if json.Unmarshal(content, &scene) == nil {
+			es.Scene = &scene
+		}
+	case bytes.HasPrefix(content, []byte(`"`)):
+		_ = json.Unmarshal(content, &es.Connectivity)
+	}
+
+	return nil
+}
+
 type Event struct {
 	Type string `json:"type"`
 	Data []struct {
@@ -29,7 +56,15 @@ type Event struct {
 		Owner            deviceReference   `json:"owner"`
 		ID               string            `json:"id"`
 		Type             string            `json:"type"`
-		PowerState       struct {
+		Status           eventStatus       `json:"status"`
+		State            json.RawMessage   `json:"state"`
+		Button           *struct {
+			LastEvent string `json:"last_event"`
+		} `json:"button,omitempty"`
+		ProductData *struct {
+			SoftwareVersion string `json:"software_version"`
+		} `json:"product_data,omitempty"`
+		PowerState struct {
 			BatteryState string `json:"battery_state"`
 			BatteryLevel int64  `json:"battery_level"`
 		} `json:"power_state"`
@@ -56,12 +91,12 @@ func createInsecureClient(timeout time.Duration) *http.Client {
 	return client
 }
 
-func (s *Service) streamIndefinitely(done <-chan struct{}) {
+func (s *Service) streamIndefinitely(ctx context.Context) {
 	for {
-		s.stream(done)
+		s.stream(ctx)
 
 		select {
-		case <-done:
+		case <-ctx.Done():
 			return
 		default:
 			slog.Warn("Streaming was ended before done receive, restarting in 30sec...")
@@ -70,10 +105,8 @@ func (s *Service) streamIndefinitely(done <-chan struct{}) {
 	}
 }
 
-func (s *Service) stream(done <-chan struct{}) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
+func (s *Service) stream(ctx context.Context) {
+	// Not instrumented: the request lasts as long as the stream, each batch of events has its own span instead
 	resp, err := s.req.Path("/eventstream/clip/v2").Accept("text/event-stream").WithClient(createInsecureClient(0)).Send(ctx, nil)
 	if err != nil {
 		slog.LogAttrs(ctx, slog.LevelError, "open stream", slog.Any("error", err))
@@ -83,13 +116,8 @@ func (s *Service) stream(done <-chan struct{}) {
 	slog.Info("Streaming events from hub...")
 	defer slog.Info("Streaming events ended.")
 
-	go func() {
-		select {
-		case <-ctx.Done():
-		case <-done:
-			cancel()
-		}
-	}()
+	s.streamMetric.Record(ctx, 1)
+	defer s.streamMetric.Record(context.WithoutCancel(ctx), 0)
 
 	reader := bufio.NewScanner(resp.Body)
 
@@ -99,17 +127,7 @@ func (s *Service) stream(done <-chan struct{}) {
 			continue
 		}
 
-		var events []Event
-		content = content[len(dataPrefix):]
-
-		if err := json.Unmarshal(content, &events); err != nil {
-			slog.LogAttrs(ctx, slog.LevelError, "parse event", slog.String("content", string(content)), slog.Any("error", err))
-			continue
-		}
-
-		for _, event := range events {
-			s.handleStreamEvent(ctx, event)
-		}
+		s.handleStreamBatch(ctx, content[len(dataPrefix):])
 	}
 
 	if closeErr := resp.Body.Close(); closeErr != nil {
@@ -117,15 +135,39 @@ func (s *Service) stream(done <-chan struct{}) {
 	}
 }
 
+func (s *Service) handleStreamBatch(ctx context.Context, content []byte) {
+	var err error
+
+	ctx, end := telemetry.StartSpan(ctx, s.tracer, "stream", trace.WithSpanKind(trace.SpanKindConsumer))
+	defer end(&err)
+
+	var events []Event
+
+	if err = json.Unmarshal(content, &events); err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "parse event", slog.String("content", string(content)), slog.Any("error", err))
+		return
+	}
+
+	var count int
+	for _, event := range events {
+		count += len(event.Data)
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("events", count))
+
+	for _, event := range events {
+		s.handleStreamEvent(ctx, event)
+	}
+}
+
 func (s *Service) handleStreamEvent(ctx context.Context, event Event) {
 	for _, data := range event.Data {
+		s.eventMetric.Add(ctx, 1, metric.WithAttributes(attribute.String("type", data.Type)))
+
 		switch data.Type {
 		case "behavior_instance":
 		case "behavior_script":
 		case "bridge_home":
-		case "button":
-		case "device":
-		case "device_software_update":
 		case "entertainment":
 		case "geofence_client":
 		case "geolocation":
@@ -135,36 +177,73 @@ func (s *Service) handleStreamEvent(ctx context.Context, event Event) {
 		case "motion_area_candidate":
 		case "relative_rotary":
 		case "room":
-		case "scene":
 		case "taurus_7455":
-		case "zgp_connectivity":
-		case "zigbee_connectivity":
 		case "zigbee_device_discovery":
 		case "zone":
+		case "scene":
+			s.updateScene(ctx, event.Type, data.ID, data.Status.Scene)
+		case "smart_scene":
+			s.updateSmartScene(ctx, event.Type, data.ID)
 		case "motion":
 			s.UpdateMotion(ctx, data.Owner.Rid, data.Enabled, data.Motion)
 		case "light_level":
 			s.updateLightLevel(ctx, data.Owner.Rid, data.Light.Level)
 		case "temperature":
 			s.updateTemperature(ctx, data.Owner.Rid, data.Temperature.Temperature)
+		case "button":
+			if data.Button != nil {
+				s.countButton(ctx, data.ID, data.Owner.Rid, data.Button.LastEvent)
+			}
+		case "device":
+			if data.ProductData != nil {
+				s.updateSoftwareVersion(ctx, data.ID, data.ProductData.SoftwareVersion)
+			}
+		case "device_software_update":
+			s.updateSoftwareUpdate(ctx, data.Owner.Rid, parseSoftwareUpdateState(data.State))
+		case "zigbee_connectivity", "zgp_connectivity":
+			s.updateConnectivity(ctx, data.Owner.Rid, data.Status.Connectivity)
 		case "device_power":
 			s.updateDevicePower(ctx, data.Owner.Rid, data.PowerState.BatteryState, data.PowerState.BatteryLevel)
 		case "light":
-			s.updateLight(ctx, data.ID, data.On, data.Dimming)
+			s.updateLight(ctx, data.ID, data.On, data.Dimming, data.Color, data.ColorTemperature)
 		case "grouped_light":
 			s.updateGroupedLight(ctx, data.ID, data.On, data.Dimming)
 		default:
 			slog.LogAttrs(ctx, slog.LevelInfo, "unhandled event received", slog.String("type", data.Type))
 		}
+
+		s.publishChanges(data.Type, data.ID, data.Owner.Rid)
+	}
+}
+
+// externalChange is a change received from the event stream, logged in the activity once the lock is released
+type externalChange struct {
+	kind   activity.Kind
+	target string
+	name   string
+	state  string
+}
+
+func (s *Service) recordExternal(ctx context.Context, change *externalChange) {
+	if change != nil {
+		s.activity.RecordExternal(ctx, change.kind, change.target, change.name, change.state)
 	}
 }
 
 func (s *Service) UpdateMotion(ctx context.Context, owner string, enabled *bool, motion *MotionValue) {
+	s.recordExternal(ctx, s.applyMotion(ctx, owner, enabled, motion))
+}
+
+func (s *Service) applyMotion(ctx context.Context, owner string, enabled *bool, motion *MotionValue) (external *externalChange) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	if motionSensor, ok := s.motionSensors[owner]; ok {
 		if enabled != nil {
+			if motionSensor.Enabled != *enabled {
+				external = &externalChange{kind: activity.Sensor, target: motionSensor.ID, name: motionSensor.Name, state: sensorActivityState(*enabled)}
+			}
+
 			motionSensor.Enabled = *enabled
 			slog.LogAttrs(ctx, slog.LevelDebug, "Motion status", slog.Bool("value", motionSensor.Enabled), slog.String("sensor", motionSensor.Name))
 		}
@@ -176,7 +255,8 @@ func (s *Service) UpdateMotion(ctx context.Context, owner string, enabled *bool,
 			if motion.Motion {
 				value = motionValue
 			}
-			s.motionMetric.Record(ctx, value, metric.WithAttributes(attribute.String("room", motionSensor.Name)))
+			s.motionMetric.Record(ctx, value, metric.WithAttributes(s.deviceAttributes("motion", owner, motionSensor.Name)...))
+			s.recordHistory(ctx, owner, history.Motion, float64(value))
 			slog.LogAttrs(ctx, slog.LevelDebug, "Motion", slog.Bool("motion", motionSensor.Motion), slog.String("sensor", motionSensor.Name))
 		}
 
@@ -184,6 +264,8 @@ func (s *Service) UpdateMotion(ctx context.Context, owner string, enabled *bool,
 	} else {
 		slog.LogAttrs(ctx, slog.LevelWarn, "unknown motion owner ID", slog.String("owner", owner))
 	}
+
+	return external
 }
 
 func (s *Service) updateLightLevel(ctx context.Context, owner string, lightLevel int64) {
@@ -193,8 +275,10 @@ func (s *Service) updateLightLevel(ctx context.Context, owner string, lightLevel
 	if motionSensor, ok := s.motionSensors[owner]; ok {
 		motionSensor.LightLevelValue = lightLevel
 
-		s.lightLevelMetric.Record(ctx, lightLevel, metric.WithAttributes(attribute.String("room", motionSensor.Name)))
-		slog.LogAttrs(ctx, slog.LevelDebug, "Light level", slog.Int64("level", lightLevel), slog.String("sensor", motionSensor.Name))
+		s.lightLevelMetric.Record(ctx, lightLevel, metric.WithAttributes(s.deviceAttributes("motion", owner, motionSensor.Name)...))
+		s.luxMetric.Record(ctx, motionSensor.Lux(), metric.WithAttributes(s.deviceAttributes("motion", owner, motionSensor.Name)...))
+		s.recordHistory(ctx, owner, history.Lux, motionSensor.Lux())
+		slog.LogAttrs(ctx, slog.LevelDebug, "Light level", slog.Int64("level", lightLevel), slog.Float64("lux", motionSensor.Lux()), slog.String("sensor", motionSensor.Name))
 
 		s.motionSensors[owner] = motionSensor
 	} else {
@@ -207,9 +291,11 @@ func (s *Service) updateTemperature(ctx context.Context, owner string, temperatu
 	defer s.mutex.Unlock()
 
 	if motionSensor, ok := s.motionSensors[owner]; ok {
+		temperature = s.calibrateTemperature(motionSensor.Name, temperature)
 		motionSensor.Temperature = temperature
 
-		s.temperatureMetric.Record(ctx, temperature, metric.WithAttributes(attribute.String("room", motionSensor.Name)))
+		s.temperatureMetric.Record(ctx, temperature, metric.WithAttributes(s.deviceAttributes("motion", owner, motionSensor.Name)...))
+		s.recordHistory(ctx, owner, history.Temperature, temperature)
 		slog.LogAttrs(ctx, slog.LevelDebug, "Temperature", slog.Float64("temperature", temperature), slog.String("sensor", motionSensor.Name))
 
 		s.motionSensors[owner] = motionSensor
@@ -226,10 +312,8 @@ func (s *Service) updateDevicePower(ctx context.Context, owner, batteryState str
 		motionSensor.BatteryState = batteryState
 		motionSensor.BatteryLevel = batteryLevel
 
-		s.batteryMetric.Record(ctx, batteryLevel, metric.WithAttributes(
-			attribute.String("kind", "motion"),
-			attribute.String("name", motionSensor.Name),
-		))
+		s.batteryMetric.Record(ctx, batteryLevel, metric.WithAttributes(s.deviceAttributes("motion", owner, motionSensor.Name)...))
+		s.recordHistory(ctx, owner, history.Battery, float64(batteryLevel))
 		slog.LogAttrs(ctx, slog.LevelDebug, "Battery", slog.Int64("battery", batteryLevel), slog.String("sensor", motionSensor.Name))
 
 		s.motionSensors[owner] = motionSensor
@@ -237,10 +321,7 @@ func (s *Service) updateDevicePower(ctx context.Context, owner, batteryState str
 		tap.BatteryState = batteryState
 		tap.BatteryLevel = batteryLevel
 
-		s.batteryMetric.Record(ctx, batteryLevel, metric.WithAttributes(
-			attribute.String("kind", "tap"),
-			attribute.String("name", tap.Name),
-		))
+		s.batteryMetric.Record(ctx, batteryLevel, metric.WithAttributes(s.deviceAttributes("tap", owner, tap.Name)...))
 		slog.LogAttrs(ctx, slog.LevelDebug, "Battery", slog.Int64("battery", batteryLevel), slog.String("sensor", tap.Name))
 
 		s.taps[owner] = tap
@@ -249,7 +330,7 @@ func (s *Service) updateDevicePower(ctx context.Context, owner, batteryState str
 	}
 }
 
-func (s *Service) updateLight(ctx context.Context, owner string, on *On, dimming *Dimming) {
+func (s *Service) updateLight(ctx context.Context, owner string, on *On, dimming *Dimming, color *Color, colorTemperature *ColorTemperature) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -263,12 +344,27 @@ func (s *Service) updateLight(ctx context.Context, owner string, on *On, dimming
 			light.On.On = on.On
 			slog.LogAttrs(ctx, slog.LevelDebug, "Light status", slog.Bool("on", on.On), slog.String("name", light.Metadata.Name))
 		}
+
+		if color != nil {
+			light.Color = *color
+		}
+
+		if colorTemperature != nil {
+			// Mirek is null, hence zero, when the light is in color mode
+			light.ColorTemperature = *colorTemperature
+		}
+
+		s.recordLight(ctx, light)
 	} else {
 		slog.LogAttrs(ctx, slog.LevelWarn, "unknown light ID", slog.String("owner", owner))
 	}
 }
 
 func (s *Service) updateGroupedLight(ctx context.Context, owner string, on *On, dimming *Dimming) {
+	s.recordExternal(ctx, s.applyGroupedLight(ctx, owner, on, dimming))
+}
+
+func (s *Service) applyGroupedLight(ctx context.Context, owner string, on *On, dimming *Dimming) (external *externalChange) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -281,13 +377,27 @@ func (s *Service) updateGroupedLight(ctx context.Context, owner string, on *On,
 		}
 
 		if on != nil {
+			if groupedLight.On.On != on.On {
+				external = &externalChange{kind: activity.Group, target: group.ID, name: group.Name, state: GroupUpdate{On: on.On, Brightness: groupedLight.Dimming.Brightness}.String()}
+			}
+
 			groupedLight.On.On = on.On
 			slog.LogAttrs(ctx, slog.LevelDebug, "Group status", slog.Bool("on", on.On), slog.String("group", group.Name))
 		}
 
 		group.GroupedLights[owner] = groupedLight
 		s.groups[group.ID] = group
+
+		s.recordGroup(ctx, group, groupedLight)
 	} else {
 		slog.LogAttrs(ctx, slog.LevelWarn, "unknown grouped light ID", slog.String("owner", owner))
 	}
+
+	return external
+}
+
+func (s *Service) recordHistory(ctx context.Context, sensor string, kind history.Metric, value float64) {
+	if err := s.history.Record(sensor, kind, time.Now(), value); err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "record history", slog.String("sensor", sensor), slog.String("metric", string(kind)), slog.Any("error", err))
+	}
 }
@@ -0,0 +1,37 @@
+package v2
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEventState(t *testing.T) {
+	cases := map[string]struct {
+		content string
+		want    SoftwareUpdateState
+	}{
+		"software update": {
+			content: `[{"type": "update", "data": [{"type": "device_software_update", "state": "ready_to_install"}]}]`,
+			want:    ReadyToInstall,
+		},
+		"object": {
+			content: `[{"type": "update", "data": [{"type": "behavior_instance", "state": {"source_type": "motion"}, "status": {"running": true}}]}]`,
+		},
+		"none": {
+			content: `[{"type": "update", "data": [{"type": "light", "on": {"on": true}}]}]`,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var events []Event
+			if err := json.Unmarshal([]byte(tc.content), &events); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := parseSoftwareUpdateState(events[0].Data[0].State); got != tc.want {
+				t.Errorf("parseSoftwareUpdateState() = `%s`, want `%s`", got, tc.want)
+			}
+		})
+	}
+}
@@ -13,6 +13,7 @@ type Tap struct {
 	IDV1         string
 	Name         string
 	BatteryState string
+	Connectivity Connectivity
 	BatteryLevel int64
 	Dial         bool
 }